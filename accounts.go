@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+)
+
+// accountsKey is the CredentialStore key holding the account list. Each account's
+// credentials are stored separately under accountKey so no entry outgrows the
+// Windows Credential Manager limit.
+const accountsKey = "accounts"
+
+// Account describes a named iSolarCloud login, without its secrets
+type Account struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	AppKey        string `json:"appKey"`
+	GatewayURL    string `json:"gatewayUrl"`
+	Active        bool   `json:"active"`
+	Authenticated bool   `json:"authenticated"`
+	TokenExpiry   int64  `json:"tokenExpiry"`
+}
+
+// accountIndex is the stored list of accounts
+type accountIndex struct {
+	Active   string         `json:"active"`
+	Accounts []accountEntry `json:"accounts"`
+}
+
+// accountEntry is an account in accountIndex
+type accountEntry struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// account is a login with its own credentials, API client and token refresh
+type account struct {
+	id   string
+	name string // guarded by app.accountsMu, as RenameAccount changes it
+	app  *App
+
+	mu          sync.Mutex
+	credentials *Credentials
+	client      *isolarcloud.Client
+}
+
+// accountKey is the CredentialStore key an account's credentials are saved under
+func accountKey(id string) string {
+	return "account-" + id
+}
+
+// newAccountID returns a short random account ID
+func newAccountID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// displayName returns the account's current name
+func (acct *account) displayName() string {
+	acct.app.accountsMu.Lock()
+	defer acct.app.accountsMu.Unlock()
+	return acct.name
+}
+
+// info describes the account for the frontend
+func (acct *account) info(active bool) Account {
+	name := acct.displayName()
+
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+
+	info := Account{ID: acct.id, Name: name, Active: active}
+	if creds := acct.credentials; creds != nil {
+		info.AppKey = creds.AppKey
+		info.GatewayURL = creds.GatewayURL
+		info.Authenticated = creds.AccessToken != "" || creds.RefreshToken != ""
+		info.TokenExpiry = creds.TokenExpiry
+	}
+	return info
+}
+
+// Credentials returns a copy of the account's credentials, or nil when signed out
+func (acct *account) Credentials() *Credentials {
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+	if acct.credentials == nil {
+		return nil
+	}
+	creds := *acct.credentials
+	return &creds
+}
+
+// setCredentials replaces the credentials and drops the client built from the old ones
+func (acct *account) setCredentials(creds *Credentials) {
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+	acct.credentials = creds
+	acct.client = nil
+}
+
+// apiClient returns the account's iSolarCloud client
+func (acct *account) apiClient() (*isolarcloud.Client, error) {
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+
+	if acct.credentials == nil || acct.credentials.AccessToken == "" {
+		return nil, isolarcloud.ErrNotAuthenticated
+	}
+
+	return acct.clientLocked(), nil
+}
+
+// clientLocked returns the cached client, creating it if the credentials changed.
+// The caller must hold mu.
+func (acct *account) clientLocked() *isolarcloud.Client {
+	if acct.client == nil {
+		acct.client = isolarcloud.NewClient(acct.credentials.AppKey, acct.credentials.SecretKey,
+			isolarcloud.WithBaseURL(acct.credentials.GatewayURL),
+			isolarcloud.WithHTTPClient(acct.app.httpClient),
+			isolarcloud.WithRateLimiter(acct.app.rateLimiter(acct.credentials.AppKey)),
+			isolarcloud.WithCircuitBreaker(acct.app.gatewayBreaker(acct.credentials.GatewayURL)),
+			isolarcloud.WithRealTimeBatching(realTimeBatchWindow),
+			isolarcloud.WithAuth(acct),
+			isolarcloud.WithObserver(acct.app.metrics.observeRequest),
+		)
+	}
+	return acct.client
+}
+
+// save persists the account's credentials
+func (acct *account) save() error {
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+	return acct.saveLocked()
+}
+
+// saveLocked persists the account's credentials. The caller must hold mu.
+func (acct *account) saveLocked() error {
+	store, err := acct.app.credentialStore()
+	if err != nil {
+		return err
+	}
+
+	if acct.credentials == nil {
+		return store.Delete(accountKey(acct.id))
+	}
+
+	data, err := json.Marshal(acct.credentials)
+	if err != nil {
+		return err
+	}
+	return store.Save(accountKey(acct.id), data)
+}
+
+// activeAccount returns the account used for sign in and unrouted calls, or nil if there are none
+func (a *App) activeAccount() *account {
+	a.accountsMu.Lock()
+	defer a.accountsMu.Unlock()
+	return a.accounts[a.activeID]
+}
+
+// loginAccount returns the active account, creating a first one if needed
+func (a *App) loginAccount() *account {
+	if acct := a.activeAccount(); acct != nil {
+		return acct
+	}
+
+	acct, err := a.addAccount("Default")
+	if err != nil {
+		a.log.Printf("loginAccount: %v\n", err)
+	}
+	return acct
+}
+
+// orderedAccounts returns the accounts in the order they were added
+func (a *App) orderedAccounts() []*account {
+	a.accountsMu.Lock()
+	defer a.accountsMu.Unlock()
+
+	accounts := make([]*account, 0, len(a.accountOrder))
+	for _, id := range a.accountOrder {
+		accounts = append(accounts, a.accounts[id])
+	}
+	return accounts
+}
+
+// addAccount creates an empty account and makes it active
+func (a *App) addAccount(name string) (*account, error) {
+	acct := &account{id: newAccountID(), name: name, app: a}
+
+	a.accountsMu.Lock()
+	a.accounts[acct.id] = acct
+	a.accountOrder = append(a.accountOrder, acct.id)
+	a.activeID = acct.id
+	a.accountsMu.Unlock()
+
+	return acct, a.saveAccountIndex()
+}
+
+// saveAccountIndex persists the account list and the active account
+func (a *App) saveAccountIndex() error {
+	store, err := a.credentialStore()
+	if err != nil {
+		return err
+	}
+
+	a.accountsMu.Lock()
+	var index accountIndex
+	index.Active = a.activeID
+	for _, id := range a.accountOrder {
+		index.Accounts = append(index.Accounts, accountEntry{ID: id, Name: a.accounts[id].name})
+	}
+	a.accountsMu.Unlock()
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	return store.Save(accountsKey, data)
+}
+
+// loadAccounts loads every account from the credential store, moving credentials saved
+// by single-account versions into a "Default" account
+func (a *App) loadAccounts() {
+	store, err := a.credentialStore()
+	if err != nil {
+		return
+	}
+
+	accounts := map[string]*account{}
+	var order []string
+	var index accountIndex
+
+	data, err := store.Load(accountsKey)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &index); err != nil {
+			a.log.Printf("loadAccounts: %v\n", err)
+			return
+		}
+	case errors.Is(err, errSecretNotFound):
+		index, err = a.migrateSingleAccount(store)
+		if err != nil {
+			if !errors.Is(err, errSecretNotFound) {
+				a.log.Printf("loadAccounts: %s: %v\n", store.Name(), err)
+			}
+			return
+		}
+	default:
+		a.log.Printf("loadAccounts: %s: %v\n", store.Name(), err)
+		return
+	}
+
+	for _, entry := range index.Accounts {
+		acct := &account{id: entry.ID, name: entry.Name, app: a}
+		data, err := store.Load(accountKey(entry.ID))
+		if err == nil {
+			var creds Credentials
+			if err := json.Unmarshal(data, &creds); err == nil {
+				acct.credentials = &creds
+			}
+		} else if !errors.Is(err, errSecretNotFound) {
+			a.log.Printf("loadAccounts: %s: %v\n", entry.Name, err)
+		}
+		accounts[entry.ID] = acct
+		order = append(order, entry.ID)
+	}
+
+	active := index.Active
+	if _, ok := accounts[active]; !ok && len(order) > 0 {
+		active = order[0]
+	}
+
+	a.accountsMu.Lock()
+	a.accounts = accounts
+	a.accountOrder = order
+	a.activeID = active
+	a.accountsMu.Unlock()
+}
+
+// migrateSingleAccount stores the credentials of a single-account version as the "Default" account
+func (a *App) migrateSingleAccount(store CredentialStore) (accountIndex, error) {
+	var index accountIndex
+
+	data, err := store.Load(credentialsKey)
+	if errors.Is(err, errSecretNotFound) {
+		data, err = a.migratePlaintextCredentials(store)
+	}
+	if err != nil {
+		return index, err
+	}
+
+	id := "default"
+	if err := store.Save(accountKey(id), data); err != nil {
+		return index, err
+	}
+
+	index.Active = id
+	index.Accounts = append(index.Accounts, accountEntry{ID: id, Name: "Default"})
+	encoded, err := json.Marshal(index)
+	if err != nil {
+		return index, err
+	}
+	if err := store.Save(accountsKey, encoded); err != nil {
+		return index, err
+	}
+
+	store.Delete(credentialsKey)
+	a.log.Printf("migrateSingleAccount: moved credentials to the Default account\n")
+	return index, nil
+}
+
+// findAccount returns the account with an ID or, failing that, a case-insensitive name
+func (a *App) findAccount(idOrName string) *account {
+	a.accountsMu.Lock()
+	defer a.accountsMu.Unlock()
+
+	if acct, ok := a.accounts[idOrName]; ok {
+		return acct
+	}
+	for _, id := range a.accountOrder {
+		if strings.EqualFold(a.accounts[id].name, idOrName) {
+			return a.accounts[id]
+		}
+	}
+	return nil
+}
+
+// psKeyPlantID reads the plant ID from a ps_key such as "1234567_14_1_1"
+func psKeyPlantID(psKey string) (int, bool) {
+	prefix, _, _ := strings.Cut(psKey, "_")
+	psID, err := strconv.Atoi(prefix)
+	return psID, err == nil
+}
+
+// accountForPlant returns the account a plant belongs to. Plants not listed yet are
+// looked up across all accounts, falling back to the active account.
+func (a *App) accountForPlant(psID int) *account {
+	a.accountsMu.Lock()
+	id, known := a.plantAccounts[psID]
+	single := len(a.accounts) <= 1
+	a.accountsMu.Unlock()
+
+	if !known && !single {
+		if _, err := a.GetPlantList(); err == nil {
+			a.accountsMu.Lock()
+			id, known = a.plantAccounts[psID]
+			a.accountsMu.Unlock()
+		}
+	}
+
+	if known {
+		if acct := a.findAccount(id); acct != nil {
+			return acct
+		}
+	}
+	return a.activeAccount()
+}
+
+// clientForPlant returns the API client of the account a plant belongs to
+func (a *App) clientForPlant(psID int) (*isolarcloud.Client, error) {
+	acct := a.accountForPlant(psID)
+	if acct == nil {
+		return nil, isolarcloud.ErrNotAuthenticated
+	}
+	return acct.apiClient()
+}
+
+// clientForPsKey returns the API client of the account a device belongs to
+func (a *App) clientForPsKey(psKey string) (*isolarcloud.Client, error) {
+	if psID, ok := psKeyPlantID(psKey); ok {
+		return a.clientForPlant(psID)
+	}
+	return a.apiClient()
+}
+
+// rememberPlants records which account listed each plant and tags them with it
+func (a *App) rememberPlants(acct *account, plants []Plant) {
+	a.accountsMu.Lock()
+	defer a.accountsMu.Unlock()
+	for i := range plants {
+		plants[i].AccountID = acct.id
+		a.plantAccounts[plants[i].PsID] = acct.id
+	}
+}
+
+// signedInAccounts returns the accounts holding tokens
+func (a *App) signedInAccounts() []*account {
+	var accounts []*account
+	for _, acct := range a.orderedAccounts() {
+		if creds := acct.Credentials(); creds != nil && creds.AccessToken != "" {
+			accounts = append(accounts, acct)
+		}
+	}
+	return accounts
+}
+
+// GetAccounts lists the accounts
+func (a *App) GetAccounts() []Account {
+	active := a.activeAccount()
+	accounts := []Account{}
+	for _, acct := range a.orderedAccounts() {
+		accounts = append(accounts, acct.info(acct == active))
+	}
+	return accounts
+}
+
+// AddAccount creates an account and makes it active, ready for Authenticate
+func (a *App) AddAccount(name string) (*Account, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("account name is required")
+	}
+	if a.findAccount(name) != nil {
+		return nil, fmt.Errorf("an account named %q already exists", name)
+	}
+
+	acct, err := a.addAccount(name)
+	if err != nil {
+		return nil, err
+	}
+	info := acct.info(true)
+	return &info, nil
+}
+
+// SwitchAccount makes an account active
+func (a *App) SwitchAccount(id string) error {
+	a.accountsMu.Lock()
+	_, ok := a.accounts[id]
+	if ok {
+		a.activeID = id
+	}
+	a.accountsMu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("unknown account %q", id)
+	}
+	return a.saveAccountIndex()
+}
+
+// RenameAccount changes an account's name
+func (a *App) RenameAccount(id string, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("account name is required")
+	}
+	if other := a.findAccount(name); other != nil && other.id != id {
+		return fmt.Errorf("an account named %q already exists", name)
+	}
+
+	a.accountsMu.Lock()
+	acct, ok := a.accounts[id]
+	if ok {
+		acct.name = name
+	}
+	a.accountsMu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("unknown account %q", id)
+	}
+	return a.saveAccountIndex()
+}
+
+// RemoveAccount deletes an account and its stored credentials
+func (a *App) RemoveAccount(id string) error {
+	a.accountsMu.Lock()
+	acct, ok := a.accounts[id]
+	if ok {
+		delete(a.accounts, id)
+		a.accountOrder = slices.DeleteFunc(a.accountOrder, func(other string) bool { return other == id })
+		for psID, owner := range a.plantAccounts {
+			if owner == id {
+				delete(a.plantAccounts, psID)
+			}
+		}
+		if a.activeID == id {
+			a.activeID = ""
+			if len(a.accountOrder) > 0 {
+				a.activeID = a.accountOrder[0]
+			}
+		}
+	}
+	a.accountsMu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("unknown account %q", id)
+	}
+
+	acct.setCredentials(nil)
+	if err := acct.save(); err != nil {
+		return err
+	}
+	return a.saveAccountIndex()
+}
+
+// listPlantsPerAccount calls list for every signed in account, tagging the plants with
+// their account and watching their status. Failing accounts are skipped unless all of them fail.
+func (a *App) listPlantsPerAccount(ctx context.Context, list func(ctx context.Context, client *isolarcloud.Client) ([]Plant, error)) ([]Plant, error) {
+	accounts := a.signedInAccounts()
+	if len(accounts) == 0 {
+		return nil, isolarcloud.ErrNotAuthenticated
+	}
+
+	var plants []Plant
+	var firstErr error
+	failed := 0
+	for _, acct := range accounts {
+		client, err := acct.apiClient()
+		var listed []Plant
+		if err == nil {
+			listed, err = list(ctx, client)
+		}
+		if err != nil {
+			a.log.Printf("listPlantsPerAccount: %s: %v\n", acct.displayName(), err)
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		a.rememberPlants(acct, listed)
+		a.observePlants(listed)
+		plants = append(plants, listed...)
+	}
+
+	if failed == len(accounts) {
+		return nil, firstErr
+	}
+	return plants, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+)
+
+// memoryStore is a CredentialStore in memory
+type memoryStore struct {
+	mu      sync.Mutex
+	secrets map[string][]byte
+}
+
+func (s *memoryStore) Name() string {
+	return "memory"
+}
+
+func (s *memoryStore) Load(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.secrets[key]
+	if !ok {
+		return nil, errSecretNotFound
+	}
+	return data, nil
+}
+
+func (s *memoryStore) Save(key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[key] = data
+	return nil
+}
+
+func (s *memoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.secrets, key)
+	return nil
+}
+
+// TestRenameAccountWhileListing renames an account while its name is read for the
+// frontend and logs, for the race detector
+func TestRenameAccountWhileListing(t *testing.T) {
+	a := NewApp()
+	a.log.SetOutput(io.Discard)
+	a.credStore = &memoryStore{secrets: map[string][]byte{}}
+	acct, err := a.addAccount("Home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acct.setCredentials(&Credentials{AppKey: "appkey", AccessToken: "token"})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 100 {
+			if err := a.RenameAccount(acct.id, fmt.Sprintf("Home %d", i)); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	errList := errors.New("gateway down")
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-done:
+			if accounts := a.GetAccounts(); len(accounts) != 1 || accounts[0].Name != "Home 99" {
+				t.Errorf("accounts after renaming = %+v", accounts)
+			}
+			return
+		default:
+			a.GetAccounts()
+			a.listPlantsPerAccount(context.Background(), func(ctx context.Context, client *isolarcloud.Client) ([]Plant, error) {
+				return nil, errList
+			})
+		}
+	}
+}
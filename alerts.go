@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+
+	"wails-sungrow-isolarcloud-app/alerts"
+)
+
+// notificationAppName is shown as the source of desktop notifications
+const notificationAppName = "Sungrow Monitor"
+
+// AlertSettings holds the alert rules evaluated against every poll
+type AlertSettings struct {
+	Notifications bool          `json:"notifications"` // show desktop notifications, not just in-app alerts
+	Rules         []alerts.Rule `json:"rules"`
+}
+
+// defaultAlertRules warns when a battery runs low
+func defaultAlertRules() []alerts.Rule {
+	return []alerts.Rule{
+		{
+			ID:              "battery-low",
+			Name:            "Battery low",
+			Enabled:         true,
+			Condition:       alerts.ConditionBelow,
+			Point:           batterySocKey,
+			Threshold:       15,
+			Hysteresis:      5,
+			CooldownSeconds: 3600,
+		},
+	}
+}
+
+// withDefaults gives new rules an ID and checks every rule can be evaluated
+func (s AlertSettings) withDefaults() (AlertSettings, error) {
+	s.Rules = slices.Clone(s.Rules)
+	for i := range s.Rules {
+		if s.Rules[i].ID == "" {
+			s.Rules[i].ID = newRuleID()
+		}
+		if err := s.Rules[i].Validate(); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// newRuleID returns a random alert rule ID
+func newRuleID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// evaluateAlerts checks a poll result against the alert rules, telling the frontend and
+// the desktop about alerts that fire or clear
+func (a *App) evaluateAlerts(t PollTarget, r PollResult) {
+	events := a.alerts.Observe(alerts.Observation{
+		PsKey:      r.PsKey,
+		DeviceName: t.DeviceName,
+		Online:     r.Error == "",
+		Readings:   r.Readings,
+		Timestamp:  r.Timestamp,
+	})
+
+	notify := a.GetSettings().Alerts.Notifications
+	for _, event := range events {
+		a.log.Printf("evaluateAlerts: %s %s: %s\n", event.RuleName, event.State, event.Message)
+		a.emit("alert", event)
+		if event.Notify {
+			a.publishWebhook(webhookEventAlert, event)
+		}
+
+		if !event.Notify || !notify || a.headless {
+			continue
+		}
+		title := event.RuleName
+		if event.State == alerts.StateResolved {
+			title += " resolved"
+		}
+		if err := sendNotification(title, event.Message); err != nil {
+			a.log.Printf("evaluateAlerts: notification failed: %v\n", err)
+		}
+	}
+}
+
+// GetActiveAlerts returns the alerts currently firing
+func (a *App) GetActiveAlerts() []alerts.Alert {
+	return a.alerts.Active()
+}
+
+// SimulateAlertRule runs a rule over synthetic observations, returning the events it
+// would raise. Nothing is notified and the live alert state is untouched.
+func (a *App) SimulateAlertRule(rule alerts.Rule, observations []alerts.Observation) ([]alerts.Event, error) {
+	if rule.ID == "" {
+		rule.ID = "simulated"
+	}
+	rule.Enabled = true
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	engine := alerts.NewEngine([]alerts.Rule{rule})
+	events := []alerts.Event{}
+	for _, obs := range observations {
+		events = append(events, engine.Observe(obs)...)
+	}
+	return events, nil
+}
@@ -0,0 +1,342 @@
+// Package alerts evaluates polled readings against user-defined threshold rules.
+//
+// A rule fires once its condition has held for its delay, and clears only when the
+// value moves back past the threshold by the hysteresis, so a reading hovering around
+// the threshold doesn't flap. Notifications of a rule and device are spaced by the
+// rule's cooldown. The engine keeps no clock of its own: every observation carries its
+// time, so rules can be exercised with synthetic readings.
+package alerts
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+)
+
+// Condition is what a rule checks
+type Condition string
+
+const (
+	ConditionBelow   Condition = "below"   // the point's value is under the threshold
+	ConditionAbove   Condition = "above"   // the point's value is over the threshold
+	ConditionOffline Condition = "offline" // the device can't be polled
+)
+
+// Rule is a user-defined alert
+type Rule struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// PsKey limits the rule to one device, empty applies it to every watched device
+	PsKey     string    `json:"psKey,omitempty"`
+	Condition Condition `json:"condition"`
+	// Point is a catalogue key such as "battery_soc" or a point ID such as "13149".
+	// Thresholds are in the reading's unit, e.g. percent for battery_soc.
+	Point           string  `json:"point,omitempty"`
+	Threshold       float64 `json:"threshold"`
+	Hysteresis      float64 `json:"hysteresis"`
+	DelaySeconds    int     `json:"delaySeconds"`    // how long the condition must hold before firing
+	CooldownSeconds int     `json:"cooldownSeconds"` // minimum time between notifications
+}
+
+// Validate checks a rule can be evaluated
+func (r Rule) Validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("rule ID is required")
+	}
+	if r.DelaySeconds < 0 || r.CooldownSeconds < 0 || r.Hysteresis < 0 {
+		return fmt.Errorf("rule %q: delay, cooldown and hysteresis can't be negative", r.Name)
+	}
+
+	switch r.Condition {
+	case ConditionBelow, ConditionAbove:
+		if r.Point == "" {
+			return fmt.Errorf("rule %q: a point is required", r.Name)
+		}
+	case ConditionOffline:
+	default:
+		return fmt.Errorf("rule %q: unknown condition %q", r.Name, r.Condition)
+	}
+	return nil
+}
+
+// matches reports whether a reading is of the rule's point
+func (r Rule) matches(reading isolarcloud.Reading) bool {
+	if reading.Key != "" && reading.Key == r.Point {
+		return true
+	}
+	return strings.TrimPrefix(r.Point, "p") == fmt.Sprint(reading.PointID)
+}
+
+// Observation is the outcome of one poll of a device
+type Observation struct {
+	PsKey      string                `json:"psKey"`
+	DeviceName string                `json:"deviceName,omitempty"`
+	Online     bool                  `json:"online"` // false when the poll failed
+	Readings   []isolarcloud.Reading `json:"readings,omitempty"`
+	Timestamp  int64                 `json:"timestamp"` // Unix milliseconds
+}
+
+// EventState is whether an event raised or cleared an alert
+type EventState string
+
+const (
+	StateFiring   EventState = "firing"
+	StateResolved EventState = "resolved"
+)
+
+// Event is an alert firing or clearing
+type Event struct {
+	RuleID     string     `json:"ruleId"`
+	RuleName   string     `json:"ruleName"`
+	PsKey      string     `json:"psKey"`
+	DeviceName string     `json:"deviceName,omitempty"`
+	State      EventState `json:"state"`
+	Value      float64    `json:"value"`
+	Unit       string     `json:"unit,omitempty"`
+	Message    string     `json:"message"`
+	Timestamp  int64      `json:"timestamp"`
+	// Notify is false while the rule's cooldown suppresses notifications
+	Notify bool `json:"notify"`
+}
+
+// Alert is a rule currently firing for a device
+type Alert struct {
+	RuleID     string  `json:"ruleId"`
+	RuleName   string  `json:"ruleName"`
+	PsKey      string  `json:"psKey"`
+	DeviceName string  `json:"deviceName,omitempty"`
+	Value      float64 `json:"value"`
+	Unit       string  `json:"unit,omitempty"`
+	Since      int64   `json:"since"` // Unix milliseconds
+}
+
+// ruleState tracks one rule for one device
+type ruleState struct {
+	pendingSince time.Time // when the condition started holding, zero if it doesn't
+	active       bool
+	activeSince  time.Time
+	lastNotified time.Time
+	value        float64
+	unit         string
+	deviceName   string
+}
+
+// Engine evaluates observations against rules and remembers which alerts are active
+type Engine struct {
+	mu     sync.Mutex
+	rules  []Rule
+	states map[string]*ruleState // by rule ID + ps_key
+}
+
+// NewEngine creates an engine with a set of rules
+func NewEngine(rules []Rule) *Engine {
+	e := &Engine{states: map[string]*ruleState{}}
+	e.SetRules(rules)
+	return e
+}
+
+// SetRules replaces the rules. Alert state is kept for rules whose definition didn't
+// change, so saving settings doesn't re-notify every active alert.
+func (e *Engine) SetRules(rules []Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	kept := map[string]bool{}
+	for _, r := range rules {
+		if old := e.rule(r.ID); old != nil && *old == r {
+			kept[r.ID] = true
+		}
+	}
+	for key := range e.states {
+		ruleID, _, _ := strings.Cut(key, "\x00")
+		if !kept[ruleID] {
+			delete(e.states, key)
+		}
+	}
+
+	e.rules = slices.Clone(rules)
+}
+
+// rule returns the rule with an ID. The caller must hold mu.
+func (e *Engine) rule(id string) *Rule {
+	for i := range e.rules {
+		if e.rules[i].ID == id {
+			return &e.rules[i]
+		}
+	}
+	return nil
+}
+
+// Observe evaluates the enabled rules against a poll of a device and returns the alerts
+// that fired or cleared
+func (e *Engine) Observe(obs Observation) []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.UnixMilli(obs.Timestamp)
+	var events []Event
+	for _, rule := range e.rules {
+		if !rule.Enabled || (rule.PsKey != "" && rule.PsKey != obs.PsKey) {
+			continue
+		}
+
+		key := rule.ID + "\x00" + obs.PsKey
+		state := e.states[key]
+		if state == nil {
+			state = &ruleState{}
+			e.states[key] = state
+		}
+		if obs.DeviceName != "" {
+			state.deviceName = obs.DeviceName
+		}
+
+		breached, cleared, ok := evaluate(rule, obs, state)
+		if !ok {
+			// The poll had no reading of the point, nothing to judge
+			continue
+		}
+
+		if event, changed := step(rule, state, breached, cleared, now); changed {
+			event.PsKey = obs.PsKey
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// evaluate checks a rule's condition, recording the value it was judged on
+func evaluate(rule Rule, obs Observation, state *ruleState) (breached, cleared, ok bool) {
+	if rule.Condition == ConditionOffline {
+		state.value, state.unit = 0, ""
+		return !obs.Online, obs.Online, true
+	}
+	if !obs.Online {
+		return false, false, false
+	}
+
+	for _, reading := range obs.Readings {
+		if !rule.matches(reading) {
+			continue
+		}
+
+		state.value, state.unit = reading.Value, reading.Unit
+		if rule.Condition == ConditionBelow {
+			return reading.Value < rule.Threshold, reading.Value >= rule.Threshold+rule.Hysteresis, true
+		}
+		return reading.Value > rule.Threshold, reading.Value <= rule.Threshold-rule.Hysteresis, true
+	}
+	return false, false, false
+}
+
+// step advances a rule's state, returning an event when the alert fires or clears
+func step(rule Rule, state *ruleState, breached, cleared bool, now time.Time) (Event, bool) {
+	if state.active {
+		if !cleared {
+			return Event{}, false
+		}
+		state.active = false
+		state.pendingSince = time.Time{}
+		return newEvent(rule, state, StateResolved, now, state.lastNotified.Equal(state.activeSince)), true
+	}
+
+	if !breached {
+		state.pendingSince = time.Time{}
+		return Event{}, false
+	}
+	if state.pendingSince.IsZero() {
+		state.pendingSince = now
+	}
+	if now.Sub(state.pendingSince) < time.Duration(rule.DelaySeconds)*time.Second {
+		return Event{}, false
+	}
+
+	state.active = true
+	state.activeSince = now
+	notify := state.lastNotified.IsZero() || now.Sub(state.lastNotified) >= time.Duration(rule.CooldownSeconds)*time.Second
+	if notify {
+		state.lastNotified = now
+	}
+	return newEvent(rule, state, StateFiring, now, notify), true
+}
+
+// newEvent describes a change of an alert. Resolutions are only notified when the alert
+// that fired was.
+func newEvent(rule Rule, state *ruleState, eventState EventState, now time.Time, notify bool) Event {
+	return Event{
+		RuleID:     rule.ID,
+		RuleName:   rule.Name,
+		DeviceName: state.deviceName,
+		State:      eventState,
+		Value:      state.value,
+		Unit:       state.unit,
+		Message:    message(rule, state, eventState),
+		Timestamp:  now.UnixMilli(),
+		Notify:     notify,
+	}
+}
+
+// message is the notification text of an event
+func message(rule Rule, state *ruleState, eventState EventState) string {
+	device := state.deviceName
+	if device == "" {
+		device = "Device"
+	}
+
+	if rule.Condition == ConditionOffline {
+		if eventState == StateFiring {
+			return fmt.Sprintf("%s is offline", device)
+		}
+		return fmt.Sprintf("%s is back online", device)
+	}
+
+	value := fmt.Sprintf("%g%s", round(state.value), state.unit)
+	threshold := fmt.Sprintf("%g%s", round(rule.Threshold), state.unit)
+	if eventState == StateResolved {
+		return fmt.Sprintf("%s: back to %s", device, value)
+	}
+	if rule.Condition == ConditionBelow {
+		return fmt.Sprintf("%s: %s is below %s", device, value, threshold)
+	}
+	return fmt.Sprintf("%s: %s is above %s", device, value, threshold)
+}
+
+// round keeps messages readable
+func round(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// Active returns the alerts currently firing
+func (e *Engine) Active() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	alerts := []Alert{}
+	for key, state := range e.states {
+		if !state.active {
+			continue
+		}
+		ruleID, psKey, _ := strings.Cut(key, "\x00")
+		rule := e.rule(ruleID)
+		if rule == nil {
+			continue
+		}
+		alerts = append(alerts, Alert{
+			RuleID:     ruleID,
+			RuleName:   rule.Name,
+			PsKey:      psKey,
+			DeviceName: state.deviceName,
+			Value:      state.value,
+			Unit:       state.unit,
+			Since:      state.activeSince.UnixMilli(),
+		})
+	}
+	slices.SortFunc(alerts, func(x, y Alert) int { return cmp.Compare(x.Since, y.Since) })
+	return alerts
+}
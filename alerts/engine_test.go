@@ -0,0 +1,161 @@
+package alerts
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+)
+
+// base is the time synthetic observations are offset from, in Unix milliseconds
+const base = 1_700_000_000_000
+
+// sample is a synthetic poll: at seconds after base, the point's value, or NaN for a
+// failed poll
+type sample struct {
+	at    int
+	value float64
+}
+
+var offline = math.NaN()
+
+// observe feeds samples of a device to an engine and describes the events raised as
+// "<seconds> <state> <notified>"
+func observe(e *Engine, point string, samples []sample) []string {
+	var got []string
+	for _, s := range samples {
+		obs := Observation{PsKey: "1_14_1_1", DeviceName: "Inverter", Online: !math.IsNaN(s.value), Timestamp: base + int64(s.at)*1000}
+		if obs.Online {
+			obs.Readings = []isolarcloud.Reading{{PsKey: obs.PsKey, Key: point, PointID: 13149, Value: s.value, Unit: "W"}}
+		}
+		for _, ev := range e.Observe(obs) {
+			notified := "notify"
+			if !ev.Notify {
+				notified = "silent"
+			}
+			got = append(got, fmt.Sprintf("%d %s %s", (ev.Timestamp-base)/1000, ev.State, notified))
+		}
+	}
+	return got
+}
+
+func TestEngine(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		samples []sample
+		want    []string
+	}{
+		{
+			name:    "fires below threshold and clears at it",
+			rule:    Rule{Condition: ConditionBelow, Point: "battery_soc", Threshold: 15},
+			samples: []sample{{0, 20}, {60, 14.9}, {120, 10}, {180, 15}},
+			want:    []string{"60 firing notify", "180 resolved notify"},
+		},
+		{
+			name: "above for a delay, e.g. import over 5 kW for 10 minutes",
+			rule: Rule{Condition: ConditionAbove, Point: "grid_import_power", Threshold: 5000, DelaySeconds: 600},
+			samples: []sample{
+				{0, 6000}, {300, 6000}, {420, 4000}, // dips before the delay passes
+				{480, 5500}, {900, 5600}, {1080, 5700}, // held from 480 to 1080
+				{1200, 3000},
+			},
+			want: []string{"1080 firing notify", "1200 resolved notify"},
+		},
+		{
+			name: "hysteresis keeps firing until the value moves back past the band",
+			rule: Rule{Condition: ConditionBelow, Point: "battery_soc", Threshold: 15, Hysteresis: 5},
+			samples: []sample{
+				{0, 14}, {60, 16}, {120, 14}, {180, 19.9}, // hovering doesn't flap
+				{240, 20},            // clears at threshold + hysteresis
+				{300, 18}, {360, 14}, // re-arms and fires again
+			},
+			want: []string{"0 firing notify", "240 resolved notify", "360 firing notify"},
+		},
+		{
+			name: "cooldown suppresses notifications, and their resolutions, until it passes",
+			rule: Rule{Condition: ConditionBelow, Point: "battery_soc", Threshold: 15, CooldownSeconds: 3600},
+			samples: []sample{
+				{0, 10}, {60, 20},
+				{120, 10}, {180, 20}, // within the cooldown
+				{3600, 10}, {3660, 20}, // cooldown over
+			},
+			want: []string{
+				"0 firing notify", "60 resolved notify",
+				"120 firing silent", "180 resolved silent",
+				"3600 firing notify", "3660 resolved notify",
+			},
+		},
+		{
+			name:    "offline after a delay and back online",
+			rule:    Rule{Condition: ConditionOffline, DelaySeconds: 300},
+			samples: []sample{{0, 1}, {60, offline}, {300, offline}, {360, offline}, {420, 1}},
+			want:    []string{"360 firing notify", "420 resolved notify"},
+		},
+		{
+			name:    "failed polls don't judge point rules",
+			rule:    Rule{Condition: ConditionBelow, Point: "battery_soc", Threshold: 15, DelaySeconds: 120},
+			samples: []sample{{0, 10}, {60, offline}, {120, 10}, {180, offline}},
+			want:    []string{"120 firing notify"},
+		},
+		{
+			name:    "matches point IDs as well as catalogue keys",
+			rule:    Rule{Condition: ConditionAbove, Point: "p13149", Threshold: 5000},
+			samples: []sample{{0, 5001}},
+			want:    []string{"0 firing notify"},
+		},
+		{
+			name:    "disabled rules never fire",
+			rule:    Rule{Condition: ConditionBelow, Point: "battery_soc", Threshold: 15, Enabled: false},
+			samples: []sample{{0, 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			rule.ID, rule.Name = "rule", tt.name
+			rule.Enabled = rule.Enabled || tt.want != nil
+			if err := rule.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			point := rule.Point
+			if point == "" || point == "p13149" {
+				point = "grid_import_power"
+			}
+			got := observe(NewEngine([]Rule{rule}), point, tt.samples)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineActiveAndSetRules(t *testing.T) {
+	rule := Rule{ID: "low", Name: "Battery low", Enabled: true, Condition: ConditionBelow, Point: "battery_soc", Threshold: 15}
+	e := NewEngine([]Rule{rule})
+	observe(e, "battery_soc", []sample{{0, 10}})
+
+	active := e.Active()
+	if len(active) != 1 || active[0].RuleID != "low" || active[0].PsKey != "1_14_1_1" || active[0].Value != 10 || active[0].Since != base {
+		t.Fatalf("Active() = %+v", active)
+	}
+
+	// Unchanged rules keep their state, so saving settings doesn't re-notify
+	e.SetRules([]Rule{rule})
+	if got := observe(e, "battery_soc", []sample{{60, 9}}); got != nil {
+		t.Errorf("events after re-saving the rule = %q, want none", got)
+	}
+
+	// A changed rule starts over
+	rule.Threshold = 12
+	e.SetRules([]Rule{rule})
+	if len(e.Active()) != 0 {
+		t.Errorf("Active() after changing the rule = %+v", e.Active())
+	}
+	if got := observe(e, "battery_soc", []sample{{120, 9}}); !slices.Equal(got, []string{"120 firing notify"}) {
+		t.Errorf("events after changing the rule = %q", got)
+	}
+}
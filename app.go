@@ -1,33 +1,67 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"image"
-	"image/color"
 	_ "image/jpeg"
-	"image/png"
-	"io"
-	"math"
-	"net"
+	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
-	stdruntime "runtime"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
+
+	"wails-sungrow-isolarcloud-app/alerts"
+	"wails-sungrow-isolarcloud-app/history"
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+	"wails-sungrow-isolarcloud-app/status"
+	"wails-sungrow-isolarcloud-app/trayicon"
+	"wails-sungrow-isolarcloud-app/webhook"
 )
 
 // App struct
 type App struct {
 	ctx           context.Context
-	credentials   *Credentials
+	headless      bool
+	log           *log.Logger // background and diagnostic messages, stdout unless the CLI redirects them
+	accounts      map[string]*account
+	accountOrder  []string
+	activeID      string
+	plantAccounts map[int]string // account ID each listed plant belongs to
+	accountsMu    sync.Mutex
+	authMu        sync.Mutex // guards pendingLogin and authCancel
+	pendingLogin  *pendingLogin
+	authCancel    context.CancelFunc
 	httpClient    *http.Client
+	limiters      map[string]*isolarcloud.RateLimiter // by appkey
+	limitersMu    sync.Mutex
+	breakers      map[string]*isolarcloud.CircuitBreaker // by gateway URL
+	breakersMu    sync.Mutex
+	settings      Settings
+	settingsMu    sync.Mutex
+	poller        *Poller
+	credStore     CredentialStore
+	credStoreMu   sync.Mutex
+	history       *history.Store
+	metrics       *appMetrics
+	metricsServer *http.Server
+	metricsConfig MetricsSettings // what metricsServer was started with
+	metricsMu     sync.Mutex
+	mqtt          *mqttBridge
+	mqttMu        sync.Mutex
+	alerts        *alerts.Engine
+	statusWatcher *status.Watcher
+	statusJournal *status.Journal
+	webhooks      *webhook.Outbox
+	pointDict     map[int]isolarcloud.PointDictEntry
+	pointDictMu   sync.Mutex
+	trayTitle     string          // last battery status, restored once gateways recover
+	trayState     *trayicon.State // last icon drawn, redrawn when tray settings change
+	trayMu        sync.Mutex
 	TrayTitleChan chan string
 	TrayIconChan  chan []byte
 	BaseIcon      []byte
@@ -45,533 +79,462 @@ type Credentials struct {
 }
 
 // Plant represents a solar plant
-type Plant struct {
-	PsID                 int     `json:"ps_id"`
-	PsName               string  `json:"ps_name"`
-	Description          *string `json:"description"`
-	PsType               int     `json:"ps_type"`
-	OnlineStatus         int     `json:"online_status"`
-	ValidFlag            int     `json:"valid_flag"`
-	GridConnectionStatus int     `json:"grid_connection_status"`
-	InstallDate          string  `json:"install_date"`
-	PsLocation           string  `json:"ps_location"`
-	Latitude             float64 `json:"latitude"`
-	Longitude            float64 `json:"longitude"`
-	PsFaultStatus        int     `json:"ps_fault_status"`
-	ConnectType          int     `json:"connect_type"`
-	UpdateTime           string  `json:"update_time"`
-	PsCurrentTimeZone    string  `json:"ps_current_time_zone"`
-	GridConnectionTime   *string `json:"grid_connection_time"`
-	BuildStatus          int     `json:"build_status"`
-	TodayEnergy          string  `json:"today_energy,omitempty"`
-}
+type Plant = isolarcloud.Plant
 
 // PlantDevice represents a device in a plant
-type PlantDevice struct {
-	UUID               int    `json:"uuid"`
-	PsKey              string `json:"ps_key"`
-	DeviceSN           string `json:"device_sn"`
-	DeviceName         string `json:"device_name"`
-	DeviceType         int    `json:"device_type"`
-	TypeName           string `json:"type_name"`
-	DeviceModelID      int    `json:"device_model_id"`
-	DeviceModelCode    string `json:"device_model_code"`
-	DevFaultStatus     int    `json:"dev_fault_status"`
-	DevStatus          string `json:"dev_status"`
-	ClaimState         int    `json:"claim_state"`
-	DeviceCode         int    `json:"device_code"`
-	ChnnlID            int    `json:"chnnl_id"`
-	CommunicationDevSN string `json:"communication_dev_sn"`
-	PsID               int    `json:"ps_id"`
-}
-
-// ApiResponse wraps API responses
-type ApiResponse struct {
-	ReqSerialNum string          `json:"req_serial_num,omitempty"`
-	ResultCode   string          `json:"result_code"`
-	ResultMsg    string          `json:"result_msg"`
-	ResultData   json.RawMessage `json:"result_data"`
-}
-
-// LoginResultData contains OAuth token data
-type LoginResultData struct {
-	AccessToken  string   `json:"access_token"`
-	TokenType    string   `json:"token_type"`
-	RefreshToken string   `json:"refresh_token"`
-	ExpiresIn    int      `json:"expires_in"`
-	AuthPsList   []string `json:"auth_ps_list"`
-	AuthUser     int      `json:"auth_user"`
-}
+type PlantDevice = isolarcloud.PlantDevice
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{
+	a := &App{
+		log: log.New(os.Stdout, "", 0),
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		settings:      defaultSettings(),
+		accounts:      map[string]*account{},
+		plantAccounts: map[int]string{},
+		limiters:      map[string]*isolarcloud.RateLimiter{},
+		breakers:      map[string]*isolarcloud.CircuitBreaker{},
+		pointDict:     map[int]isolarcloud.PointDictEntry{},
+		alerts:        alerts.NewEngine(defaultAlertRules()),
+		statusWatcher: status.NewWatcher(),
 		TrayTitleChan: make(chan string, 10),
 		TrayIconChan:  make(chan []byte, 10),
 	}
+	a.poller = newPoller(a.pollDevice, a.handlePollResult, a.log)
+	a.metrics = newAppMetrics(a.tokenExpiry)
+	return a
 }
 
 // startup is called when the app starts
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-	a.loadCredentials()
+	a.loadAccounts()
+	a.loadSettings()
+	a.openHistory(ctx)
+	a.openStatusJournal(ctx)
+	a.openWebhookOutbox(ctx)
+	a.poller.Start(ctx, a.GetSettings().Poller)
+	a.watchStatus(ctx)
+	a.applyMetricsSettings(ctx, a.GetSettings().Metrics)
+	a.applyMQTTSettings(ctx, a.GetSettings().MQTT)
 }
 
-// GetStoredCredentials returns stored credentials
-func (a *App) GetStoredCredentials() *Credentials {
-	if a.credentials == nil {
-		a.loadCredentials()
-	}
-	return a.credentials
+// shutdown stops the exporter and MQTT bridge, marking the app offline
+func (a *App) shutdown(ctx context.Context) {
+	a.applyMQTTSettings(ctx, MQTTSettings{})
+	a.applyMetricsSettings(ctx, MetricsSettings{})
 }
 
-// Authenticate handles OAuth flow
-func (a *App) Authenticate(creds Credentials) (map[string]interface{}, error) {
-	// Store credentials
-	a.credentials = &creds
-
-	// Find an available port
-	port := 0
-	var server *http.Server
-	var listener net.Listener
-
-	for p := 8080; p <= 8090; p++ {
-		addr := fmt.Sprintf(":%d", p)
-		l, err := net.Listen("tcp", addr)
-		if err == nil {
-			port = p
-			listener = l
-			server = &http.Server{}
-			break
-		}
-	}
-
-	if port == 0 {
-		return nil, fmt.Errorf("no available ports found (tried 8080-8090)")
-	}
-
-	redirectURL := fmt.Sprintf("http://localhost:%d/callback", port)
-
-	// Build auth URL
-	authURL, err := url.Parse(creds.AuthURL)
-	if err != nil {
-		listener.Close()
-		return nil, fmt.Errorf("invalid auth URL: %w", err)
-	}
-
-	query := authURL.Query()
-	query.Set("redirectUrl", redirectURL)
-	authURL.RawQuery = query.Encode()
-
-	// Create a channel to receive the authorization code
-	codeChan := make(chan string, 1)
-	errChan := make(chan error, 1)
-
-	// Setup HTTP handler
-	mux := http.NewServeMux()
-	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-		code := r.URL.Query().Get("code")
-		if code == "" {
-			errChan <- fmt.Errorf("no authorization code received")
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("Authentication failed: no code received"))
-			return
-		}
-
-		// Send success page
-		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(`
-			<html>
-			<head><title>Authentication Successful</title></head>
-			<body style="font-family: sans-serif; display: flex; align-items: center; justify-content: center; height: 100vh; margin: 0; background: linear-gradient(135deg, #0f172a 0%, #1e293b 100%); color: white;">
-				<div style="text-align: center;">
-					<h1 style="color: #10b981;">✓ Authentication Successful</h1>
-					<p>You can close this window and return to the app.</p>
-				</div>
-			</body>
-			</html>
-		`))
-
-		codeChan <- code
-	})
-
-	server.Handler = mux
-
-	// Start server in background
-	go func() {
-		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
-			errChan <- err
-		}
-	}()
-
-	// Open browser for OAuth
-	runtime.BrowserOpenURL(a.ctx, authURL.String())
-
-	// Wait for code or error (with timeout)
-	select {
-	case code := <-codeChan:
-		// Shutdown server
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		server.Shutdown(ctx)
-
-		// Exchange code for tokens
-		return a.exchangeCodeForTokens(code, creds, redirectURL)
-
-	case err := <-errChan:
-		server.Shutdown(context.Background())
-		return nil, err
-
-	case <-time.After(5 * time.Minute):
-		server.Shutdown(context.Background())
-		return nil, fmt.Errorf("authentication timeout")
+// emit sends an event to the frontend
+func (a *App) emit(name string, data ...interface{}) {
+	if a.ctx == nil || a.headless {
+		return
 	}
+	runtime.EventsEmit(a.ctx, name, data...)
 }
 
-// exchangeCodeForTokens exchanges authorization code for access tokens
-func (a *App) exchangeCodeForTokens(code string, creds Credentials, redirectURL string) (map[string]interface{}, error) {
-	gatewayURL := creds.GatewayURL
-	if gatewayURL == "" {
-		gatewayURL = "https://augateway.isolarcloud.com"
+// GetStoredCredentials returns the active account's credentials
+func (a *App) GetStoredCredentials() *Credentials {
+	if acct := a.activeAccount(); acct != nil {
+		return acct.Credentials()
 	}
+	return nil
+}
 
-	tokenURL := fmt.Sprintf("%s/openapi/apiManage/token", gatewayURL)
-
-	reqBody := map[string]interface{}{
-		"appkey":       creds.AppKey,
-		"grant_type":   "authorization_code",
-		"code":         code,
-		"redirect_uri": redirectURL,
-	}
+// Authenticate handles OAuth flow
+func (a *App) Authenticate(creds Credentials) (map[string]interface{}, error) {
+	// Store credentials on the active account
+	creds = creds.withGatewayDefaults()
+	acct := a.loginAccount()
+	acct.setCredentials(&creds)
 
-	jsonData, err := json.Marshal(reqBody)
+	state, err := newOAuthState()
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", tokenURL, bytes.NewBuffer(jsonData))
+	callback, err := startCallbackServer(state)
 	if err != nil {
 		return nil, err
 	}
+	defer callback.Close()
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-access-key", creds.SecretKey)
-
-	resp, err := a.httpClient.Do(req)
+	authURL, err := authorizationURL(creds.AuthURL, callback.redirectURL, state)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	// Wait until the browser comes back, the timeout passes or CancelAuthentication is called
+	timeout := time.Duration(a.GetSettings().Auth.CallbackTimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	a.authMu.Lock()
+	if a.authCancel != nil {
+		a.authCancel()
 	}
+	a.authCancel = cancel
+	a.authMu.Unlock()
 
-	var apiResp ApiResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
+	// Open browser for OAuth
+	runtime.BrowserOpenURL(a.ctx, authURL)
+
+	code, err := callback.Wait(ctx)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return nil, fmt.Errorf("authentication timeout after %s", timeout)
+	case errors.Is(err, context.Canceled):
+		return nil, errAuthCancelled
+	case err != nil:
 		return nil, err
 	}
 
-	if apiResp.ResultCode != "1" {
-		return nil, fmt.Errorf("authentication failed: %s", apiResp.ResultMsg)
-	}
+	// Exchange code for tokens
+	return a.exchangeCodeForTokens(acct, code, creds, callback.redirectURL)
+}
 
-	var loginData LoginResultData
-	if err := json.Unmarshal(apiResp.ResultData, &loginData); err != nil {
-		return nil, err
+// exchangeCodeForTokens exchanges authorization code for access tokens
+func (a *App) exchangeCodeForTokens(acct *account, code string, creds Credentials, redirectURL string) (map[string]interface{}, error) {
+	client := isolarcloud.NewClient(creds.AppKey, creds.SecretKey,
+		isolarcloud.WithBaseURL(creds.GatewayURL),
+		isolarcloud.WithHTTPClient(a.httpClient),
+		isolarcloud.WithRateLimiter(a.rateLimiter(creds.AppKey)),
+		isolarcloud.WithCircuitBreaker(a.gatewayBreaker(creds.GatewayURL)),
+	)
+
+	loginData, err := client.ExchangeCode(context.Background(), code, redirectURL)
+	if err != nil {
+		return nil, fmt.Errorf("authentication failed: %w", err)
 	}
 
-	// Calculate expiry time
-	expiry := time.Now().Add(time.Duration(loginData.ExpiresIn) * time.Second).Unix()
-
 	// Update credentials with tokens
-	a.credentials.AccessToken = loginData.AccessToken
-	a.credentials.RefreshToken = loginData.RefreshToken
-	a.credentials.TokenExpiry = expiry * 1000 // Convert to milliseconds
+	creds.AccessToken = loginData.AccessToken
+	creds.RefreshToken = loginData.RefreshToken
+	creds.TokenExpiry = loginData.ExpiryMillis()
+	acct.setCredentials(&creds)
 
 	// Save credentials
-	if err := a.saveCredentials(); err != nil {
+	if err := acct.save(); err != nil {
 		return nil, err
 	}
 
 	return map[string]interface{}{
 		"authenticated": true,
-		"tokenExpiry":   a.credentials.TokenExpiry,
+		"accountId":     acct.id,
+		"tokenExpiry":   creds.TokenExpiry,
 	}, nil
 }
 
-// GetPlantList retrieves list of solar plants
-func (a *App) GetPlantList() ([]Plant, error) {
-	if a.credentials == nil || a.credentials.AccessToken == "" {
-		return nil, fmt.Errorf("not authenticated")
-	}
+// apiRequestsPerSecond and apiRequestBurst limit the requests sent for each appkey, so
+// views loading at once don't exhaust the gateway's per-appkey quota
+const (
+	apiRequestsPerSecond = 2
+	apiRequestBurst      = 10
+)
 
-	gatewayURL := a.credentials.GatewayURL
-	if gatewayURL == "" {
-		gatewayURL = "https://augateway.isolarcloud.com"
-	}
+// realTimeBatchWindow is how long real-time requests wait to be merged with others
+const realTimeBatchWindow = 50 * time.Millisecond
 
-	apiURL := fmt.Sprintf("%s/openapi/platform/queryPowerStationList", gatewayURL)
-	fmt.Printf("GetPlantList: Calling %s\n", apiURL)
+// rateLimiter returns the limiter shared by every client using an appkey
+func (a *App) rateLimiter(appKey string) *isolarcloud.RateLimiter {
+	a.limitersMu.Lock()
+	defer a.limitersMu.Unlock()
 
-	reqBody := map[string]interface{}{
-		"appkey": a.credentials.AppKey,
-		"page":   1,
-		"size":   50,
+	l, ok := a.limiters[appKey]
+	if !ok {
+		l = isolarcloud.NewRateLimiter(apiRequestsPerSecond, apiRequestBurst)
+		a.limiters[appKey] = l
 	}
+	return l
+}
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, err
+// apiClient returns the iSolarCloud client of the active account
+func (a *App) apiClient() (*isolarcloud.Client, error) {
+	acct := a.activeAccount()
+	if acct == nil {
+		return nil, isolarcloud.ErrNotAuthenticated
 	}
-	fmt.Printf("GetPlantList: Request body: %s\n", string(jsonData))
+	return acct.apiClient()
+}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+// GetPlantList retrieves the solar plants of every signed in account
+func (a *App) GetPlantList() ([]Plant, error) {
+	plants, err := a.listPlantsPerAccount(context.Background(), func(ctx context.Context, client *isolarcloud.Client) ([]Plant, error) {
+		a.log.Printf("GetPlantList: Calling %s\n", client.BaseURL())
+		return client.PlantList(ctx)
+	})
 	if err != nil {
+		a.log.Printf("GetPlantList: %v\n", err)
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+a.credentials.AccessToken)
-	req.Header.Set("x-access-key", a.credentials.SecretKey)
+	a.log.Printf("GetPlantList: Successfully loaded %d plants\n", len(plants))
+	return plants, nil
+}
 
-	resp, err := a.httpClient.Do(req)
+// GetDeviceList retrieves devices for a plant
+func (a *App) GetDeviceList(psID int) ([]PlantDevice, error) {
+	client, err := a.clientForPlant(psID)
 	if err != nil {
-		fmt.Printf("GetPlantList: HTTP error: %v\n", err)
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	devices, err := client.DeviceList(context.Background(), psID)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Printf("GetPlantList: Response status: %d, body: %s\n", resp.StatusCode, string(body))
-
-	var apiResp ApiResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		fmt.Printf("GetPlantList: JSON unmarshal error: %v\n", err)
-		return nil, err
-	}
 
-	if apiResp.ResultCode != "1" {
-		fmt.Printf("GetPlantList: API error code: %s, message: %s\n", apiResp.ResultCode, apiResp.ResultMsg)
-		return nil, fmt.Errorf("API error: %s", apiResp.ResultMsg)
-	}
+	a.observeDevices(devices)
+	return devices, nil
+}
 
-	var result struct {
-		PageList []Plant `json:"pageList"`
-	}
-	if err := json.Unmarshal(apiResp.ResultData, &result); err != nil {
-		fmt.Printf("GetPlantList: Result data unmarshal error: %v\n", err)
+// GetPlantListPage retrieves a single page of solar plants for lazy loading. With several
+// accounts the page holds that page of each, and RowCount is their combined total.
+func (a *App) GetPlantListPage(page int, size int) (*isolarcloud.PlantPage, error) {
+	result := &isolarcloud.PlantPage{Page: page, Size: size}
+	plants, err := a.listPlantsPerAccount(context.Background(), func(ctx context.Context, client *isolarcloud.Client) ([]Plant, error) {
+		accountPage, err := client.PlantListPage(ctx, page, size)
+		if err != nil {
+			return nil, err
+		}
+		result.RowCount += accountPage.RowCount
+		return accountPage.PageList, nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	fmt.Printf("GetPlantList: Successfully loaded %d plants\n", len(result.PageList))
-	return result.PageList, nil
+	result.PageList = plants
+	return result, nil
 }
 
-// GetDeviceList retrieves devices for a plant
-func (a *App) GetDeviceList(psID int) ([]PlantDevice, error) {
-	if a.credentials == nil || a.credentials.AccessToken == "" {
-		return nil, fmt.Errorf("not authenticated")
-	}
-
-	gatewayURL := a.credentials.GatewayURL
-	if gatewayURL == "" {
-		gatewayURL = "https://augateway.isolarcloud.com"
-	}
-
-	apiURL := fmt.Sprintf("%s/openapi/platform/getDeviceListByPsId", gatewayURL)
-
-	reqBody := map[string]interface{}{
-		"appkey": a.credentials.AppKey,
-		"ps_id":  fmt.Sprintf("%d", psID),
-		"page":   1,
-		"size":   50,
-	}
-	jsonData, err := json.Marshal(reqBody)
+// GetDeviceListPage retrieves a single page of devices for a plant
+func (a *App) GetDeviceListPage(psID int, page int, size int) (*isolarcloud.DevicePage, error) {
+	client, err := a.clientForPlant(psID)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	devicePage, err := client.DeviceListPage(context.Background(), psID, page, size)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+a.credentials.AccessToken)
-	req.Header.Set("x-access-key", a.credentials.SecretKey)
-
-	resp, err := a.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+	a.observeDevices(devicePage.PageList)
+	return devicePage, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// GetPlantAlarms retrieves a page of a plant's device faults and alarms, newest first.
+// from and to are Unix milliseconds, 0 leaving that end of the range open.
+func (a *App) GetPlantAlarms(psID int, from int64, to int64, activeOnly bool, page int, size int) (*isolarcloud.AlarmPage, error) {
+	client, err := a.clientForPlant(psID)
 	if err != nil {
 		return nil, err
 	}
 
-	var apiResp ApiResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, err
-	}
-
-	if apiResp.ResultCode != "1" {
-		return nil, fmt.Errorf("API error: %s", apiResp.ResultMsg)
-	}
+	return client.AlarmListPage(context.Background(), psID, alarmQuery(from, to, activeOnly), page, size)
+}
 
-	var result struct {
-		PageList []PlantDevice `json:"pageList"`
+// alarmQuery builds an alarm query from Unix millisecond bounds in local time
+func alarmQuery(from, to int64, activeOnly bool) isolarcloud.AlarmQuery {
+	q := isolarcloud.AlarmQuery{ActiveOnly: activeOnly}
+	if from != 0 {
+		q.From = time.UnixMilli(from)
 	}
-	if err := json.Unmarshal(apiResp.ResultData, &result); err != nil {
-		return nil, err
+	if to != 0 {
+		q.To = time.UnixMilli(to)
 	}
-
-	return result.PageList, nil
+	return q
 }
 
 // GetDevicePointData retrieves real-time data points for a device
 func (a *App) GetDevicePointData(deviceType int, psKey string, pointIDs []int) ([]map[string]interface{}, error) {
-	if a.credentials == nil || a.credentials.AccessToken == "" {
-		return nil, fmt.Errorf("not authenticated")
-	}
-
-	gatewayURL := a.credentials.GatewayURL
-	if gatewayURL == "" {
-		gatewayURL = "https://augateway.isolarcloud.com"
-	}
-
-	apiURL := fmt.Sprintf("%s/openapi/platform/getDeviceRealTimeData", gatewayURL)
-
-	// Convert point IDs to strings
-	pointIDStrs := make([]string, len(pointIDs))
-	for i, id := range pointIDs {
-		pointIDStrs[i] = fmt.Sprintf("%d", id)
+	client, err := a.clientForPsKey(psKey)
+	if err != nil {
+		return nil, err
 	}
 
-	reqBody := map[string]interface{}{
-		"appkey":            a.credentials.AppKey,
-		"device_type":       deviceType,
-		"ps_key_list":       []string{psKey},
-		"point_id_list":     pointIDStrs,
-		"is_get_point_dict": "1",
-	}
+	return a.deviceRealTime(context.Background(), client, deviceType, []string{psKey}, pointIDs)
+}
 
-	jsonData, err := json.Marshal(reqBody)
+// GetDeviceReadings retrieves real-time points of a device as typed readings, scaled to
+// their catalogue unit where the point is well known
+func (a *App) GetDeviceReadings(deviceType int, psKey string, pointIDs []int) ([]isolarcloud.Reading, error) {
+	devicePoints, err := a.GetDevicePointData(deviceType, psKey, pointIDs)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
+	readings := []isolarcloud.Reading{}
+	for _, devicePoint := range devicePoints {
+		readings = append(readings, isolarcloud.ParseReadings(devicePoint, a.pointDef)...)
 	}
+	return readings, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+a.credentials.AccessToken)
-	req.Header.Set("x-access-key", a.credentials.SecretKey)
+// GetPointCatalogue returns the well known points, so consumers can find them by key
+func (a *App) GetPointCatalogue() []isolarcloud.PointDef {
+	return isolarcloud.Catalogue()
+}
 
-	resp, err := a.httpClient.Do(req)
+// deviceRealTime fetches real-time points, remembering the point names and units returned with them
+func (a *App) deviceRealTime(ctx context.Context, client *isolarcloud.Client, deviceType int, psKeys []string, pointIDs []int) ([]map[string]interface{}, error) {
+	data, err := client.DeviceRealTime(ctx, deviceType, psKeys, pointIDs)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+	a.rememberPointDict(data.PointDict)
+	return data.DevicePoints, nil
+}
 
-	var apiResp ApiResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, err
+// rememberPointDict caches point names and units for pointDef
+func (a *App) rememberPointDict(entries []isolarcloud.PointDictEntry) {
+	a.pointDictMu.Lock()
+	defer a.pointDictMu.Unlock()
+	for _, entry := range entries {
+		a.pointDict[entry.PointID] = entry
 	}
+}
 
-	if apiResp.ResultCode != "1" {
-		return nil, fmt.Errorf("API error: %s", apiResp.ResultMsg)
-	}
+// realTimeWorkers is how many real-time requests a batch runs at once for each account
+const realTimeWorkers = 4
 
-	var result struct {
-		DevicePointList []struct {
-			DevicePoint map[string]interface{} `json:"device_point"`
-		} `json:"device_point_list"`
-	}
-	if err := json.Unmarshal(apiResp.ResultData, &result); err != nil {
-		return nil, err
+// DevicePointResult is the data of one device from GetDevicePointDataBatch
+type DevicePointResult struct {
+	Points   map[string]interface{} `json:"points,omitempty"`
+	Readings []isolarcloud.Reading  `json:"readings,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+	err      error
+}
+
+// GetDevicePointDataBatch retrieves real-time data points for many devices at once,
+// keyed by ps_key. A device that couldn't be read has its own error and doesn't fail the rest.
+func (a *App) GetDevicePointDataBatch(requests []isolarcloud.DeviceRealTimeRequest) (map[string]DevicePointResult, error) {
+	results := map[string]DevicePointResult{}
+
+	// Each account's devices go through that account's client
+	perClient := map[*isolarcloud.Client][]isolarcloud.DeviceRealTimeRequest{}
+	for _, req := range requests {
+		if len(req.PointIDs) == 0 {
+			return nil, fmt.Errorf("at least one point ID is required for device type %d", req.DeviceType)
+		}
+		for _, psKey := range req.PsKeys {
+			client, err := a.clientForPsKey(psKey)
+			if err != nil {
+				results[psKey] = DevicePointResult{Error: err.Error(), err: err}
+				continue
+			}
+			perClient[client] = appendDeviceRequest(perClient[client], req.DeviceType, psKey, req.PointIDs)
+		}
 	}
 
-	// Extract device points
-	devicePoints := make([]map[string]interface{}, len(result.DevicePointList))
-	for i, item := range result.DevicePointList {
-		devicePoints[i] = item.DevicePoint
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for client, clientRequests := range perClient {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			batch := client.DeviceRealTimeBatch(context.Background(), clientRequests, realTimeWorkers)
+			a.rememberPointDict(batch.PointDict)
+
+			mu.Lock()
+			defer mu.Unlock()
+			for psKey, device := range batch.Devices {
+				result := DevicePointResult{Points: device.Points, err: device.Err}
+				if device.Err != nil {
+					result.Error = device.Err.Error()
+				} else {
+					result.Readings = isolarcloud.ParseReadings(device.Points, a.pointDef)
+				}
+				results[psKey] = result
+			}
+		}()
 	}
+	wg.Wait()
 
-	return devicePoints, nil
+	return results, nil
 }
 
-// Logout clears stored credentials
-func (a *App) Logout() error {
-	a.credentials = nil
-	return a.saveCredentials()
+// appendDeviceRequest adds a device to the request for its device type and points
+func appendDeviceRequest(requests []isolarcloud.DeviceRealTimeRequest, deviceType int, psKey string, pointIDs []int) []isolarcloud.DeviceRealTimeRequest {
+	for i, req := range requests {
+		if req.DeviceType == deviceType && slices.Equal(req.PointIDs, pointIDs) {
+			requests[i].PsKeys = append(req.PsKeys, psKey)
+			return requests
+		}
+	}
+	return append(requests, isolarcloud.DeviceRealTimeRequest{DeviceType: deviceType, PsKeys: []string{psKey}, PointIDs: pointIDs})
 }
 
-// loadCredentials loads credentials from file
-func (a *App) loadCredentials() {
-	configDir, err := os.UserConfigDir()
-	if err != nil {
-		return
+// pointDef returns the name and unit of a point seen in a point dictionary
+func (a *App) pointDef(pointID int) (isolarcloud.PointDictEntry, bool) {
+	a.pointDictMu.Lock()
+	defer a.pointDictMu.Unlock()
+	entry, ok := a.pointDict[pointID]
+	return entry, ok
+}
+
+// Logout clears the active account's credentials
+func (a *App) Logout() error {
+	acct := a.activeAccount()
+	if acct == nil {
+		return nil
 	}
+	acct.setCredentials(nil)
+	return acct.save()
+}
 
-	appDir := filepath.Join(configDir, "SungrowMonitor")
-	credFile := filepath.Join(appDir, "credentials.json")
+// credentialsKey is the CredentialStore key single-account versions saved the credentials under
+const credentialsKey = "credentials"
 
-	data, err := os.ReadFile(credFile)
-	if err != nil {
-		return
+// credentialStore returns the store used for saving credentials
+func (a *App) credentialStore() (CredentialStore, error) {
+	a.credStoreMu.Lock()
+	defer a.credStoreMu.Unlock()
+	if a.credStore != nil {
+		return a.credStore, nil
 	}
 
-	var creds Credentials
-	if err := json.Unmarshal(data, &creds); err != nil {
-		return
+	appDir, err := appConfigDir()
+	if err != nil {
+		return nil, err
 	}
 
-	a.credentials = &creds
+	a.credStore = newCredentialStore(appDir, a.log)
+	return a.credStore, nil
 }
 
-// saveCredentials saves credentials to file
-func (a *App) saveCredentials() error {
-	configDir, err := os.UserConfigDir()
+// migratePlaintextCredentials moves a credentials.json written by older versions into
+// the credential store and removes it. The file is kept if the store rejects it.
+func (a *App) migratePlaintextCredentials(store CredentialStore) ([]byte, error) {
+	appDir, err := appConfigDir()
 	if err != nil {
-		return err
-	}
-
-	appDir := filepath.Join(configDir, "SungrowMonitor")
-	if err := os.MkdirAll(appDir, 0755); err != nil {
-		return err
+		return nil, err
 	}
 
 	credFile := filepath.Join(appDir, "credentials.json")
+	data, err := os.ReadFile(credFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errSecretNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	if a.credentials == nil {
-		// Delete file if credentials are nil
-		os.Remove(credFile)
-		return nil
+	if err := store.Save(credentialsKey, data); err != nil {
+		a.log.Printf("migratePlaintextCredentials: cannot move credentials to %s, keeping %s: %v\n", store.Name(), credFile, err)
+		return data, nil
 	}
 
-	data, err := json.MarshalIndent(a.credentials, "", "  ")
-	if err != nil {
-		return err
+	if err := os.Remove(credFile); err != nil {
+		return nil, err
 	}
 
-	return os.WriteFile(credFile, data, 0600)
+	a.log.Printf("migratePlaintextCredentials: moved %s to %s\n", credFile, store.Name())
+	return data, nil
 }
 
 // UpdateTrayTitle updates the system tray title/tooltip
@@ -585,115 +548,5 @@ func (a *App) UpdateTrayTitle(title string) {
 
 // UpdateTrayStatus updates the system tray icon with battery percentage and title
 func (a *App) UpdateTrayStatus(percentage int, title string) {
-	fmt.Printf("UpdateTrayStatus called: %d%%, %s\n", percentage, title)
-
-	// Update Title
-	a.UpdateTrayTitle(title)
-
-	// Update Icon
-	iconBytes, err := a.generateIconWithBadge(percentage)
-	if err != nil {
-		fmt.Printf("Failed to generate icon: %v\n", err)
-		return
-	}
-	fmt.Printf("Generated icon bytes: %d\n", len(iconBytes))
-
-	select {
-	case a.TrayIconChan <- iconBytes:
-		fmt.Println("Sent icon to TrayIconChan")
-	default:
-		fmt.Println("TrayIconChan blocked/full")
-	}
-}
-
-func (a *App) generateIconWithBadge(percentage int) ([]byte, error) {
-	width := 32
-	height := 32
-	rgba := image.NewRGBA(image.Rect(0, 0, width, height))
-
-	// Colors
-	bgColor := color.RGBA{80, 80, 80, 255} // Dark Gray background for empty part
-	var fgColor color.RGBA
-
-	if percentage <= 20 {
-		fgColor = color.RGBA{220, 38, 38, 255} // Red
-	} else if percentage <= 50 {
-		fgColor = color.RGBA{234, 179, 8, 255} // Yellow/Orange
-	} else {
-		fgColor = color.RGBA{22, 163, 74, 255} // Green
-	}
-
-	// Geometry
-	cx := float64(width) / 2
-	cy := float64(height) / 2
-	r := 15.0 // Radius
-
-	// Angle limit (percentage 0..100 -> 0..2*Pi)
-	limit := (float64(percentage) / 100.0) * 2 * math.Pi
-
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			dx := float64(x) - cx + 0.5 // +0.5 for pixel center
-			dy := float64(y) - cy + 0.5
-			dist := math.Sqrt(dx*dx + dy*dy)
-
-			if dist <= r {
-				// Inside circle
-				// Calculate angle from Top (-Pi/2) Clockwise
-				angle := math.Atan2(dy, dx) // -Pi to Pi
-
-				// Standard Atan2: Right=0, Down=PI/2, Left=PI, Top=-PI/2
-				// We want Top=0, Right=PI/2, Down=PI, Left=3PI/2
-
-				angle += math.Pi / 2
-				if angle < 0 {
-					angle += 2 * math.Pi
-				}
-
-				if angle <= limit {
-					rgba.Set(x, y, fgColor)
-				} else {
-					rgba.Set(x, y, bgColor)
-				}
-			}
-		}
-	}
-
-	buf := new(bytes.Buffer)
-	err := png.Encode(buf, rgba)
-	if err != nil {
-		return nil, err
-	}
-
-	// Windows requires usage of ICO format for system tray
-	if stdruntime.GOOS == "windows" {
-		return a.convertToIco(buf.Bytes())
-	}
-
-	// macOS and Linux generally prefer PNG
-	return buf.Bytes(), nil
-}
-
-func (a *App) convertToIco(pngData []byte) ([]byte, error) {
-	buf := new(bytes.Buffer)
-
-	// ICONDIR header
-	binary.Write(buf, binary.LittleEndian, uint16(0)) // Reserved
-	binary.Write(buf, binary.LittleEndian, uint16(1)) // Type 1 = Icon
-	binary.Write(buf, binary.LittleEndian, uint16(1)) // Count = 1
-
-	// ICONDIRENTRY
-	buf.WriteByte(32)                                            // Width
-	buf.WriteByte(32)                                            // Height
-	buf.WriteByte(0)                                             // ColorCount (0 for >= 8bpp)
-	buf.WriteByte(0)                                             // Reserved
-	binary.Write(buf, binary.LittleEndian, uint16(1))            // Planes
-	binary.Write(buf, binary.LittleEndian, uint16(32))           // BitCount
-	binary.Write(buf, binary.LittleEndian, uint32(len(pngData))) // SizeInBytes
-	binary.Write(buf, binary.LittleEndian, uint32(22))           // Offset (6 + 16)
-
-	// PNG Data
-	buf.Write(pngData)
-
-	return buf.Bytes(), nil
+	a.updateTray(trayicon.State{Percentage: percentage, Flow: trayicon.FlowIdle}, title)
 }
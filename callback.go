@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"time"
+)
+
+// errAuthCancelled is returned by Authenticate when CancelAuthentication is called
+var errAuthCancelled = errors.New("authentication cancelled")
+
+// AuthSettings controls the browser sign in flow
+type AuthSettings struct {
+	CallbackTimeoutSeconds int `json:"callbackTimeoutSeconds"`
+}
+
+// withDefaults fills in unset or invalid values
+func (s AuthSettings) withDefaults() AuthSettings {
+	if s.CallbackTimeoutSeconds < 30 {
+		s.CallbackTimeoutSeconds = defaultSettings().Auth.CallbackTimeoutSeconds
+	}
+	return s
+}
+
+// newOAuthState returns a random value tying the callback to the login that started it
+func newOAuthState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// callbackResult is the outcome of the OAuth redirect
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// callbackServer receives the OAuth redirect on loopback
+type callbackServer struct {
+	port        int
+	state       string
+	redirectURL string
+	servers     []*http.Server
+	result      chan callbackResult
+}
+
+// startCallbackServer listens on the first free port from 8080 to 8090, on IPv4 loopback
+// and, when available, IPv6 loopback so "localhost" works whichever the browser picks
+func startCallbackServer(state string) (*callbackServer, error) {
+	s := &callbackServer{
+		state:  state,
+		result: make(chan callbackResult, 1),
+	}
+
+	var listeners []net.Listener
+	for p := 8080; p <= 8090; p++ {
+		l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", p))
+		if err != nil {
+			continue
+		}
+		s.port = p
+		listeners = append(listeners, l)
+		if l6, err := net.Listen("tcp", fmt.Sprintf("[::1]:%d", p)); err == nil {
+			listeners = append(listeners, l6)
+		}
+		break
+	}
+
+	if s.port == 0 {
+		return nil, fmt.Errorf("no available ports found (tried 8080-8090)")
+	}
+
+	s.redirectURL = fmt.Sprintf("http://localhost:%d/callback", s.port)
+	for _, l := range listeners {
+		server := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+		s.servers = append(s.servers, server)
+		go func() {
+			if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.finish(callbackResult{err: err})
+			}
+		}()
+	}
+
+	return s, nil
+}
+
+// Wait returns the authorization code, or an error if the provider refused, the server
+// failed or ctx ended first
+func (s *callbackServer) Wait(ctx context.Context) (string, error) {
+	select {
+	case r := <-s.result:
+		return r.code, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Close stops the servers
+func (s *callbackServer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, server := range s.servers {
+		server.Shutdown(ctx)
+	}
+}
+
+// finish records the first result, later ones are dropped
+func (s *callbackServer) finish(r callbackResult) {
+	select {
+	case s.result <- r:
+	default:
+	}
+}
+
+// ServeHTTP handles the redirect. Requests from other hosts, for other host names (DNS
+// rebinding) or without the login's state are rejected without ending the login.
+func (s *callbackServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !isLoopbackAddr(r.RemoteAddr) || !s.validHost(r.Host) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if r.URL.Path != "/callback" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(s.state)) != 1 {
+		writeCallbackPage(w, http.StatusBadRequest, false, "Authentication Failed",
+			"This sign in link doesn't match the one the app is waiting for. Start again from the app.")
+		return
+	}
+
+	if reason := query.Get("error"); reason != "" {
+		message := reason
+		if description := query.Get("error_description"); description != "" {
+			message = fmt.Sprintf("%s: %s", reason, description)
+		}
+		writeCallbackPage(w, http.StatusBadRequest, false, "Authentication Failed", "Sungrow refused the sign in ("+message+"). You can close this window and try again from the app.")
+		s.finish(callbackResult{err: fmt.Errorf("authorization was refused: %s", message)})
+		return
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		writeCallbackPage(w, http.StatusBadRequest, false, "Authentication Failed", "No authorization code was received. You can close this window and try again from the app.")
+		s.finish(callbackResult{err: fmt.Errorf("no authorization code received")})
+		return
+	}
+
+	writeCallbackPage(w, http.StatusOK, true, "Authentication Successful", "You can close this window and return to the app.")
+	s.finish(callbackResult{code: code})
+}
+
+// validHost reports whether the Host header names this server on loopback
+func (s *callbackServer) validHost(host string) bool {
+	for _, name := range []string{"localhost", "127.0.0.1", "[::1]"} {
+		if host == fmt.Sprintf("%s:%d", name, s.port) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopbackAddr reports whether a remote address is on this machine
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// callbackPage is shown in the browser after the redirect
+var callbackPage = template.Must(template.New("callback").Parse(`<html>
+<head><title>{{.Title}}</title></head>
+<body style="font-family: sans-serif; display: flex; align-items: center; justify-content: center; height: 100vh; margin: 0; background: linear-gradient(135deg, #0f172a 0%, #1e293b 100%); color: white;">
+	<div style="text-align: center;">
+		<h1 style="color: {{if .OK}}#10b981{{else}}#ef4444{{end}};">{{if .OK}}✓{{else}}✕{{end}} {{.Title}}</h1>
+		<p>{{.Message}}</p>
+	</div>
+</body>
+</html>
+`))
+
+// writeCallbackPage renders callbackPage
+func writeCallbackPage(w http.ResponseWriter, status int, ok bool, title, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	callbackPage.Execute(w, struct {
+		OK             bool
+		Title, Message string
+	}{ok, title, message})
+}
+
+// CancelAuthentication stops waiting for the browser sign in started by Authenticate
+func (a *App) CancelAuthentication() {
+	a.authMu.Lock()
+	defer a.authMu.Unlock()
+	if a.authCancel != nil {
+		a.authCancel()
+		a.authCancel = nil
+	}
+}
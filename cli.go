@@ -0,0 +1,637 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+)
+
+// Exit codes returned by CLI subcommands
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitAuthFail = 3 // not signed in, or the token can't be refreshed
+)
+
+// cliCommands are the subcommands run instead of the desktop app, keyed by their words
+var cliCommands = map[string]func(args []string) int{
+	"daemon":       runDaemon,
+	"plants list":  runPlantsList,
+	"devices list": runDevicesList,
+	"alarms list":  runAlarmsList,
+	"points get":   runPointsGet,
+	"auth status":  runAuthStatus,
+	"auth login":   runAuthLogin,
+}
+
+// cliUsage lists the subcommands
+const cliUsage = `Usage: %[1]s <command> [flags]
+
+Commands:
+  daemon                                    run background services without a window
+  plants list                               list plants
+  devices list --ps-id ID                   list the devices of a plant
+  alarms list --ps-id ID                    list the faults and alarms of a plant
+  points get --ps-key KEY,...               read real-time points of devices
+  auth status                               show whether stored credentials are usable
+  auth login                                sign in by pasting the redirect URL, e.g. over SSH
+
+List and get commands accept --format table|json|csv and -v to log API calls to stderr.
+Run "%[1]s <command> -h" for a command's flags.
+`
+
+// runCommand runs the CLI subcommand named by args. ok is false if args don't name
+// one, in which case the desktop app should start.
+func runCommand(args []string) (code int, ok bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+
+	if run, found := cliCommands[args[0]]; found {
+		return run(args[1:]), true
+	}
+	if len(args) > 1 {
+		if run, found := cliCommands[args[0]+" "+args[1]]; found {
+			return run(args[2:]), true
+		}
+	}
+
+	switch args[0] {
+	case "help", "-h", "--help":
+		fmt.Printf(cliUsage, os.Args[0])
+		return exitOK, true
+	}
+
+	// A command group without a valid verb
+	for name := range cliCommands {
+		if strings.HasPrefix(name, args[0]+" ") {
+			fmt.Fprintf(os.Stderr, cliUsage, os.Args[0])
+			return exitUsage, true
+		}
+	}
+	return 0, false
+}
+
+// cliFlags holds the flags shared by the query commands
+type cliFlags struct {
+	*flag.FlagSet
+	format  *string
+	verbose *bool
+}
+
+// newCLIFlags creates a flag set with the shared --format and -v flags
+func newCLIFlags(name string) *cliFlags {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	return &cliFlags{
+		FlagSet: flags,
+		format:  flags.String("format", "table", "output format: table, json or csv"),
+		verbose: flags.Bool("v", false, "log API calls to stderr"),
+	}
+}
+
+// parse parses args, checking the output format
+func (f *cliFlags) parse(args []string) error {
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	switch *f.format {
+	case "table", "json", "csv":
+		return nil
+	}
+	err := fmt.Errorf("unknown format %q, use table, json or csv", *f.format)
+	fmt.Fprintln(f.Output(), err)
+	return err
+}
+
+// newCLIApp loads stored accounts and settings without starting any background services.
+// The app's log goes to stderr with -v and is discarded otherwise, keeping the returned
+// writer, stdout, for command output alone.
+func newCLIApp(verbose bool) (*App, io.Writer) {
+	a := NewApp()
+	a.headless = true
+	a.log.SetOutput(io.Discard)
+	if verbose {
+		a.log.SetOutput(os.Stderr)
+	}
+	a.loadAccounts()
+	a.loadSettings()
+	return a, os.Stdout
+}
+
+// cliFail reports an error and returns the matching exit code
+func cliFail(command string, err error) int {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
+	return errorExitCode(err)
+}
+
+// errorExitCode returns the exit code for a failed command
+func errorExitCode(err error) int {
+	if errors.Is(err, isolarcloud.ErrNotAuthenticated) || errors.Is(err, isolarcloud.ErrTokenExpired) {
+		return exitAuthFail
+	}
+	return exitError
+}
+
+// table is command output that can be written as an aligned table, CSV or JSON
+type table struct {
+	headers []string
+	rows    [][]string
+	data    interface{} // written instead of rows for JSON
+}
+
+// write renders the table in the given format
+func (t table) write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(t.data)
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write(t.headers)
+		writer.WriteAll(t.rows)
+		return writer.Error()
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(t.headers, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	return writer.Flush()
+}
+
+// runPlantsList implements "plants list"
+func runPlantsList(args []string) int {
+	flags := newCLIFlags("plants list")
+	if err := flags.parse(args); err != nil {
+		return exitUsage
+	}
+
+	a, out := newCLIApp(*flags.verbose)
+	plants, err := a.GetPlantList()
+	if err != nil {
+		return cliFail("plants list", err)
+	}
+
+	accountNames := map[string]string{}
+	for _, acct := range a.GetAccounts() {
+		accountNames[acct.ID] = acct.Name
+	}
+
+	t := table{
+		headers: []string{"ps_id", "name", "type", "online", "fault", "location", "updated", "account"},
+		data:    plants,
+	}
+	for _, p := range plants {
+		t.rows = append(t.rows, []string{
+			strconv.Itoa(p.PsID),
+			p.PsName,
+			strconv.Itoa(p.PsType),
+			strconv.Itoa(p.OnlineStatus),
+			strconv.Itoa(p.PsFaultStatus),
+			p.PsLocation,
+			p.UpdateTime,
+			accountNames[p.AccountID],
+		})
+	}
+
+	if err := t.write(out, *flags.format); err != nil {
+		return cliFail("plants list", err)
+	}
+	return exitOK
+}
+
+// runDevicesList implements "devices list"
+func runDevicesList(args []string) int {
+	flags := newCLIFlags("devices list")
+	psID := flags.Int("ps-id", 0, "plant ID (required)")
+	if err := flags.parse(args); err != nil {
+		return exitUsage
+	}
+	if *psID == 0 {
+		fmt.Fprintln(os.Stderr, "devices list: --ps-id is required")
+		return exitUsage
+	}
+
+	a, out := newCLIApp(*flags.verbose)
+	devices, err := a.GetDeviceList(*psID)
+	if err != nil {
+		return cliFail("devices list", err)
+	}
+
+	t := table{
+		headers: []string{"ps_key", "name", "type", "type_name", "serial", "model", "fault"},
+		data:    devices,
+	}
+	for _, d := range devices {
+		t.rows = append(t.rows, []string{
+			d.PsKey,
+			d.DeviceName,
+			strconv.Itoa(d.DeviceType),
+			d.TypeName,
+			d.DeviceSN,
+			d.DeviceModelCode,
+			strconv.Itoa(d.DevFaultStatus),
+		})
+	}
+
+	if err := t.write(out, *flags.format); err != nil {
+		return cliFail("devices list", err)
+	}
+	return exitOK
+}
+
+// runAlarmsList implements "alarms list"
+func runAlarmsList(args []string) int {
+	flags := newCLIFlags("alarms list")
+	psID := flags.Int("ps-id", 0, "plant ID (required)")
+	since := flags.Duration("since", 7*24*time.Hour, "how far back to look, e.g. 24h")
+	active := flags.Bool("active", false, "only list alarms that haven't cleared")
+	if err := flags.parse(args); err != nil {
+		return exitUsage
+	}
+	if *psID == 0 {
+		fmt.Fprintln(os.Stderr, "alarms list: --ps-id is required")
+		return exitUsage
+	}
+
+	a, out := newCLIApp(*flags.verbose)
+	client, err := a.clientForPlant(*psID)
+	if err != nil {
+		return cliFail("alarms list", err)
+	}
+	alarms, err := client.AlarmList(context.Background(), *psID, isolarcloud.AlarmQuery{
+		From:       time.Now().Add(-*since),
+		ActiveOnly: *active,
+	})
+	if err != nil {
+		return cliFail("alarms list", err)
+	}
+
+	t := table{
+		headers: []string{"started", "ended", "ps_key", "device", "code", "level", "name", "description"},
+		data:    alarms,
+	}
+	for _, alarm := range alarms {
+		ended := "active"
+		if alarm.EndTime != 0 {
+			ended = time.UnixMilli(alarm.EndTime).Format(time.DateTime)
+		}
+		t.rows = append(t.rows, []string{
+			time.UnixMilli(alarm.StartTime).Format(time.DateTime),
+			ended,
+			alarm.PsKey,
+			alarm.DeviceName,
+			alarm.Code,
+			alarm.LevelName,
+			alarm.Name,
+			alarm.Description,
+		})
+	}
+
+	if err := t.write(out, *flags.format); err != nil {
+		return cliFail("alarms list", err)
+	}
+	return exitOK
+}
+
+// runPointsGet implements "points get". Several devices are read in as few requests as
+// possible; a device that fails is reported on stderr without hiding the others.
+func runPointsGet(args []string) int {
+	flags := newCLIFlags("points get")
+	psKeyList := flags.String("ps-key", "", "comma separated device ps_keys (required)")
+	points := flags.String("points", batterySocKey, "comma separated point IDs or catalogue keys, e.g. battery_soc")
+	deviceType := flags.Int("device-type", 0, "device type (default: taken from each ps_key)")
+	if err := flags.parse(args); err != nil {
+		return exitUsage
+	}
+
+	var psKeys []string
+	for _, key := range strings.Split(*psKeyList, ",") {
+		if key = strings.TrimSpace(key); key != "" && !slices.Contains(psKeys, key) {
+			psKeys = append(psKeys, key)
+		}
+	}
+	if len(psKeys) == 0 {
+		fmt.Fprintln(os.Stderr, "points get: --ps-key is required")
+		return exitUsage
+	}
+
+	pointIDs, err := parsePointList(*points)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "points get: %v\n", err)
+		return exitUsage
+	}
+
+	var requests []isolarcloud.DeviceRealTimeRequest
+	for _, psKey := range psKeys {
+		keyType := *deviceType
+		if keyType == 0 {
+			keyType, err = psKeyDeviceType(psKey)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "points get: %v, pass --device-type\n", err)
+				return exitUsage
+			}
+		}
+		requests = appendDeviceRequest(requests, keyType, psKey, pointIDs)
+	}
+
+	a, out := newCLIApp(*flags.verbose)
+	results, err := a.GetDevicePointDataBatch(requests)
+	if err != nil {
+		return cliFail("points get", err)
+	}
+
+	readings := []isolarcloud.Reading{}
+	t := table{headers: []string{"ps_key", "point_id", "name", "value", "unit"}}
+	var failed error
+	for _, psKey := range psKeys {
+		result := results[psKey]
+		if result.Error != "" {
+			fmt.Fprintf(os.Stderr, "points get: %s: %s\n", psKey, result.Error)
+			failed = result.err
+			continue
+		}
+
+		for _, reading := range result.Readings {
+			if reading.PsKey == "" {
+				reading.PsKey = psKey
+			}
+			readings = append(readings, reading)
+			t.rows = append(t.rows, []string{psKey, strconv.Itoa(reading.PointID), reading.Name, strconv.FormatFloat(reading.Value, 'f', -1, 64), reading.Unit})
+		}
+	}
+	t.data = readings
+
+	if err := t.write(out, *flags.format); err != nil {
+		return cliFail("points get", err)
+	}
+	if failed != nil {
+		return errorExitCode(failed)
+	}
+	return exitOK
+}
+
+// parsePointList parses "58604,13141", "p58604,p13141" or catalogue keys such as "battery_soc"
+func parsePointList(list string) ([]int, error) {
+	var pointIDs []int
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if def, ok := isolarcloud.PointByKey(field); ok {
+			pointIDs = append(pointIDs, def.ID)
+			continue
+		}
+		pointID, err := strconv.Atoi(strings.TrimPrefix(field, "p"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid point ID %q", field)
+		}
+		pointIDs = append(pointIDs, pointID)
+	}
+	if len(pointIDs) == 0 {
+		return nil, fmt.Errorf("no point IDs given")
+	}
+	return pointIDs, nil
+}
+
+// psKeyDeviceType reads the device type from a ps_key such as "1234567_14_1_1"
+func psKeyDeviceType(psKey string) (int, error) {
+	parts := strings.Split(psKey, "_")
+	if len(parts) < 2 {
+		return 0, fmt.Errorf("cannot read device type from ps_key %q", psKey)
+	}
+	deviceType, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("cannot read device type from ps_key %q", psKey)
+	}
+	return deviceType, nil
+}
+
+// authStatus is a row of "auth status"
+type authStatus struct {
+	AccountID       string `json:"accountId"`
+	Account         string `json:"account"`
+	Active          bool   `json:"active"`
+	SignedIn        bool   `json:"signedIn"`
+	AppKey          string `json:"appKey,omitempty"`
+	Gateway         string `json:"gateway,omitempty"`
+	TokenExpiry     string `json:"tokenExpiry,omitempty"`
+	TokenValid      bool   `json:"tokenValid"`
+	CanRefresh      bool   `json:"canRefresh"`
+	CredentialStore string `json:"credentialStore,omitempty"`
+}
+
+// runAuthStatus implements "auth status", one row per account. It exits with exitAuthFail
+// unless every account's token is valid or can be refreshed, so cron jobs can check for
+// a required re-login.
+func runAuthStatus(args []string) int {
+	flags := newCLIFlags("auth status")
+	only := flags.String("account", "", "only show this account (ID or name)")
+	if err := flags.parse(args); err != nil {
+		return exitUsage
+	}
+
+	a, out := newCLIApp(*flags.verbose)
+	storeName := ""
+	if store, err := a.credentialStore(); err == nil {
+		storeName = store.Name()
+	}
+
+	accounts := a.orderedAccounts()
+	if *only != "" {
+		acct := a.findAccount(*only)
+		if acct == nil {
+			fmt.Fprintf(os.Stderr, "auth status: unknown account %q\n", *only)
+			return exitUsage
+		}
+		accounts = []*account{acct}
+	}
+
+	active := a.activeAccount()
+	healthy := len(accounts) > 0
+	t := table{
+		headers: []string{"account_id", "account", "active", "signed_in", "app_key", "gateway", "token_expiry", "token_valid", "can_refresh", "credential_store"},
+	}
+	statuses := []authStatus{}
+	for _, acct := range accounts {
+		status := authStatus{AccountID: acct.id, Account: acct.displayName(), Active: acct == active, CredentialStore: storeName}
+		if creds := acct.Credentials(); creds != nil {
+			status.AppKey = creds.AppKey
+			status.Gateway = creds.GatewayURL
+			if status.Gateway == "" {
+				status.Gateway = isolarcloud.DefaultGateway().BaseURL
+			}
+			status.SignedIn = creds.AccessToken != "" || creds.RefreshToken != ""
+			status.CanRefresh = creds.RefreshToken != ""
+			if creds.TokenExpiry > 0 {
+				expiry := time.UnixMilli(creds.TokenExpiry)
+				status.TokenExpiry = expiry.Format(time.RFC3339)
+				status.TokenValid = creds.AccessToken != "" && time.Now().Before(expiry)
+			} else {
+				status.TokenValid = creds.AccessToken != ""
+			}
+		}
+		if !status.TokenValid && !status.CanRefresh {
+			healthy = false
+		}
+
+		statuses = append(statuses, status)
+		t.rows = append(t.rows, []string{
+			status.AccountID,
+			status.Account,
+			strconv.FormatBool(status.Active),
+			strconv.FormatBool(status.SignedIn),
+			status.AppKey,
+			status.Gateway,
+			status.TokenExpiry,
+			strconv.FormatBool(status.TokenValid),
+			strconv.FormatBool(status.CanRefresh),
+			status.CredentialStore,
+		})
+	}
+	t.data = statuses
+
+	if err := t.write(out, *flags.format); err != nil {
+		return cliFail("auth status", err)
+	}
+
+	if !healthy {
+		return exitAuthFail
+	}
+	return exitOK
+}
+
+// runAuthLogin implements "auth login", signing in without a local browser or callback
+// listener. Flags default to the stored credentials so renewing a login needs none.
+func runAuthLogin(args []string) int {
+	flags := flag.NewFlagSet("auth login", flag.ContinueOnError)
+	appKey := flags.String("app-key", "", "app key (default: stored)")
+	secretKey := flags.String("secret-key", "", "secret key (default: stored, or $SUNGROW_MONITOR_SECRET_KEY)")
+	authURL := flags.String("auth-url", "", "authorization URL (default: stored, or the region's)")
+	gateway := flags.String("gateway", "", "API gateway URL (default: stored, or the region's)")
+	region := flags.String("region", "", "gateway region ("+gatewayIDs()+"), or auto to detect it from the keys (default: stored, or "+isolarcloud.DefaultGatewayID+")")
+	redirectURL := flags.String("redirect-url", manualRedirectURL, "redirect URL registered for the app key")
+	accountName := flags.String("account", "", "account to sign in (ID or name, created if new; default: the active account)")
+	verbose := flags.Bool("v", false, "log API calls to stderr")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	a, out := newCLIApp(*verbose)
+
+	if *accountName != "" {
+		var err error
+		if acct := a.findAccount(*accountName); acct != nil {
+			err = a.SwitchAccount(acct.id)
+		} else {
+			_, err = a.AddAccount(*accountName)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "auth login: %v\n", err)
+			return exitError
+		}
+	}
+
+	creds := Credentials{}
+	if stored := a.GetStoredCredentials(); stored != nil {
+		creds = Credentials{
+			AppKey:     stored.AppKey,
+			SecretKey:  stored.SecretKey,
+			AuthURL:    stored.AuthURL,
+			GatewayURL: stored.GatewayURL,
+		}
+	}
+	if secret := os.Getenv("SUNGROW_MONITOR_SECRET_KEY"); secret != "" {
+		creds.SecretKey = secret
+	}
+	for _, override := range []struct {
+		value *string
+		field *string
+	}{
+		{appKey, &creds.AppKey},
+		{secretKey, &creds.SecretKey},
+		{authURL, &creds.AuthURL},
+		{gateway, &creds.GatewayURL},
+	} {
+		if *override.value != "" {
+			*override.field = *override.value
+		}
+	}
+
+	if *region != "" {
+		var selected isolarcloud.Gateway
+		if *region == "auto" {
+			var err error
+			if selected, err = a.DetectGateway(creds.AppKey, creds.SecretKey); err != nil {
+				fmt.Fprintf(os.Stderr, "auth login: %v\n", err)
+				return exitAuthFail
+			}
+			fmt.Fprintf(out, "Detected the %s gateway (%s)\n", selected.Name, selected.BaseURL)
+		} else {
+			var ok bool
+			if selected, ok = isolarcloud.GatewayByID(*region); !ok {
+				fmt.Fprintf(os.Stderr, "auth login: unknown region %q, expected one of %s or auto\n", *region, gatewayIDs())
+				return exitUsage
+			}
+		}
+		if *gateway == "" {
+			creds.GatewayURL = selected.BaseURL
+		}
+		if *authURL == "" {
+			creds.AuthURL = selected.AuthURL
+		}
+	}
+
+	loginURL, err := a.BeginManualLogin(creds, *redirectURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "auth login: %v\n", err)
+		return exitUsage
+	}
+
+	fmt.Fprintf(out, "Open this URL in a browser on any device and sign in:\n\n  %s\n\n", loginURL)
+	fmt.Fprintln(out, "The browser is then sent to a page that won't load. Paste its full address, or just the code, here:")
+	fmt.Fprint(out, "> ")
+
+	input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && input == "" {
+		fmt.Fprintf(os.Stderr, "auth login: no input: %v\n", err)
+		return exitAuthFail
+	}
+
+	result, err := a.CompleteManualLogin(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "auth login: %v\n", err)
+		return exitAuthFail
+	}
+
+	// The account just signed in, not the soonest expiry across accounts
+	expiry, _ := result["tokenExpiry"].(int64)
+	fmt.Fprintf(out, "Signed in, token valid until %s\n", time.UnixMilli(expiry).Format(time.RFC1123))
+	return exitOK
+}
+
+// gatewayIDs lists the region IDs accepted by --region
+func gatewayIDs() string {
+	ids := []string{}
+	for _, g := range isolarcloud.Gateways() {
+		ids = append(ids, g.ID)
+	}
+	return strings.Join(ids, ", ")
+}
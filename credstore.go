@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+// keyringService is the service name secrets are stored under in the OS keyring
+const keyringService = "SungrowMonitor"
+
+// errSecretNotFound is returned by a CredentialStore when no secret is stored under a key
+var errSecretNotFound = errors.New("secret not found")
+
+// errKeyringLocked is returned by the OS keyring when it's locked and the user didn't
+// unlock it
+var errKeyringLocked = errors.New("keyring is locked")
+
+// CredentialStore persists secrets such as the saved credentials
+type CredentialStore interface {
+	// Name describes the backend for logs and the UI
+	Name() string
+	// Load returns the secret stored under key, or errSecretNotFound
+	Load(key string) ([]byte, error)
+	// Save stores a secret under key, replacing any existing one
+	Save(key string, data []byte) error
+	// Delete removes the secret stored under key. Deleting a missing key is not an error.
+	Delete(key string) error
+}
+
+// newCredentialStore picks the OS keyring when one is reachable and unlocked, and falls
+// back to an encrypted file in appDir. SUNGROW_MONITOR_CREDENTIAL_STORE=file|keyring
+// forces a backend.
+func newCredentialStore(appDir string, logger *log.Logger) CredentialStore {
+	passphrase, derived := credentialPassphrase(appDir)
+	fileStore := func() CredentialStore {
+		if derived {
+			logger.Println("newCredentialStore: warning: SUNGROW_MONITOR_PASSPHRASE is not set, so the encrypted file only obfuscates secrets, its key coming from the hostname and user name")
+		}
+		return newEncryptedFileStore(appDir, passphrase)
+	}
+
+	switch os.Getenv("SUNGROW_MONITOR_CREDENTIAL_STORE") {
+	case "file":
+		return fileStore()
+	case "keyring":
+		return keyringStore{}
+	}
+
+	if !keyringAvailable() {
+		logger.Println("newCredentialStore: OS keyring unavailable, using encrypted file")
+		return fileStore()
+	}
+
+	// A keyring left locked would fail every load and save, so use the file instead
+	if err := keyringUnlock(); err != nil {
+		logger.Printf("newCredentialStore: OS keyring unusable, using encrypted file: %v\n", err)
+		return fileStore()
+	}
+	return keyringStore{}
+}
+
+// credentialPassphrase returns the passphrase protecting the encrypted file store, and
+// whether it was derived rather than set with SUNGROW_MONITOR_PASSPHRASE. The derived
+// one comes from the hostname, user and app directory. None of those are secret, so it
+// only stops casual reading of a copied file: anyone who can read the file and knows
+// or guesses them can decrypt it.
+func credentialPassphrase(appDir string) (string, bool) {
+	if passphrase := os.Getenv("SUNGROW_MONITOR_PASSPHRASE"); passphrase != "" {
+		return passphrase, false
+	}
+
+	hostname, _ := os.Hostname()
+	username := ""
+	if u, err := user.Current(); err == nil {
+		username = u.Username + ":" + u.Uid
+	}
+
+	return fmt.Sprintf("%s|%s|%s|%s", keyringService, hostname, username, appDir), true
+}
+
+// keyringStore keeps secrets in the OS keyring (Secret Service, macOS Keychain or
+// Windows Credential Manager)
+type keyringStore struct{}
+
+func (keyringStore) Name() string {
+	return "OS keyring"
+}
+
+func (keyringStore) Load(key string) ([]byte, error) {
+	return keyringGet(keyringService, key)
+}
+
+func (keyringStore) Save(key string, data []byte) error {
+	return keyringSet(keyringService, key, data)
+}
+
+func (keyringStore) Delete(key string) error {
+	return keyringDelete(keyringService, key)
+}
+
+// pbkdf2Iterations is the work factor for deriving the file encryption key
+const pbkdf2Iterations = 600000
+
+// encryptedFile is the on-disk format of a secret in the encrypted file store
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// encryptedFileStore keeps each secret in an AES-256-GCM encrypted file, with the key
+// derived from a passphrase using PBKDF2-SHA256
+type encryptedFileStore struct {
+	dir        string
+	passphrase string
+}
+
+func newEncryptedFileStore(dir, passphrase string) *encryptedFileStore {
+	return &encryptedFileStore{dir: dir, passphrase: passphrase}
+}
+
+func (s *encryptedFileStore) Name() string {
+	return "encrypted file"
+}
+
+func (s *encryptedFileStore) path(key string) string {
+	return filepath.Join(s.dir, key+".enc")
+}
+
+func (s *encryptedFileStore) Load(key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errSecretNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("corrupt secret file %s: %w", s.path(key), err)
+	}
+	if file.Version != 1 {
+		return nil, fmt.Errorf("unsupported secret file version %d", file.Version)
+	}
+
+	gcm, err := s.cipher(file.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt %s, was the passphrase changed? %w", s.path(key), err)
+	}
+
+	return plaintext, nil
+}
+
+func (s *encryptedFileStore) Save(key string, data []byte) error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	gcm, err := s.cipher(salt)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	file := encryptedFile{
+		Version:    1,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, data, []byte(key)),
+	}
+
+	out, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so a crash never leaves a half-written secret
+	tmp := s.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, out, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(key))
+}
+
+func (s *encryptedFileStore) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// cipher derives the AES-GCM cipher for a salt
+func (s *encryptedFileStore) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, s.passphrase, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedFileStore(t *testing.T) {
+	dir := t.TempDir()
+	s := newEncryptedFileStore(dir, "correct horse")
+
+	if _, err := s.Load("account-1"); !errors.Is(err, errSecretNotFound) {
+		t.Errorf("Load of a missing key = %v, want errSecretNotFound", err)
+	}
+	if err := s.Delete("account-1"); err != nil {
+		t.Errorf("Delete of a missing key = %v", err)
+	}
+
+	// Save replaces, Load returns the latest
+	for _, secret := range []string{`{"password":"one"}`, `{"password":"two"}`} {
+		if err := s.Save("account-1", []byte(secret)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.Load("account-1")
+		if err != nil || string(got) != secret {
+			t.Errorf("Load = %s, %v, want %s", got, err, secret)
+		}
+	}
+
+	// Only the encrypted file is left, with nothing readable in it
+	data, err := os.ReadFile(filepath.Join(dir, "account-1.enc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "two") {
+		t.Errorf("secret file holds the plaintext: %s", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files in the store, want only the secret", len(entries))
+	}
+
+	// Another store on the same directory with the same passphrase reads it
+	if got, err := newEncryptedFileStore(dir, "correct horse").Load("account-1"); err != nil || string(got) != `{"password":"two"}` {
+		t.Errorf("Load from a new store = %s, %v", got, err)
+	}
+
+	if err := s.Delete("account-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load("account-1"); !errors.Is(err, errSecretNotFound) {
+		t.Errorf("Load after Delete = %v, want errSecretNotFound", err)
+	}
+}
+
+func TestEncryptedFileStoreRejectsTampering(t *testing.T) {
+	dir := t.TempDir()
+	s := newEncryptedFileStore(dir, "correct horse")
+	if err := s.Save("account-1", []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "account-1.enc")
+	original, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrite changes the saved file, restoring it first
+	rewrite := func(change func(*encryptedFile)) {
+		t.Helper()
+		var file encryptedFile
+		if err := json.Unmarshal(original, &file); err != nil {
+			t.Fatal(err)
+		}
+		change(&file)
+		data, _ := json.Marshal(file)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		store   *encryptedFileStore
+		change  func(*encryptedFile)
+		wantErr string
+	}{
+		{
+			name:    "wrong passphrase",
+			store:   newEncryptedFileStore(dir, "battery staple"),
+			change:  func(*encryptedFile) {},
+			wantErr: "was the passphrase changed?",
+		},
+		{
+			name:    "flipped ciphertext bit",
+			store:   s,
+			change:  func(f *encryptedFile) { f.Ciphertext[0] ^= 1 },
+			wantErr: "message authentication failed",
+		},
+		{
+			name:    "changed salt",
+			store:   s,
+			change:  func(f *encryptedFile) { f.Salt[0] ^= 1 },
+			wantErr: "message authentication failed",
+		},
+		{
+			name:    "unknown version",
+			store:   s,
+			change:  func(f *encryptedFile) { f.Version = 2 },
+			wantErr: "unsupported secret file version 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewrite(tt.change)
+			if _, err := tt.store.Load("account-1"); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	// The key is authenticated too, so a file copied to another key doesn't decrypt
+	if err := os.WriteFile(filepath.Join(dir, "account-2.enc"), original, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load("account-2"); err == nil {
+		t.Error("Load of a secret copied from another key succeeded")
+	}
+
+	if err := os.WriteFile(path, original[:len(original)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load("account-1"); err == nil || !strings.Contains(err.Error(), "corrupt secret file") {
+		t.Errorf("Load of a truncated file = %v", err)
+	}
+}
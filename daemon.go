@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runDaemon polls watched devices without a window or tray, feeding history, the
+// exporter and MQTT. SIGTERM/SIGINT stop it and SIGHUP reloads settings and credentials.
+func runDaemon(args []string) int {
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s daemon\n\n", os.Args[0])
+		fmt.Fprintln(flags.Output(), "Runs background polling, history, the Prometheus exporter and MQTT without a window.")
+		fmt.Fprintln(flags.Output(), "Configure through settings.json in the app config directory; send SIGHUP to reload it.")
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	a := NewApp()
+	a.headless = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	a.startup(ctx)
+	if err := a.checkDaemonReady(); err != nil {
+		fmt.Fprintf(os.Stderr, "runDaemon: %v\n", err)
+		a.shutdown(ctx)
+		return 1
+	}
+	a.log.Printf("runDaemon: polling %d device(s)\n", len(a.poller.Targets()))
+
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			a.log.Printf("runDaemon: received %s, shutting down\n", sig)
+			break
+		}
+
+		a.log.Println("runDaemon: received SIGHUP, reloading settings")
+		a.reload()
+		if err := a.checkDaemonReady(); err != nil {
+			fmt.Fprintf(os.Stderr, "runDaemon: %v\n", err)
+		}
+	}
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	a.shutdown(shutdownCtx)
+	return 0
+}
+
+// checkDaemonReady reports configuration the daemon can't do anything useful without
+func (a *App) checkDaemonReady() error {
+	if len(a.signedInAccounts()) == 0 {
+		return fmt.Errorf("not signed in: log in with the desktop app or \"auth login\" as this user first")
+	}
+	if len(a.poller.Targets()) == 0 {
+		a.log.Println("runDaemon: no devices are watched, add poller.targets to settings.json or watch a device in the desktop app")
+	}
+	return nil
+}
+
+// reload re-reads accounts and settings and applies them to the running services
+func (a *App) reload() {
+	a.loadAccounts()
+	a.loadSettings()
+	settings := a.GetSettings()
+
+	// Configure only changes timings, sync the watched devices separately
+	stale := map[string]bool{}
+	for _, t := range a.poller.Targets() {
+		stale[t.PsKey] = true
+	}
+	for _, t := range settings.Poller.Targets {
+		a.poller.Watch(t)
+		delete(stale, t.PsKey)
+	}
+	for psKey := range stale {
+		a.poller.Unwatch(psKey)
+	}
+
+	a.applySettings(settings)
+}
+
+// logPollResult prints the values of a successful poll, failures are logged by the poller
+func (a *App) logPollResult(t PollTarget, r PollResult) {
+	if r.Error != "" {
+		return
+	}
+
+	name := t.DeviceName
+	if name == "" {
+		name = t.PsKey
+	}
+
+	values := make([]string, 0, len(r.Readings))
+	for _, reading := range r.Readings {
+		values = append(values, fmt.Sprintf("p%d=%g%s", reading.PointID, reading.Value, reading.Unit))
+	}
+	a.log.Printf("handlePollResult: %s: %s\n", name, strings.Join(values, " "))
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+)
+
+// FrontendError is the structured form of errors returned to the frontend.
+// Bound methods reject their promise with this object instead of a plain string.
+type FrontendError struct {
+	Message      string `json:"message"`
+	Kind         string `json:"kind"`
+	ResultCode   string `json:"resultCode,omitempty"`
+	ReqSerialNum string `json:"reqSerialNum,omitempty"`
+	HTTPStatus   int    `json:"httpStatus,omitempty"`
+	Path         string `json:"path,omitempty"`
+}
+
+// formatError converts errors from bound methods into a FrontendError
+func formatError(err error) any {
+	fe := FrontendError{
+		Message: err.Error(),
+		Kind:    isolarcloud.ErrorKind(err),
+	}
+
+	var apiErr *isolarcloud.APIError
+	if errors.As(err, &apiErr) {
+		fe.ResultCode = apiErr.ResultCode
+		fe.ReqSerialNum = apiErr.ReqSerialNum
+		fe.HTTPStatus = apiErr.HTTPStatus
+		fe.Path = apiErr.Path
+	}
+
+	return fe
+}
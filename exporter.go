@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+	"wails-sungrow-isolarcloud-app/metrics"
+)
+
+// MetricsSettings controls the Prometheus /metrics endpoint
+type MetricsSettings struct {
+	Enabled    bool   `json:"enabled"`
+	ListenAddr string `json:"listenAddr"`
+}
+
+// namedPointGauge maps a well known point to a dedicated gauge
+type namedPointGauge struct {
+	name string
+	help string
+}
+
+// namedPointGauges are catalogue points exported under their own metric name, in their
+// catalogue unit, in addition to sungrow_point_value
+var namedPointGauges = map[string]namedPointGauge{
+	batterySocKey:       {"sungrow_battery_soc_percent", "Battery state of charge in percent."},
+	batteryLevelKey:     {"sungrow_battery_soc_percent", "Battery state of charge in percent."},
+	"pv_power":          {"sungrow_pv_power_watts", "Total DC power produced by PV."},
+	"load_power":        {"sungrow_load_power_watts", "Power consumed by the load."},
+	"grid_import_power": {"sungrow_grid_import_power_watts", "Power purchased from the grid."},
+	"grid_export_power": {"sungrow_grid_export_power_watts", "Power fed into the grid."},
+}
+
+// appMetrics holds the metrics exported by the app
+type appMetrics struct {
+	registry     *metrics.Registry
+	pointValue   *metrics.Vec
+	named        map[string]*metrics.Vec
+	lastPoll     *metrics.Vec
+	pollFailures *metrics.Vec
+	apiRequests  *metrics.Vec
+	apiErrors    *metrics.Vec
+	apiLatency   *metrics.HistogramVec
+}
+
+// newAppMetrics registers the app's metrics. tokenExpiry returns the access token expiry in Unix milliseconds.
+func newAppMetrics(tokenExpiry func() int64) *appMetrics {
+	r := metrics.NewRegistry()
+	m := &appMetrics{
+		registry:     r,
+		pointValue:   r.NewGauge("sungrow_point_value", "Latest raw value of a device point.", "ps_id", "ps_key", "point_id"),
+		named:        map[string]*metrics.Vec{},
+		lastPoll:     r.NewGauge("sungrow_last_poll_timestamp_seconds", "Time of the last successful poll of a device.", "ps_id", "ps_key"),
+		pollFailures: r.NewGauge("sungrow_poll_consecutive_failures", "Consecutive failed polls of a device.", "ps_id", "ps_key"),
+		apiRequests:  r.NewCounter("sungrow_api_requests_total", "Gateway requests by endpoint and result code.", "path", "result_code"),
+		apiErrors:    r.NewCounter("sungrow_api_errors_total", "Failed gateway requests by endpoint and result code.", "path", "result_code"),
+		apiLatency:   r.NewHistogram("sungrow_api_request_duration_seconds", "Gateway request latency.", metrics.DefaultBuckets, "path"),
+	}
+
+	for _, g := range namedPointGauges {
+		m.named[g.name] = r.NewGauge(g.name, g.help, "ps_id", "ps_key")
+	}
+
+	r.NewGaugeFunc("sungrow_token_expiry_seconds", "Seconds until the access token expires, negative once expired.", func() float64 {
+		expiry := tokenExpiry()
+		if expiry == 0 {
+			return 0
+		}
+		return time.Until(time.UnixMilli(expiry)).Seconds()
+	})
+
+	return m
+}
+
+// observeRequest records a gateway request
+func (m *appMetrics) observeRequest(info isolarcloud.RequestInfo) {
+	code := info.ResultCode
+	if info.Err != nil {
+		code = "transport_error"
+	} else if code == "" {
+		code = "http_" + strconv.Itoa(info.HTTPStatus)
+	}
+
+	m.apiRequests.Inc(info.Path, code)
+	m.apiLatency.Observe(info.Duration.Seconds(), info.Path)
+	if code != "1" {
+		m.apiErrors.Inc(info.Path, code)
+	}
+}
+
+// observePoll records the points of a poll result
+func (m *appMetrics) observePoll(r PollResult) {
+	psID := strconv.Itoa(r.PsID)
+	m.pollFailures.Set(float64(r.Failures), psID, r.PsKey)
+	if r.Error != "" {
+		return
+	}
+
+	m.lastPoll.Set(float64(r.Timestamp)/1000, psID, r.PsKey)
+	for key, raw := range r.Points {
+		pointID, ok := isolarcloud.ParsePointKey(key)
+		if !ok {
+			continue
+		}
+		if value, ok := isolarcloud.ParseValue(raw); ok {
+			m.pointValue.Set(value, psID, r.PsKey, strconv.Itoa(pointID))
+		}
+	}
+	for _, reading := range r.Readings {
+		if g, ok := namedPointGauges[reading.Key]; ok {
+			m.named[g.name].Set(reading.Value, psID, r.PsKey)
+		}
+	}
+}
+
+// tokenExpiry returns the soonest access token expiry of the signed in accounts in Unix milliseconds
+func (a *App) tokenExpiry() int64 {
+	var soonest int64
+	for _, acct := range a.signedInAccounts() {
+		if expiry := acct.Credentials().TokenExpiry; expiry > 0 && (soonest == 0 || expiry < soonest) {
+			soonest = expiry
+		}
+	}
+	return soonest
+}
+
+// applyMetricsSettings starts, restarts or stops the /metrics server to match the settings.
+// A server already running with them is left alone.
+func (a *App) applyMetricsSettings(ctx context.Context, settings MetricsSettings) {
+	a.metricsMu.Lock()
+	defer a.metricsMu.Unlock()
+
+	if a.metricsServer != nil && settings.Enabled && settings == a.metricsConfig {
+		return
+	}
+
+	if a.metricsServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		a.metricsServer.Shutdown(shutdownCtx)
+		cancel()
+		a.metricsServer = nil
+	}
+
+	if !settings.Enabled {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", a.metrics.registry.Handler())
+
+	listener, err := net.Listen("tcp", settings.ListenAddr)
+	if err != nil {
+		a.log.Printf("applyMetricsSettings: cannot listen on %s: %v\n", settings.ListenAddr, err)
+		return
+	}
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	a.metricsServer = server
+	a.metricsConfig = settings
+	a.log.Printf("applyMetricsSettings: serving metrics on http://%s/metrics\n", listener.Addr())
+
+	// done lets the watcher below exit when this server is replaced rather than waiting
+	// for the app to stop
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.log.Printf("applyMetricsSettings: %v\n", err)
+		}
+	}()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			server.Close()
+		case <-done:
+		}
+	}()
+}
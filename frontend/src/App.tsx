@@ -1,27 +1,75 @@
 import React, { useState, useEffect } from 'react'
 import { Login } from './components/Login'
 import { PlantDetails } from './components/PlantDetails'
-import { ArrowLeft } from 'lucide-react'
-import { GetStoredCredentials, GetPlantList, Authenticate, Logout } from '../wailsjs/go/main/App'
+import { Settings } from './components/Settings'
+import { Accounts } from './components/Accounts'
+import { ArrowLeft, Settings as SettingsIcon } from 'lucide-react'
+import {
+    GetStoredCredentials,
+    GetPlantListPage,
+    GetAccounts,
+    GetGatewayStatus,
+    GetActiveAlerts,
+    SwitchAccount,
+    Authenticate,
+    CancelAuthentication,
+    CompleteManualLogin,
+    Logout
+} from '../wailsjs/go/main/App'
+import { alerts, main } from '../wailsjs/go/models'
+import { EventsOn } from '../wailsjs/runtime/runtime'
+import { errorMessage, isAuthError, isGatewayUnavailable } from './errors'
+
+const PLANT_PAGE_SIZE = 50
 
 function App() {
     const [isAuthenticated, setIsAuthenticated] = useState(false)
     const [isLoading, setIsLoading] = useState(true)
     const [error, setError] = useState<string | null>(null)
+    const [isAuthenticating, setIsAuthenticating] = useState(false)
     const [plants, setPlants] = useState<any[]>([])
+    const [plantPage, setPlantPage] = useState(1)
+    const [hasMorePlants, setHasMorePlants] = useState(false)
+    const [isLoadingMore, setIsLoadingMore] = useState(false)
     const [selectedPlant, setSelectedPlant] = useState<any | null>(null)
+    const [showSettings, setShowSettings] = useState(false)
+    const [accounts, setAccounts] = useState<main.Account[]>([])
+    const [degradedGateways, setDegradedGateways] = useState<main.GatewayStatus[]>([])
+    const [activeAlerts, setActiveAlerts] = useState<alerts.Alert[]>([])
 
     useEffect(() => {
         checkAuth()
     }, [])
 
+    // The backend reports gateways whose circuit breaker has opened and when they recover
+    useEffect(() => {
+        GetGatewayStatus().then((statuses) => setDegradedGateways(statuses.filter((s) => s.degraded)))
+        return EventsOn('gateway:status', (status: main.GatewayStatus) => {
+            setDegradedGateways((prev) => [...prev.filter((s) => s.url !== status.url), ...(status.degraded ? [status] : [])])
+        })
+    }, [])
+
+    // Alert rules are evaluated by the backend after every poll
+    useEffect(() => {
+        GetActiveAlerts().then(setActiveAlerts)
+        return EventsOn('alert', () => {
+            GetActiveAlerts().then(setActiveAlerts)
+        })
+    }, [])
+
     const checkAuth = async () => {
         setIsLoading(true)
         try {
+            setAccounts(await GetAccounts())
             const creds = await GetStoredCredentials()
-            if (creds && creds.accessToken && creds.tokenExpiry && creds.tokenExpiry > Date.now()) {
+            // An expired access token is fine as long as the backend can refresh it
+            const tokenValid = !!creds?.tokenExpiry && creds.tokenExpiry > Date.now()
+            if (creds && creds.accessToken && (tokenValid || creds.refreshToken)) {
                 setIsAuthenticated(true)
                 loadPlants()
+            } else {
+                setIsAuthenticated(false)
+                setPlants([])
             }
         } catch (err) {
             console.error('Auth check failed:', err)
@@ -30,20 +78,44 @@ function App() {
         }
     }
 
-    const loadPlants = async () => {
+    const loadPlants = async (page: number = 1) => {
         try {
-            const plantList = await GetPlantList()
-            console.log('Plants loaded:', plantList)
-            setPlants(plantList || [])
+            const result = await GetPlantListPage(page, PLANT_PAGE_SIZE)
+            const plantList = result?.pageList || []
+            console.log(`Plants page ${page} loaded:`, plantList)
+            setPlants((prev) => (page === 1 ? plantList : [...prev, ...plantList]))
+            setPlantPage(page)
+            // With several accounts a page can hold more than PLANT_PAGE_SIZE plants
+            const loaded = (page === 1 ? 0 : plants.length) + plantList.length
+            setHasMorePlants(!!result && plantList.length > 0 && loaded < result.rowCount)
         } catch (err: any) {
             console.error('Failed to load plants:', err)
-            const errorMsg = err?.message || err?.toString() || 'Unknown error occurred'
-            setError('Failed to load plants: ' + errorMsg)
+            if (isAuthError(err)) {
+                setIsAuthenticated(false)
+                setError('Session expired, please authenticate again: ' + errorMessage(err))
+                return
+            }
+            // Nothing failed on our side, say the gateway is down and when it's retried
+            if (isGatewayUnavailable(err)) {
+                setError(errorMessage(err))
+                return
+            }
+            setError('Failed to load plants: ' + errorMessage(err))
+        }
+    }
+
+    const loadMorePlants = async () => {
+        setIsLoadingMore(true)
+        try {
+            await loadPlants(plantPage + 1)
+        } finally {
+            setIsLoadingMore(false)
         }
     }
 
     const handleLogin = async (credentials: any) => {
         setIsLoading(true)
+        setIsAuthenticating(true)
         setError(null)
         try {
             const result = await Authenticate(credentials)
@@ -54,23 +126,64 @@ function App() {
                 setError(result.message || 'Authentication pending')
             }
         } catch (err: any) {
-            setError(err.message || 'Authentication failed')
+            setError(errorMessage(err) || 'Authentication failed')
         } finally {
             setIsLoading(false)
+            setIsAuthenticating(false)
         }
     }
 
+    const handleManualLogin = async (redirect: string) => {
+        setIsLoading(true)
+        setError(null)
+        try {
+            await CompleteManualLogin(redirect)
+            setIsAuthenticated(true)
+            await loadPlants()
+        } catch (err: any) {
+            setError(errorMessage(err) || 'Authentication failed')
+        } finally {
+            setIsLoading(false)
+        }
+    }
+
+    const handleAccountsChanged = async () => {
+        setSelectedPlant(null)
+        setPlantPage(1)
+        await checkAuth()
+    }
+
+    const handleSwitchAccount = async (id: string) => {
+        setError(null)
+        try {
+            await SwitchAccount(id)
+        } catch (err: any) {
+            setError(errorMessage(err))
+        }
+        setShowSettings(false)
+        await handleAccountsChanged()
+    }
+
+    const accountName = (id: string) => accounts.find((account) => account.id === id)?.name
+
     const handleLogout = async () => {
         await Logout()
         setIsAuthenticated(false)
         setPlants([])
+        setPlantPage(1)
+        setHasMorePlants(false)
         setSelectedPlant(null)
     }
 
     if (isLoading && !isAuthenticated) {
         return (
             <div className="container" style={{ justifyContent: 'center', alignItems: 'center' }}>
-                <div className="status-badge">Loading...</div>
+                <div className="status-badge">{isAuthenticating ? 'Waiting for sign in in your browser...' : 'Loading...'}</div>
+                {isAuthenticating && (
+                    <button onClick={() => CancelAuthentication()} style={{ marginTop: '1rem' }}>
+                        Cancel
+                    </button>
+                )}
             </div>
         )
     }
@@ -79,9 +192,9 @@ function App() {
         <div className="container">
             <header>
                 <div style={{ display: 'flex', alignItems: 'center', gap: '1rem' }}>
-                    {selectedPlant && (
+                    {(selectedPlant || showSettings) && (
                         <button
-                            onClick={() => setSelectedPlant(null)}
+                            onClick={() => (showSettings ? setShowSettings(false) : setSelectedPlant(null))}
                             style={{
                                 background: 'none',
                                 padding: '0.5rem',
@@ -93,9 +206,32 @@ function App() {
                             <ArrowLeft size={20} />
                         </button>
                     )}
-                    <h1>{selectedPlant ? selectedPlant.ps_name : 'Sungrow iSolarCloud'}</h1>
+                    <h1>{showSettings ? 'Settings' : selectedPlant ? selectedPlant.ps_name : 'Sungrow iSolarCloud'}</h1>
                 </div>
                 <div style={{ display: 'flex', alignItems: 'center', gap: '1rem' }}>
+                    {accounts.length > 1 && (
+                        <select
+                            value={accounts.find((account) => account.active)?.id}
+                            onChange={(e) => handleSwitchAccount(e.target.value)}
+                            title="Active account"
+                            style={{ padding: '0.25rem 0.5rem', fontSize: '0.75rem', width: 'auto' }}
+                        >
+                            {accounts.map((account) => (
+                                <option key={account.id} value={account.id}>
+                                    {account.name}
+                                </option>
+                            ))}
+                        </select>
+                    )}
+                    {isAuthenticated && (
+                        <button
+                            onClick={() => setShowSettings(!showSettings)}
+                            title="Settings"
+                            style={{ padding: '0.25rem 0.5rem', display: 'flex', alignItems: 'center' }}
+                        >
+                            <SettingsIcon size={14} />
+                        </button>
+                    )}
                     {isAuthenticated && (
                         <button onClick={handleLogout} style={{ padding: '0.25rem 0.75rem', fontSize: '0.75rem' }}>
                             Logout
@@ -110,15 +246,47 @@ function App() {
                                     backgroundColor: 'rgba(239, 68, 68, 0.1)',
                                     borderColor: 'rgba(239, 68, 68, 0.2)'
                                 }
-                                : {}
+                                : degradedGateways.length > 0
+                                    ? {
+                                        color: '#f59e0b',
+                                        backgroundColor: 'rgba(245, 158, 11, 0.1)',
+                                        borderColor: 'rgba(245, 158, 11, 0.2)'
+                                    }
+                                    : {}
                         }
                     >
-                        {isAuthenticated ? 'Connected' : 'Disconnected'}
+                        {!isAuthenticated ? 'Disconnected' : degradedGateways.length > 0 ? 'Degraded' : 'Connected'}
                     </div>
                 </div>
             </header>
 
             <main>
+                {isAuthenticated && degradedGateways.map((gateway) => (
+                    <div
+                        key={gateway.url}
+                        className="card"
+                        style={{ borderLeft: '4px solid #f59e0b', marginBottom: '1.5rem', padding: '1rem' }}
+                    >
+                        <p style={{ margin: 0, color: '#f59e0b', fontSize: '0.875rem' }}>
+                            The {gateway.name} gateway is not responding
+                            {gateway.retryAt ? `, trying again at ${new Date(gateway.retryAt).toLocaleTimeString()}` : ', checking whether it recovered'}
+                            {gateway.lastError ? ` (${gateway.lastError})` : ''}
+                        </p>
+                    </div>
+                ))}
+                {activeAlerts.map((alert) => (
+                    <div
+                        key={`${alert.ruleId}-${alert.psKey}`}
+                        className="card"
+                        style={{ borderLeft: '4px solid #ef4444', marginBottom: '1.5rem', padding: '1rem' }}
+                    >
+                        <p style={{ margin: 0, color: '#ef4444', fontSize: '0.875rem' }}>
+                            {alert.ruleName}: {alert.deviceName || alert.psKey}
+                            {alert.unit ? ` at ${Math.round(alert.value * 10) / 10}${alert.unit}` : ''}
+                            {` since ${new Date(alert.since).toLocaleTimeString()}`}
+                        </p>
+                    </div>
+                ))}
                 {error && (
                     <div
                         className="card"
@@ -129,35 +297,52 @@ function App() {
                 )}
 
                 {!isAuthenticated ? (
-                    <Login onLogin={handleLogin} isLoading={isLoading} />
+                    <Login onLogin={handleLogin} onManualLogin={handleManualLogin} isLoading={isLoading} />
+                ) : showSettings ? (
+                    <>
+                        <Accounts onChange={handleAccountsChanged} />
+                        <Settings />
+                    </>
                 ) : selectedPlant ? (
                     <PlantDetails plant={selectedPlant} />
                 ) : (
-                    <div
-                        style={{
-                            display: 'grid',
-                            gridTemplateColumns: 'repeat(auto-fill, minmax(300px, 1fr))',
-                            gap: '1.5rem'
-                        }}
-                    >
-                        {plants.map((plant) => (
-                            <div key={plant.ps_id} className="card">
-                                <h3 style={{ margin: '0 0 1rem 0' }}>{plant.ps_name}</h3>
-                                <div style={{ fontSize: '0.875rem', color: '#94a3b8' }}>
-                                    <p>Location: {plant.ps_location}</p>
-                                    <p>Status: {plant.ps_fault_status === 3 ? 'Normal' : 'Attention'}</p>
-                                    <p>Daily Yield: {plant.today_energy || '0'} kWh</p>
+                    <>
+                        <div
+                            style={{
+                                display: 'grid',
+                                gridTemplateColumns: 'repeat(auto-fill, minmax(300px, 1fr))',
+                                gap: '1.5rem'
+                            }}
+                        >
+                            {plants.map((plant) => (
+                                <div key={`${plant.account_id}-${plant.ps_id}`} className="card">
+                                    <h3 style={{ margin: '0 0 1rem 0' }}>{plant.ps_name}</h3>
+                                    <div style={{ fontSize: '0.875rem', color: '#94a3b8' }}>
+                                        {accounts.length > 1 && <p>Account: {accountName(plant.account_id)}</p>}
+                                        <p>Location: {plant.ps_location}</p>
+                                        <p>Status: {plant.ps_fault_status === 3 ? 'Normal' : 'Attention'}</p>
+                                        <p>Daily Yield: {plant.today_energy || '0'} kWh</p>
+                                    </div>
+                                    <button
+                                        style={{ width: '100%', marginTop: '1rem' }}
+                                        onClick={() => setSelectedPlant(plant)}
+                                    >
+                                        View Details
+                                    </button>
                                 </div>
-                                <button
-                                    style={{ width: '100%', marginTop: '1rem' }}
-                                    onClick={() => setSelectedPlant(plant)}
-                                >
-                                    View Details
-                                </button>
-                            </div>
-                        ))}
-                        {plants.length === 0 && <p>No plants found.</p>}
-                    </div>
+                            ))}
+                            {plants.length === 0 && <p>No plants found.</p>}
+                        </div>
+                        {hasMorePlants && (
+                            <button
+                                style={{ display: 'block', margin: '1.5rem auto 0' }}
+                                onClick={loadMorePlants}
+                                disabled={isLoadingMore}
+                            >
+                                {isLoadingMore ? 'Loading...' : 'Load more plants'}
+                            </button>
+                        )}
+                    </>
                 )}
             </main>
         </div>
@@ -0,0 +1,81 @@
+import React, { useState, useEffect } from 'react'
+import { GetAccounts, AddAccount, SwitchAccount, RemoveAccount } from '../../wailsjs/go/main/App'
+import { main } from '../../wailsjs/go/models'
+import { errorMessage } from '../errors'
+
+interface AccountsProps {
+    onChange: () => void
+}
+
+export function Accounts({ onChange }: AccountsProps) {
+    const [accounts, setAccounts] = useState<main.Account[]>([])
+    const [name, setName] = useState('')
+    const [error, setError] = useState<string | null>(null)
+
+    useEffect(() => {
+        GetAccounts().then(setAccounts)
+    }, [])
+
+    const run = async (action: () => Promise<any>) => {
+        setError(null)
+        try {
+            await action()
+            setAccounts(await GetAccounts())
+            onChange()
+        } catch (err: any) {
+            setError(errorMessage(err))
+        }
+    }
+
+    const handleAdd = (e: React.FormEvent) => {
+        e.preventDefault()
+        run(() => AddAccount(name)).then(() => setName(''))
+    }
+
+    const handleRemove = (account: main.Account) => {
+        if (confirm(`Remove ${account.name} and its stored credentials?`)) {
+            run(() => RemoveAccount(account.id))
+        }
+    }
+
+    return (
+        <div className="card" style={{ maxWidth: '480px', margin: '0 auto 1.5rem' }}>
+            <h3 className="section-title">Accounts</h3>
+            {accounts.map((account) => (
+                <div
+                    key={account.id}
+                    style={{ display: 'flex', alignItems: 'center', gap: '0.5rem', marginBottom: '0.5rem' }}
+                >
+                    <div style={{ flex: 1, fontSize: '0.875rem' }}>
+                        <strong>{account.name}</strong>
+                        <div style={{ fontSize: '0.75rem', color: '#94a3b8' }}>
+                            {account.authenticated ? account.gatewayUrl : 'Signed out'}
+                        </div>
+                    </div>
+                    {account.active ? (
+                        <span className="status-badge">Active</span>
+                    ) : (
+                        <button style={{ padding: '0.25rem 0.75rem', fontSize: '0.75rem' }} onClick={() => run(() => SwitchAccount(account.id))}>
+                            Switch
+                        </button>
+                    )}
+                    <button style={{ padding: '0.25rem 0.75rem', fontSize: '0.75rem' }} onClick={() => handleRemove(account)}>
+                        Remove
+                    </button>
+                </div>
+            ))}
+            <form onSubmit={handleAdd} style={{ display: 'flex', gap: '0.5rem', marginTop: '1rem' }}>
+                <input
+                    type="text"
+                    value={name}
+                    onChange={(e) => setName(e.target.value)}
+                    placeholder="New account name"
+                    required
+                    style={{ flex: 1 }}
+                />
+                <button type="submit">Add</button>
+            </form>
+            {error && <p style={{ fontSize: '0.875rem', color: '#ef4444' }}>{error}</p>}
+        </div>
+    )
+}
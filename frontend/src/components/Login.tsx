@@ -1,19 +1,116 @@
-import React, { useState } from 'react'
+import React, { useEffect, useState } from 'react'
+import { BeginManualLogin, DetectGateway, GetGateways } from '../../wailsjs/go/main/App'
+import { isolarcloud, main } from '../../wailsjs/go/models'
+import { BrowserOpenURL } from '../../wailsjs/runtime/runtime'
+import { errorMessage } from '../errors'
 
 interface LoginProps {
     onLogin: (creds: any) => void
+    onManualLogin: (redirect: string) => void
     isLoading: boolean
 }
 
-export function Login({ onLogin, isLoading }: LoginProps) {
+export function Login({ onLogin, onManualLogin, isLoading }: LoginProps) {
     const [appKey, setAppKey] = useState('')
     const [secretKey, setSecretKey] = useState('')
-    const [authUrl, setAuthUrl] = useState('https://auapi.isolarcloud.com:443/openapi/apiManage/token')
-    const [gatewayUrl, setGatewayUrl] = useState('https://augateway.isolarcloud.com')
+    const [gateways, setGateways] = useState<isolarcloud.Gateway[]>([])
+    const [authUrl, setAuthUrl] = useState('')
+    const [gatewayUrl, setGatewayUrl] = useState('')
+    const [detecting, setDetecting] = useState(false)
+    const [detectMessage, setDetectMessage] = useState<string | null>(null)
+    const [manual, setManual] = useState(false)
+    const [loginUrl, setLoginUrl] = useState<string | null>(null)
+    const [redirect, setRedirect] = useState('')
+    const [manualError, setManualError] = useState<string | null>(null)
 
-    const handleSubmit = (e: React.FormEvent) => {
+    useEffect(() => {
+        GetGateways().then((list) => {
+            setGateways(list)
+            // The default region is listed first
+            if (list.length > 0) {
+                selectGateway(list[0])
+            }
+        })
+    }, [])
+
+    const selectGateway = (gateway: isolarcloud.Gateway) => {
+        setGatewayUrl(gateway.baseUrl)
+        setAuthUrl(gateway.authUrl)
+    }
+
+    const handleGatewayChange = (baseUrl: string) => {
+        const gateway = gateways.find((g) => g.baseUrl === baseUrl)
+        if (gateway) {
+            selectGateway(gateway)
+        }
+        setDetectMessage(null)
+    }
+
+    const handleDetect = async () => {
+        setDetecting(true)
+        setDetectMessage(null)
+        try {
+            const gateway = await DetectGateway(appKey, secretKey)
+            selectGateway(gateway)
+            setDetectMessage(`Found your app on the ${gateway.name} gateway`)
+        } catch (err: any) {
+            setDetectMessage(errorMessage(err))
+        } finally {
+            setDetecting(false)
+        }
+    }
+
+    const handleSubmit = async (e: React.FormEvent) => {
+        e.preventDefault()
+        if (!manual) {
+            onLogin({ appKey, secretKey, authUrl, gatewayUrl })
+            return
+        }
+
+        setManualError(null)
+        try {
+            setLoginUrl(await BeginManualLogin(main.Credentials.createFrom({ appKey, secretKey, authUrl, gatewayUrl }), ''))
+        } catch (err: any) {
+            setManualError(errorMessage(err))
+        }
+    }
+
+    const handleManualSubmit = (e: React.FormEvent) => {
         e.preventDefault()
-        onLogin({ appKey, secretKey, authUrl, gatewayUrl })
+        onManualLogin(redirect)
+    }
+
+    if (loginUrl) {
+        return (
+            <div className="card" style={{ maxWidth: '400px', margin: '2rem auto' }}>
+                <h2 style={{ marginBottom: '1.5rem', textAlign: 'center' }}>Connect to Sungrow</h2>
+                <p style={{ fontSize: '0.875rem' }}>
+                    Open this address in a browser on any device and sign in:
+                </p>
+                <textarea readOnly value={loginUrl} rows={4} style={{ width: '100%', fontSize: '0.75rem' }} />
+                <button type="button" style={{ width: '100%', marginBottom: '1rem' }} onClick={() => BrowserOpenURL(loginUrl)}>
+                    Open in browser
+                </button>
+                <form onSubmit={handleManualSubmit}>
+                    <div className="input-group">
+                        <label>Redirected address or code</label>
+                        <input
+                            type="text"
+                            value={redirect}
+                            onChange={(e) => setRedirect(e.target.value)}
+                            required
+                            placeholder="http://localhost:8080/callback?code=..."
+                        />
+                    </div>
+                    <button type="submit" style={{ width: '100%', marginTop: '1rem' }} disabled={isLoading}>
+                        {isLoading ? 'Authenticating...' : 'Complete sign in'}
+                    </button>
+                </form>
+                <p style={{ marginTop: '1rem', fontSize: '0.75rem', color: '#94a3b8', textAlign: 'center' }}>
+                    The browser ends up on a page that won't load. Copy its full address from the address bar.
+                </p>
+            </div>
+        )
     }
 
     return (
@@ -51,19 +148,39 @@ export function Login({ onLogin, isLoading }: LoginProps) {
                 </div>
                 <div className="input-group">
                     <label>Country / Gateway</label>
-                    <select
-                        value={gatewayUrl}
-                        onChange={(e) => setGatewayUrl(e.target.value)}
-                        required
-                    >
-                        <option value="https://augateway.isolarcloud.com">Australia</option>
-                        <option value="https://gateway.isolarcloud.com">China</option>
-                        <option value="https://gateway.isolarcloud.com.hk">International</option>
-                        <option value="https://gateway.isolarcloud.eu">Europe</option>
-                    </select>
+                    <div style={{ display: 'flex', gap: '0.5rem' }}>
+                        <select
+                            value={gatewayUrl}
+                            onChange={(e) => handleGatewayChange(e.target.value)}
+                            required
+                        >
+                            {gateways.map((g) => (
+                                <option key={g.id} value={g.baseUrl}>{g.name}</option>
+                            ))}
+                        </select>
+                        <button
+                            type="button"
+                            onClick={handleDetect}
+                            disabled={detecting || !appKey || !secretKey}
+                            title="Try the keys against every region"
+                        >
+                            {detecting ? 'Detecting...' : 'Detect'}
+                        </button>
+                    </div>
+                    {detectMessage && <p style={{ fontSize: '0.75rem', color: '#94a3b8' }}>{detectMessage}</p>}
+                </div>
+                <div className="input-group" style={{ display: 'flex', alignItems: 'center', gap: '0.5rem' }}>
+                    <input
+                        type="checkbox"
+                        checked={manual}
+                        onChange={(e) => setManual(e.target.checked)}
+                        style={{ width: 'auto' }}
+                    />
+                    <label style={{ margin: 0 }}>Paste the redirect address instead of waiting for it</label>
                 </div>
+                {manualError && <p style={{ fontSize: '0.875rem', color: '#ef4444' }}>{manualError}</p>}
                 <button type="submit" style={{ width: '100%', marginTop: '1rem' }} disabled={isLoading}>
-                    {isLoading ? 'Authenticating...' : 'Authenticate'}
+                    {isLoading ? 'Authenticating...' : manual ? 'Get sign in address' : 'Authenticate'}
                 </button>
             </form>
             <p style={{ marginTop: '1.5rem', fontSize: '0.75rem', color: '#94a3b8', textAlign: 'center' }}>
@@ -1,5 +1,13 @@
-import React from 'react'
+import React, { useEffect, useState } from 'react'
 import { PlantDeviceList } from './PlantDeviceList'
+import { GetPlantAlarms, GetStatusEvents } from '../../wailsjs/go/main/App'
+import { isolarcloud, status } from '../../wailsjs/go/models'
+import { EventsOn } from '../../wailsjs/runtime/runtime'
+import { errorMessage } from '../errors'
+
+const STATUS_EVENT_LIMIT = 10
+const ALARM_PAGE_SIZE = 20
+const ALARM_LOOKBACK_MS = 7 * 24 * 60 * 60 * 1000
 
 const PLANT_TYPES: Record<number, string> = {
     1: 'Utility Plant',
@@ -28,6 +36,34 @@ interface PlantDetailsProps {
 }
 
 export function PlantDetails({ plant }: PlantDetailsProps) {
+    const [events, setEvents] = useState<status.Event[]>([])
+    const [alarms, setAlarms] = useState<isolarcloud.Alarm[]>([])
+    const [alarmError, setAlarmError] = useState<string | null>(null)
+
+    // The gateway's fault log explains why the fault status changed
+    useEffect(() => {
+        GetPlantAlarms(plant.ps_id, Date.now() - ALARM_LOOKBACK_MS, 0, false, 1, ALARM_PAGE_SIZE)
+            .then((page) => {
+                setAlarms(page.pageList)
+                setAlarmError(null)
+            })
+            .catch((err) => setAlarmError(errorMessage(err)))
+    }, [plant.ps_id])
+
+    // Status changes are journaled by the backend as plants and devices are listed
+    useEffect(() => {
+        const load = () =>
+            GetStatusEvents(status.Query.createFrom({ psId: plant.ps_id, limit: STATUS_EVENT_LIMIT }))
+                .then(setEvents)
+                .catch(() => setEvents([]))
+        load()
+        return EventsOn('status:event', (event: status.Event) => {
+            if (event.psId === plant.ps_id) {
+                load()
+            }
+        })
+    }, [plant.ps_id])
+
     return (
         <div className="plant-details-view">
             <div className="plant-info-grid card">
@@ -40,6 +76,35 @@ export function PlantDetails({ plant }: PlantDetailsProps) {
                 <DetailRow label="Installed" value={plant.install_date?.split(' ')[0] || '-'} />
             </div>
 
+            {events.length > 0 && (
+                <div className="plant-info-grid card">
+                    {events.map((event, i) => (
+                        <DetailRow
+                            key={`${event.timestamp}-${i}`}
+                            label={new Date(event.timestamp).toLocaleString()}
+                            value={event.message}
+                        />
+                    ))}
+                </div>
+            )}
+
+            {(alarms.length > 0 || alarmError) && (
+                <div className="plant-info-grid card">
+                    {alarmError && <p style={{ margin: 0, color: '#ef4444', fontSize: '0.875rem' }}>Faults: {alarmError}</p>}
+                    {alarms.map((alarm) => (
+                        <div key={`${alarm.id}-${alarm.startTime}`} className="detail-row" title={alarm.advice || undefined}>
+                            <span className="detail-label">
+                                {new Date(alarm.startTime).toLocaleString()} · {alarm.deviceName || alarm.psKey}
+                            </span>
+                            <span className={`detail-value ${alarm.active ? 'status-err' : ''}`}>
+                                {alarm.name || `Fault ${alarm.code}`} ({alarm.levelName}){alarm.description ? `: ${alarm.description}` : ''}
+                                {alarm.active ? ' · active' : ` · cleared ${new Date(alarm.endTime!).toLocaleString()}`}
+                            </span>
+                        </div>
+                    ))}
+                </div>
+            )}
+
             <PlantDeviceList ps_id={plant.ps_id} />
         </div>
     )
@@ -1,6 +1,12 @@
-import React, { useState, useEffect } from 'react'
+import React, { useState, useEffect, useRef } from 'react'
 import { Battery } from 'lucide-react'
-import { GetDevicePointData, UpdateTrayStatus } from '../../wailsjs/go/main/App'
+import { BackfillHistory, GetPointCatalogue, GetPointHistory, GetWatchedDevices, UnwatchDevice, WatchDevice } from '../../wailsjs/go/main/App'
+import { isolarcloud, main } from '../../wailsjs/go/models'
+import { EventsOn } from '../../wailsjs/runtime/runtime'
+import { Sparkline } from './Sparkline'
+
+const SOC_KEY = 'battery_soc'
+const HISTORY_WINDOW_MS = 24 * 60 * 60 * 1000
 
 interface PlantDeviceType {
     device_type: number
@@ -8,6 +14,7 @@ interface PlantDeviceType {
     dev_fault_status: number
     type_name: string
     ps_key: string
+    ps_id: number
 }
 
 interface PlantDeviceBatteryProps {
@@ -17,40 +24,121 @@ interface PlantDeviceBatteryProps {
 export function PlantDeviceBattery({ device }: PlantDeviceBatteryProps) {
     const [soc, setSoc] = useState<number | null>(null)
     const [loading, setLoading] = useState(true)
+    const [history, setHistory] = useState<number[]>([])
+    const [inTray, setInTray] = useState(false)
+    // The device's poller target once known, and whether this view added it and so
+    // stops it on unmount. Targets the user chose for the tray, or that were watched
+    // before the view opened, are left running.
+    const target = useRef<main.PollTarget | null>(null)
+    const watchedBefore = useRef(false)
+    const ownedByView = useRef(false)
 
-    useEffect(() => {
-        async function fetchSoc() {
-            try {
-                const data = await GetDevicePointData(device.device_type, device.ps_key, [58604])
-
-                if (data && data.length > 0) {
-                    const pointData = data[0]
-                    const socValue = pointData['p58604']
-                    if (socValue !== undefined && socValue !== null) {
-                        const val = Math.round(parseFloat(socValue) * 1000) / 10
-                        setSoc(val)
-                        // Update tray status with battery percentage
-                        const trayTitle = `Battery: ${Math.round(val)}%`
-                        console.log('Updating tray status:', trayTitle)
-                        UpdateTrayStatus(Math.round(val), trayTitle)
-                    }
-                }
-            } catch (error) {
-                console.error('Failed to fetch battery SOC:', error)
-            } finally {
+    // History holds raw values, scaled here to the catalogue unit like live readings
+    const loadHistory = async (point: isolarcloud.PointDef, backfill: boolean = false) => {
+        try {
+            const now = Date.now()
+            let points = await GetPointHistory(device.ps_key, point.id, now - HISTORY_WINDOW_MS, now, '5m')
+            // Nothing recorded locally yet, fetch the last day from the gateway
+            if (backfill && (!points || points.length < 2)) {
+                await BackfillHistory(device.ps_key, [point.id], now - HISTORY_WINDOW_MS, now)
+                points = await GetPointHistory(device.ps_key, point.id, now - HISTORY_WINDOW_MS, now, '5m')
+            }
+            const values = (points || []).map((p) => p.value * point.scale)
+            setHistory(values)
+            // Until the poller next reports, show the latest recorded charge
+            if (values.length > 0) {
+                setSoc((current) => current ?? Math.round(values[values.length - 1] * 10) / 10)
                 setLoading(false)
             }
+        } catch (error) {
+            console.error('Failed to load battery history:', error)
         }
+    }
+
+    useEffect(() => {
+        let point: isolarcloud.PointDef | undefined
+
+        // The backend poller owns the refresh schedule and keeps a tray battery updated
+        // even while this view is hidden, we only listen for its results
+        const off = EventsOn('poller:result', (result: any) => {
+            if (result.psKey !== device.ps_key) return
+            setLoading(false)
+            if (result.error) {
+                console.error('Failed to fetch battery SOC:', result.error)
+                return
+            }
+            const reading = result.readings?.find((r: isolarcloud.Reading) => r.key === SOC_KEY)
+            if (reading) {
+                setSoc(Math.round(reading.value * 10) / 10)
+                if (point) loadHistory(point)
+            }
+        })
+
+        let unmounted = false
+        Promise.all([GetPointCatalogue(), GetWatchedDevices()])
+            .then(([catalogue, watched]) => {
+                point = catalogue.find((p) => p.key === SOC_KEY)
+                if (!point) throw new Error(`${SOC_KEY} is missing from the point catalogue`)
+                loadHistory(point, true)
 
-        fetchSoc()
+                // The first battery viewed drives the tray until the user picks another
+                const claimTray = !(watched || []).some((t) => t.tray)
 
-        // Refresh every 5 minutes
-        const interval = setInterval(fetchSoc, 5 * 60 * 1000)
+                // Leave an existing target alone, it may be the tray's or from settings
+                const existing = (watched || []).find((t) => t.psKey === device.ps_key)
+                watchedBefore.current = !!existing
+                const hasPoint = !!existing && existing.pointIds.includes(point.id)
+                if (existing && hasPoint && !claimTray) {
+                    target.current = existing
+                    setInTray(existing.tray)
+                    return
+                }
+                if (unmounted) return
+                target.current = {
+                    psId: device.ps_id,
+                    psKey: device.ps_key,
+                    accountId: existing?.accountId,
+                    deviceType: device.device_type,
+                    deviceName: device.device_name,
+                    pointIds: existing && hasPoint ? existing.pointIds : [...(existing?.pointIds || []), point.id],
+                    tray: existing?.tray || claimTray,
+                    intervalSeconds: existing?.intervalSeconds
+                }
+                setInTray(target.current.tray)
+                // A tray battery keeps being polled after the view closes
+                ownedByView.current = !existing && !target.current.tray
+                return WatchDevice(target.current)
+            })
+            .catch((error) => {
+                console.error('Failed to watch battery:', error)
+                setLoading(false)
+            })
 
-        // Cleanup interval on unmount
-        return () => clearInterval(interval)
+        return () => {
+            unmounted = true
+            off()
+            if (ownedByView.current) {
+                ownedByView.current = false
+                UnwatchDevice(device.ps_key).catch((error) => console.error('Failed to stop watching battery:', error))
+            }
+        }
     }, [device.ps_key, device.device_type])
 
+    // Switches the tray to this battery, or takes it out. A tray battery stays polled
+    // after the view closes until it's taken out again.
+    const toggleTray = async () => {
+        if (!target.current) return
+        const next = { ...target.current, tray: !inTray }
+        try {
+            await WatchDevice(next)
+            target.current = next
+            ownedByView.current = !next.tray && !watchedBefore.current
+            setInTray(next.tray)
+        } catch (error) {
+            console.error('Failed to change the tray battery:', error)
+        }
+    }
+
     return (
         <div className="device-card battery">
             <div className="device-icon">
@@ -72,7 +160,11 @@ export function PlantDeviceBattery({ device }: PlantDeviceBatteryProps) {
                     <span>{device.type_name}</span>
                     <span className="separator">|</span>
                     <span className="mono">{device.ps_key}</span>
+                    <button style={{ marginLeft: 'auto', padding: '0.15rem 0.5rem', fontSize: '0.7rem' }} onClick={toggleTray}>
+                        {inTray ? 'Shown in tray' : 'Show in tray'}
+                    </button>
                 </div>
+                <Sparkline values={history} min={0} max={100} />
             </div>
         </div>
     )
@@ -3,6 +3,7 @@ import { PlantDevice } from './PlantDevice'
 import { PlantDeviceBattery } from './PlantDeviceBattery'
 import { Layers } from 'lucide-react'
 import { GetDeviceList } from '../../wailsjs/go/main/App'
+import { errorMessage } from '../errors'
 
 interface PlantDeviceType {
     uuid: number
@@ -45,7 +46,7 @@ export function PlantDeviceList({ ps_id }: PlantDeviceListProps) {
             setDevices(deviceList || [])
         } catch (e: any) {
             console.error('Failed to load devices', e)
-            setError(e.message || 'Failed to load devices')
+            setError(errorMessage(e) || 'Failed to load devices')
         } finally {
             setIsLoading(false)
         }
@@ -0,0 +1,494 @@
+import React, { useState, useEffect } from 'react'
+import {
+    ClearSecretSetting,
+    GetPointCatalogue,
+    GetSettings,
+    PreviewTrayIcon,
+    SaveSettings,
+    SendTestWebhook
+} from '../../wailsjs/go/main/App'
+import { alerts, isolarcloud, main, trayicon } from '../../wailsjs/go/models'
+import { errorMessage } from '../errors'
+
+export function Settings() {
+    const [settings, setSettings] = useState<main.Settings | null>(null)
+    const [status, setStatus] = useState<string | null>(null)
+    const [isSaving, setIsSaving] = useState(false)
+    const [catalogue, setCatalogue] = useState<isolarcloud.PointDef[]>([])
+    const [webhookStatus, setWebhookStatus] = useState<string | null>(null)
+
+    useEffect(() => {
+        GetSettings().then(setSettings)
+        GetPointCatalogue().then(setCatalogue)
+    }, [])
+
+    if (!settings) {
+        return <div className="loading-compact">Loading settings...</div>
+    }
+
+    const update = <K extends keyof main.Settings>(section: K, field: keyof main.Settings[K], value: any) => {
+        setSettings(main.Settings.createFrom({ ...settings, [section]: { ...settings[section], [field]: value } }))
+    }
+
+    const rules = settings.alerts.rules || []
+    const updateRule = (index: number, field: keyof alerts.Rule, value: any) => {
+        update('alerts', 'rules', rules.map((r, i) => (i === index ? alerts.Rule.createFrom({ ...r, [field]: value }) : r)))
+    }
+    const addRule = () => {
+        update('alerts', 'rules', [
+            ...rules,
+            alerts.Rule.createFrom({
+                name: 'New alert',
+                enabled: true,
+                condition: 'below',
+                point: catalogue[0]?.key ?? '',
+                threshold: 0,
+                hysteresis: 0,
+                delaySeconds: 0,
+                cooldownSeconds: 3600
+            })
+        ])
+    }
+
+    const sendTestWebhook = async () => {
+        setWebhookStatus('Sending...')
+        try {
+            await SendTestWebhook(settings.webhook)
+            setWebhookStatus('Test event delivered')
+        } catch (err: any) {
+            setWebhookStatus('Test failed: ' + errorMessage(err))
+        }
+    }
+
+    // Saving with a secret field left empty keeps the stored secret, so clearing is explicit
+    const clearSecret = async (key: string) => {
+        setStatus(null)
+        try {
+            await ClearSecretSetting(key)
+            setSettings(await GetSettings())
+            setStatus('Secret removed')
+        } catch (err: any) {
+            setStatus('Failed to remove secret: ' + errorMessage(err))
+        }
+    }
+
+    const handleSubmit = async (e: React.FormEvent) => {
+        e.preventDefault()
+        setIsSaving(true)
+        setStatus(null)
+        try {
+            await SaveSettings(settings)
+            setSettings(await GetSettings())
+            setStatus('Settings saved')
+        } catch (err: any) {
+            setStatus('Failed to save settings: ' + errorMessage(err))
+        } finally {
+            setIsSaving(false)
+        }
+    }
+
+    return (
+        <div className="card" style={{ maxWidth: '480px', margin: '0 auto' }}>
+            <h2 style={{ marginBottom: '1.5rem' }}>Settings</h2>
+            <form onSubmit={handleSubmit}>
+                <h3 className="section-title">Background Refresh</h3>
+                <NumberField
+                    label="Refresh interval (seconds)"
+                    value={settings.poller.intervalSeconds}
+                    min={30}
+                    onChange={(v) => update('poller', 'intervalSeconds', v)}
+                />
+                <NumberField
+                    label="Jitter (± seconds)"
+                    value={settings.poller.jitterSeconds}
+                    min={0}
+                    onChange={(v) => update('poller', 'jitterSeconds', v)}
+                />
+                <NumberField
+                    label="Maximum retry backoff (seconds)"
+                    value={settings.poller.maxBackoffSeconds}
+                    min={30}
+                    onChange={(v) => update('poller', 'maxBackoffSeconds', v)}
+                />
+                <p style={{ fontSize: '0.75rem', color: '#94a3b8' }}>
+                    Watching {settings.poller.targets?.length || 0} device(s)
+                </p>
+
+                <h3 className="section-title">History</h3>
+                <NumberField
+                    label="Keep every sample for (days)"
+                    value={settings.history.rawRetentionDays}
+                    min={1}
+                    onChange={(v) => update('history', 'rawRetentionDays', v)}
+                />
+                <NumberField
+                    label="Keep 5 minute averages for (days)"
+                    value={settings.history.retentionDays}
+                    min={1}
+                    onChange={(v) => update('history', 'retentionDays', v)}
+                />
+
+                <h3 className="section-title">Alerts</h3>
+                <CheckboxField
+                    label="Show desktop notifications"
+                    checked={settings.alerts.notifications}
+                    onChange={(v) => update('alerts', 'notifications', v)}
+                />
+                {rules.map((rule, i) => (
+                    <AlertRuleFields
+                        key={rule.id || i}
+                        rule={rule}
+                        catalogue={catalogue}
+                        onChange={(field, value) => updateRule(i, field, value)}
+                        onRemove={() => update('alerts', 'rules', rules.filter((_, j) => j !== i))}
+                    />
+                ))}
+                <button type="button" onClick={addRule} style={{ marginBottom: '1rem' }}>
+                    Add alert
+                </button>
+
+                <h3 className="section-title">Tray Icon</h3>
+                <TrayIconFields options={settings.tray} onChange={(field, value) => update('tray', field, value)} />
+
+                <h3 className="section-title">Sign In</h3>
+                <NumberField
+                    label="Wait for browser sign in (seconds)"
+                    value={settings.auth.callbackTimeoutSeconds}
+                    min={30}
+                    onChange={(v) => update('auth', 'callbackTimeoutSeconds', v)}
+                />
+
+                <h3 className="section-title">Prometheus Exporter</h3>
+                <CheckboxField
+                    label="Serve /metrics"
+                    checked={settings.metrics.enabled}
+                    onChange={(v) => update('metrics', 'enabled', v)}
+                />
+                <TextField
+                    label="Listen address"
+                    value={settings.metrics.listenAddr}
+                    onChange={(v) => update('metrics', 'listenAddr', v)}
+                />
+
+                <h3 className="section-title">MQTT</h3>
+                <CheckboxField
+                    label="Publish to MQTT broker"
+                    checked={settings.mqtt.enabled}
+                    onChange={(v) => update('mqtt', 'enabled', v)}
+                />
+                <TextField
+                    label="Broker"
+                    value={settings.mqtt.broker}
+                    placeholder="tcp://homeassistant.local:1883"
+                    onChange={(v) => update('mqtt', 'broker', v)}
+                />
+                <TextField
+                    label="Username"
+                    value={settings.mqtt.username}
+                    onChange={(v) => update('mqtt', 'username', v)}
+                />
+                <TextField
+                    label="Password"
+                    type="password"
+                    value={settings.mqtt.password ?? ''}
+                    onChange={(v) => update('mqtt', 'password', v)}
+                />
+                {settings.storedSecrets?.includes('mqttPassword') && (
+                    <button type="button" onClick={() => clearSecret('mqttPassword')} style={{ marginBottom: '1rem' }}>
+                        Remove saved password
+                    </button>
+                )}
+                <TextField
+                    label="Topic prefix"
+                    value={settings.mqtt.topicPrefix}
+                    onChange={(v) => update('mqtt', 'topicPrefix', v)}
+                />
+                <CheckboxField
+                    label="Home Assistant discovery"
+                    checked={settings.mqtt.discovery}
+                    onChange={(v) => update('mqtt', 'discovery', v)}
+                />
+                <TextField
+                    label="Discovery prefix"
+                    value={settings.mqtt.discoveryPrefix}
+                    onChange={(v) => update('mqtt', 'discoveryPrefix', v)}
+                />
+
+                <h3 className="section-title">Webhook</h3>
+                <CheckboxField
+                    label="Post events to a webhook"
+                    checked={settings.webhook.enabled}
+                    onChange={(v) => update('webhook', 'enabled', v)}
+                />
+                <TextField
+                    label="URL"
+                    value={settings.webhook.url}
+                    placeholder="https://example.com/hooks/solar"
+                    onChange={(v) => update('webhook', 'url', v)}
+                />
+                <TextField
+                    label="Signing secret"
+                    type="password"
+                    value={settings.webhook.secret ?? ''}
+                    onChange={(v) => update('webhook', 'secret', v)}
+                />
+                {settings.storedSecrets?.includes('webhookSecret') && (
+                    <button type="button" onClick={() => clearSecret('webhookSecret')} style={{ marginBottom: '1rem' }}>
+                        Remove saved secret
+                    </button>
+                )}
+                <div className="input-group">
+                    <label>Body template (empty sends the event as JSON)</label>
+                    <textarea
+                        value={settings.webhook.template}
+                        rows={4}
+                        placeholder={'{"text": {{json .data.message}}}'}
+                        style={{ width: '100%', fontFamily: 'monospace', fontSize: '0.75rem' }}
+                        onChange={(e) => update('webhook', 'template', e.target.value)}
+                    />
+                </div>
+                <CheckboxField
+                    label="Alerts"
+                    checked={settings.webhook.alerts}
+                    onChange={(v) => update('webhook', 'alerts', v)}
+                />
+                <CheckboxField
+                    label="Status changes"
+                    checked={settings.webhook.statusChanges}
+                    onChange={(v) => update('webhook', 'statusChanges', v)}
+                />
+                <button type="button" onClick={sendTestWebhook} disabled={!settings.webhook.url} style={{ marginBottom: '0.5rem' }}>
+                    Send test
+                </button>
+                {webhookStatus && <p style={{ fontSize: '0.875rem' }}>{webhookStatus}</p>}
+
+                <button type="submit" style={{ width: '100%', marginTop: '1rem' }} disabled={isSaving}>
+                    {isSaving ? 'Saving...' : 'Save'}
+                </button>
+                {status && <p style={{ fontSize: '0.875rem', textAlign: 'center' }}>{status}</p>}
+            </form>
+        </div>
+    )
+}
+
+// trayPreviewStates are the battery states shown while choosing a tray icon style
+const trayPreviewStates = [
+    trayicon.State.createFrom({ percentage: 12, flow: 'discharging' }),
+    trayicon.State.createFrom({ percentage: 45, flow: 'charging' }),
+    trayicon.State.createFrom({ percentage: 90, flow: 'idle' })
+]
+
+const trayPaletteLabels: [keyof trayicon.Palette, string][] = [
+    ['low', 'Low charge'],
+    ['medium', 'Medium charge'],
+    ['high', 'High charge'],
+    ['empty', 'Empty and outline'],
+    ['text', 'Digits'],
+    ['charging', 'Charging arrow'],
+    ['discharging', 'Discharging arrow']
+]
+
+function TrayIconFields({
+    options,
+    onChange
+}: {
+    options: trayicon.Options
+    onChange: (field: keyof trayicon.Options, value: any) => void
+}) {
+    const [previews, setPreviews] = useState<string[]>([])
+    const [previewError, setPreviewError] = useState<string | null>(null)
+
+    useEffect(() => {
+        // []byte arrives as base64
+        Promise.all(trayPreviewStates.map((state) => PreviewTrayIcon(options, state) as unknown as Promise<string>))
+            .then((images) => {
+                setPreviews(images)
+                setPreviewError(null)
+            })
+            .catch((err) => setPreviewError(errorMessage(err)))
+    }, [options])
+
+    return (
+        <>
+            <div style={{ display: 'flex', gap: '1rem', alignItems: 'center', marginBottom: '1rem' }}>
+                {previews.map((png, i) => (
+                    <img
+                        key={i}
+                        src={`data:image/png;base64,${png}`}
+                        alt={`${trayPreviewStates[i].percentage}% ${trayPreviewStates[i].flow}`}
+                        style={{ width: '48px', height: '48px', imageRendering: 'pixelated' }}
+                    />
+                ))}
+                {previewError && <span style={{ fontSize: '0.875rem', color: '#f87171' }}>{previewError}</span>}
+            </div>
+            <div className="input-group">
+                <label>Style</label>
+                <select value={options.style} onChange={(e) => onChange('style', e.target.value)}>
+                    <option value="pie">Pie</option>
+                    <option value="bar">Battery bar</option>
+                    <option value="text">Percentage</option>
+                    <option value="flow">Charge ring with charge/discharge arrow</option>
+                </select>
+            </div>
+            <div className="input-group">
+                <label>Size</label>
+                <select value={options.size} onChange={(e) => onChange('size', parseInt(e.target.value, 10))}>
+                    <option value={0}>Automatic</option>
+                    <option value={16}>16 px</option>
+                    <option value={32}>32 px</option>
+                    <option value={64}>64 px (HiDPI)</option>
+                </select>
+            </div>
+            <CheckboxField label="Smooth edges" checked={options.antialias} onChange={(v) => onChange('antialias', v)} />
+            <NumberField label="Low charge at or below (%)" value={options.low} min={0} onChange={(v) => onChange('low', v)} />
+            <NumberField
+                label="Medium charge at or below (%)"
+                value={options.medium}
+                min={0}
+                onChange={(v) => onChange('medium', v)}
+            />
+            {trayPaletteLabels.map(([field, label]) => (
+                <TextField
+                    key={field}
+                    label={label}
+                    type="color"
+                    value={options.palette[field].slice(0, 7)}
+                    onChange={(v) => onChange('palette', trayicon.Palette.createFrom({ ...options.palette, [field]: v }))}
+                />
+            ))}
+        </>
+    )
+}
+
+function AlertRuleFields({
+    rule,
+    catalogue,
+    onChange,
+    onRemove
+}: {
+    rule: alerts.Rule
+    catalogue: isolarcloud.PointDef[]
+    onChange: (field: keyof alerts.Rule, value: any) => void
+    onRemove: () => void
+}) {
+    const unit = catalogue.find((p) => p.key === rule.point)?.unit ?? ''
+    return (
+        <div style={{ borderLeft: '2px solid #334155', paddingLeft: '0.75rem', marginBottom: '1rem' }}>
+            <CheckboxField label="Enabled" checked={rule.enabled} onChange={(v) => onChange('enabled', v)} />
+            <TextField label="Name" value={rule.name} onChange={(v) => onChange('name', v)} />
+            <div className="input-group">
+                <label>Condition</label>
+                <select value={rule.condition} onChange={(e) => onChange('condition', e.target.value)}>
+                    <option value="below">Point below threshold</option>
+                    <option value="above">Point above threshold</option>
+                    <option value="offline">Device offline</option>
+                </select>
+            </div>
+            {rule.condition !== 'offline' && (
+                <>
+                    <div className="input-group">
+                        <label>Point</label>
+                        <select value={rule.point} onChange={(e) => onChange('point', e.target.value)}>
+                            {catalogue.map((p) => (
+                                <option key={p.key} value={p.key}>{p.name}</option>
+                            ))}
+                        </select>
+                    </div>
+                    <NumberField
+                        label={`Threshold${unit ? ` (${unit})` : ''}`}
+                        value={rule.threshold}
+                        step="any"
+                        onChange={(v) => onChange('threshold', v)}
+                    />
+                    <NumberField
+                        label="Hysteresis, how far back past the threshold to clear"
+                        value={rule.hysteresis}
+                        min={0}
+                        step="any"
+                        onChange={(v) => onChange('hysteresis', v)}
+                    />
+                </>
+            )}
+            <TextField
+                label="Device ps_key (blank for every watched device)"
+                value={rule.psKey ?? ''}
+                onChange={(v) => onChange('psKey', v)}
+            />
+            <NumberField
+                label="Only alert after (seconds)"
+                value={rule.delaySeconds}
+                min={0}
+                onChange={(v) => onChange('delaySeconds', v)}
+            />
+            <NumberField
+                label="Notify at most every (seconds)"
+                value={rule.cooldownSeconds}
+                min={0}
+                onChange={(v) => onChange('cooldownSeconds', v)}
+            />
+            <button type="button" onClick={onRemove}>
+                Remove alert
+            </button>
+        </div>
+    )
+}
+
+export function NumberField({
+    label,
+    value,
+    min,
+    step,
+    onChange
+}: {
+    label: string
+    value: number
+    min?: number
+    step?: string
+    onChange: (value: number) => void
+}) {
+    const parse = step === 'any' ? parseFloat : (v: string) => parseInt(v, 10)
+    return (
+        <div className="input-group">
+            <label>{label}</label>
+            <input type="number" value={value} min={min} step={step} onChange={(e) => onChange(parse(e.target.value) || 0)} />
+        </div>
+    )
+}
+
+export function TextField({
+    label,
+    value,
+    type = 'text',
+    placeholder,
+    onChange
+}: {
+    label: string
+    value: string
+    type?: string
+    placeholder?: string
+    onChange: (value: string) => void
+}) {
+    return (
+        <div className="input-group">
+            <label>{label}</label>
+            <input type={type} value={value || ''} placeholder={placeholder} onChange={(e) => onChange(e.target.value)} />
+        </div>
+    )
+}
+
+export function CheckboxField({
+    label,
+    checked,
+    onChange
+}: {
+    label: string
+    checked: boolean
+    onChange: (checked: boolean) => void
+}) {
+    return (
+        <div className="input-group" style={{ display: 'flex', alignItems: 'center', gap: '0.5rem' }}>
+            <input type="checkbox" checked={checked} onChange={(e) => onChange(e.target.checked)} style={{ width: 'auto' }} />
+            <label style={{ margin: 0 }}>{label}</label>
+        </div>
+    )
+}
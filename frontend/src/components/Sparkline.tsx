@@ -0,0 +1,30 @@
+import React from 'react'
+
+interface SparklineProps {
+    values: number[]
+    width?: number
+    height?: number
+    min?: number
+    max?: number
+}
+
+// Minimal inline SVG line chart for recent point history
+export function Sparkline({ values, width = 120, height = 24, min, max }: SparklineProps) {
+    if (values.length < 2) {
+        return null
+    }
+
+    const lo = min ?? Math.min(...values)
+    const hi = max ?? Math.max(...values)
+    const range = hi - lo || 1
+    const step = width / (values.length - 1)
+    const points = values
+        .map((v, i) => `${(i * step).toFixed(1)},${(height - ((v - lo) / range) * height).toFixed(1)}`)
+        .join(' ')
+
+    return (
+        <svg className="sparkline" width={width} height={height} viewBox={`0 0 ${width} ${height}`}>
+            <polyline points={points} fill="none" stroke="currentColor" strokeWidth={1.5} />
+        </svg>
+    )
+}
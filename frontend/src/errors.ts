@@ -0,0 +1,38 @@
+// Structured error returned by backend methods (see FrontendError in errors.go)
+export interface ApiError {
+    message: string
+    kind:
+        | 'not_authenticated'
+        | 'token_expired'
+        | 'rate_limited'
+        | 'invalid_appkey'
+        | 'no_permission'
+        | 'gateway_unavailable'
+        | 'api_error'
+        | 'error'
+    resultCode?: string
+    reqSerialNum?: string
+    httpStatus?: number
+    path?: string
+}
+
+export function errorMessage(err: any): string {
+    if (!err) return 'Unknown error occurred'
+    if (typeof err === 'string') return err
+    let msg = err.message || err.toString()
+    // Requests aren't sent while a gateway is down, the message says when it's retried
+    if (isGatewayUnavailable(err)) return `The iSolarCloud gateway is not responding (${msg})`
+    if (err.reqSerialNum) msg += ` [ref ${err.reqSerialNum}]`
+    return msg
+}
+
+// True when the request wasn't sent because the gateway is down, so it is worth retrying
+// later rather than changing anything
+export function isGatewayUnavailable(err: any): boolean {
+    return err?.kind === 'gateway_unavailable'
+}
+
+// True when the error means the user has to log in again
+export function isAuthError(err: any): boolean {
+    return err?.kind === 'not_authenticated' || err?.kind === 'token_expired' || err?.kind === 'invalid_appkey'
+}
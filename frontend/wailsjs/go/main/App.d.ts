@@ -1,19 +1,92 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
+import {alerts} from '../models';
+import {history} from '../models';
+import {isolarcloud} from '../models';
 import {main} from '../models';
+import {status} from '../models';
+import {trayicon} from '../models';
+
+export function AddAccount(arg1:string):Promise<main.Account>;
 
 export function Authenticate(arg1:main.Credentials):Promise<Record<string, any>>;
 
-export function GetDeviceList(arg1:number):Promise<Array<main.PlantDevice>>;
+export function BackfillHistory(arg1:string,arg2:Array<number>,arg3:number,arg4:number):Promise<number>;
+
+export function BeginManualLogin(arg1:main.Credentials,arg2:string):Promise<string>;
+
+export function CancelAuthentication():Promise<void>;
+
+export function ClearSecretSetting(arg1:string):Promise<void>;
+
+export function CompleteManualLogin(arg1:string):Promise<Record<string, any>>;
+
+export function DetectGateway(arg1:string,arg2:string):Promise<isolarcloud.Gateway>;
+
+export function GetAccounts():Promise<Array<main.Account>>;
+
+export function GetActiveAlerts():Promise<Array<alerts.Alert>>;
+
+export function GetDeviceList(arg1:number):Promise<Array<isolarcloud.PlantDevice>>;
+
+export function GetDeviceListPage(arg1:number,arg2:number,arg3:number):Promise<isolarcloud.DevicePage>;
+
+export function GetDeviceMinuteHistory(arg1:string,arg2:Array<number>,arg3:number,arg4:number,arg5:number):Promise<Array<isolarcloud.Series>>;
 
 export function GetDevicePointData(arg1:number,arg2:string,arg3:Array<number>):Promise<Array<Record<string, any>>>;
 
-export function GetPlantList():Promise<Array<main.Plant>>;
+export function GetDevicePointDataBatch(arg1:Array<isolarcloud.DeviceRealTimeRequest>):Promise<Record<string, main.DevicePointResult>>;
+
+export function GetDeviceReadings(arg1:number,arg2:string,arg3:Array<number>):Promise<Array<isolarcloud.Reading>>;
+
+export function GetDeviceStatistics(arg1:string,arg2:Array<number>,arg3:string,arg4:number,arg5:number):Promise<Array<isolarcloud.Series>>;
+
+export function GetGatewayStatus():Promise<Array<main.GatewayStatus>>;
+
+export function GetGateways():Promise<Array<isolarcloud.Gateway>>;
+
+export function GetMQTTStatus():Promise<Record<string, any>>;
+
+export function GetPlantAlarms(arg1:number,arg2:number,arg3:number,arg4:boolean,arg5:number,arg6:number):Promise<isolarcloud.AlarmPage>;
+
+export function GetPlantList():Promise<Array<isolarcloud.Plant>>;
+
+export function GetPlantListPage(arg1:number,arg2:number):Promise<isolarcloud.PlantPage>;
+
+export function GetPlantStatistics(arg1:number,arg2:Array<number>,arg3:string,arg4:number,arg5:number):Promise<Array<isolarcloud.Series>>;
+
+export function GetPointCatalogue():Promise<Array<isolarcloud.PointDef>>;
+
+export function GetPointHistory(arg1:string,arg2:number,arg3:number,arg4:number,arg5:string):Promise<Array<history.Point>>;
+
+export function GetSettings():Promise<main.Settings>;
+
+export function GetStatusEvents(arg1:status.Query):Promise<Array<status.Event>>;
 
 export function GetStoredCredentials():Promise<main.Credentials>;
 
+export function GetWatchedDevices():Promise<Array<main.PollTarget>>;
+
 export function Logout():Promise<void>;
 
+export function PreviewTrayIcon(arg1:trayicon.Options,arg2:trayicon.State):Promise<number[]>;
+
+export function RemoveAccount(arg1:string):Promise<void>;
+
+export function RenameAccount(arg1:string,arg2:string):Promise<void>;
+
+export function SaveSettings(arg1:main.Settings):Promise<void>;
+
+export function SendTestWebhook(arg1:main.WebhookSettings):Promise<void>;
+
+export function SimulateAlertRule(arg1:alerts.Rule,arg2:Array<alerts.Observation>):Promise<Array<alerts.Event>>;
+
+export function SwitchAccount(arg1:string):Promise<void>;
+
+export function UnwatchDevice(arg1:string):Promise<void>;
+
 export function UpdateTrayStatus(arg1:number,arg2:string):Promise<void>;
 
 export function UpdateTrayTitle(arg1:string):Promise<void>;
+
+export function WatchDevice(arg1:main.PollTarget):Promise<void>;
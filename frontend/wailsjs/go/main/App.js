@@ -2,30 +2,162 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddAccount(arg1) {
+  return window['go']['main']['App']['AddAccount'](arg1);
+}
+
 export function Authenticate(arg1) {
   return window['go']['main']['App']['Authenticate'](arg1);
 }
 
+export function BackfillHistory(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['BackfillHistory'](arg1, arg2, arg3, arg4);
+}
+
+export function BeginManualLogin(arg1, arg2) {
+  return window['go']['main']['App']['BeginManualLogin'](arg1, arg2);
+}
+
+export function CancelAuthentication() {
+  return window['go']['main']['App']['CancelAuthentication']();
+}
+
+export function ClearSecretSetting(arg1) {
+  return window['go']['main']['App']['ClearSecretSetting'](arg1);
+}
+
+export function CompleteManualLogin(arg1) {
+  return window['go']['main']['App']['CompleteManualLogin'](arg1);
+}
+
+export function DetectGateway(arg1, arg2) {
+  return window['go']['main']['App']['DetectGateway'](arg1, arg2);
+}
+
+export function GetAccounts() {
+  return window['go']['main']['App']['GetAccounts']();
+}
+
+export function GetActiveAlerts() {
+  return window['go']['main']['App']['GetActiveAlerts']();
+}
+
 export function GetDeviceList(arg1) {
   return window['go']['main']['App']['GetDeviceList'](arg1);
 }
 
+export function GetDeviceListPage(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetDeviceListPage'](arg1, arg2, arg3);
+}
+
+export function GetDeviceMinuteHistory(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['GetDeviceMinuteHistory'](arg1, arg2, arg3, arg4, arg5);
+}
+
 export function GetDevicePointData(arg1, arg2, arg3) {
   return window['go']['main']['App']['GetDevicePointData'](arg1, arg2, arg3);
 }
 
+export function GetDevicePointDataBatch(arg1) {
+  return window['go']['main']['App']['GetDevicePointDataBatch'](arg1);
+}
+
+export function GetDeviceReadings(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetDeviceReadings'](arg1, arg2, arg3);
+}
+
+export function GetDeviceStatistics(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['GetDeviceStatistics'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function GetGatewayStatus() {
+  return window['go']['main']['App']['GetGatewayStatus']();
+}
+
+export function GetGateways() {
+  return window['go']['main']['App']['GetGateways']();
+}
+
+export function GetMQTTStatus() {
+  return window['go']['main']['App']['GetMQTTStatus']();
+}
+
+export function GetPlantAlarms(arg1, arg2, arg3, arg4, arg5, arg6) {
+  return window['go']['main']['App']['GetPlantAlarms'](arg1, arg2, arg3, arg4, arg5, arg6);
+}
+
 export function GetPlantList() {
   return window['go']['main']['App']['GetPlantList']();
 }
 
+export function GetPlantListPage(arg1, arg2) {
+  return window['go']['main']['App']['GetPlantListPage'](arg1, arg2);
+}
+
+export function GetPlantStatistics(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['GetPlantStatistics'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function GetPointCatalogue() {
+  return window['go']['main']['App']['GetPointCatalogue']();
+}
+
+export function GetPointHistory(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['GetPointHistory'](arg1, arg2, arg3, arg4, arg5);
+}
+
+export function GetSettings() {
+  return window['go']['main']['App']['GetSettings']();
+}
+
+export function GetStatusEvents(arg1) {
+  return window['go']['main']['App']['GetStatusEvents'](arg1);
+}
+
 export function GetStoredCredentials() {
   return window['go']['main']['App']['GetStoredCredentials']();
 }
 
+export function GetWatchedDevices() {
+  return window['go']['main']['App']['GetWatchedDevices']();
+}
+
 export function Logout() {
   return window['go']['main']['App']['Logout']();
 }
 
+export function PreviewTrayIcon(arg1, arg2) {
+  return window['go']['main']['App']['PreviewTrayIcon'](arg1, arg2);
+}
+
+export function RemoveAccount(arg1) {
+  return window['go']['main']['App']['RemoveAccount'](arg1);
+}
+
+export function RenameAccount(arg1, arg2) {
+  return window['go']['main']['App']['RenameAccount'](arg1, arg2);
+}
+
+export function SaveSettings(arg1) {
+  return window['go']['main']['App']['SaveSettings'](arg1);
+}
+
+export function SendTestWebhook(arg1) {
+  return window['go']['main']['App']['SendTestWebhook'](arg1);
+}
+
+export function SimulateAlertRule(arg1, arg2) {
+  return window['go']['main']['App']['SimulateAlertRule'](arg1, arg2);
+}
+
+export function SwitchAccount(arg1) {
+  return window['go']['main']['App']['SwitchAccount'](arg1);
+}
+
+export function UnwatchDevice(arg1) {
+  return window['go']['main']['App']['UnwatchDevice'](arg1);
+}
+
 export function UpdateTrayStatus(arg1, arg2) {
   return window['go']['main']['App']['UpdateTrayStatus'](arg1, arg2);
 }
@@ -33,3 +165,7 @@ export function UpdateTrayStatus(arg1, arg2) {
 export function UpdateTrayTitle(arg1) {
   return window['go']['main']['App']['UpdateTrayTitle'](arg1);
 }
+
+export function WatchDevice(arg1) {
+  return window['go']['main']['App']['WatchDevice'](arg1);
+}
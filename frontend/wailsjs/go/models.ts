@@ -1,27 +1,299 @@
-export namespace main {
+export namespace alerts {
 	
-	export class Credentials {
-	    appKey: string;
-	    secretKey: string;
+	export class Alert {
+	    ruleId: string;
+	    ruleName: string;
+	    psKey: string;
+	    deviceName?: string;
+	    value: number;
+	    unit?: string;
+	    since: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Alert(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ruleId = source["ruleId"];
+	        this.ruleName = source["ruleName"];
+	        this.psKey = source["psKey"];
+	        this.deviceName = source["deviceName"];
+	        this.value = source["value"];
+	        this.unit = source["unit"];
+	        this.since = source["since"];
+	    }
+	}
+	export class Event {
+	    ruleId: string;
+	    ruleName: string;
+	    psKey: string;
+	    deviceName?: string;
+	    state: string;
+	    value: number;
+	    unit?: string;
+	    message: string;
+	    timestamp: number;
+	    notify: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Event(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ruleId = source["ruleId"];
+	        this.ruleName = source["ruleName"];
+	        this.psKey = source["psKey"];
+	        this.deviceName = source["deviceName"];
+	        this.state = source["state"];
+	        this.value = source["value"];
+	        this.unit = source["unit"];
+	        this.message = source["message"];
+	        this.timestamp = source["timestamp"];
+	        this.notify = source["notify"];
+	    }
+	}
+	export class Observation {
+	    psKey: string;
+	    deviceName?: string;
+	    online: boolean;
+	    readings?: isolarcloud.Reading[];
+	    timestamp: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Observation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.psKey = source["psKey"];
+	        this.deviceName = source["deviceName"];
+	        this.online = source["online"];
+	        this.readings = this.convertValues(source["readings"], isolarcloud.Reading);
+	        this.timestamp = source["timestamp"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Rule {
+	    id: string;
+	    name: string;
+	    enabled: boolean;
+	    psKey?: string;
+	    condition: string;
+	    point?: string;
+	    threshold: number;
+	    hysteresis: number;
+	    delaySeconds: number;
+	    cooldownSeconds: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Rule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.enabled = source["enabled"];
+	        this.psKey = source["psKey"];
+	        this.condition = source["condition"];
+	        this.point = source["point"];
+	        this.threshold = source["threshold"];
+	        this.hysteresis = source["hysteresis"];
+	        this.delaySeconds = source["delaySeconds"];
+	        this.cooldownSeconds = source["cooldownSeconds"];
+	    }
+	}
+
+}
+
+export namespace history {
+	
+	export class Point {
+	    timestamp: number;
+	    value: number;
+	    min: number;
+	    max: number;
+	    count: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Point(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.timestamp = source["timestamp"];
+	        this.value = source["value"];
+	        this.min = source["min"];
+	        this.max = source["max"];
+	        this.count = source["count"];
+	    }
+	}
+
+}
+
+export namespace isolarcloud {
+	
+	export class Alarm {
+	    id: string;
+	    psId: number;
+	    psKey: string;
+	    deviceName: string;
+	    deviceSn?: string;
+	    code: string;
+	    name: string;
+	    level: number;
+	    levelName: string;
+	    description?: string;
+	    advice?: string;
+	    startTime: number;
+	    endTime?: number;
+	    active: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Alarm(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.psId = source["psId"];
+	        this.psKey = source["psKey"];
+	        this.deviceName = source["deviceName"];
+	        this.deviceSn = source["deviceSn"];
+	        this.code = source["code"];
+	        this.name = source["name"];
+	        this.level = source["level"];
+	        this.levelName = source["levelName"];
+	        this.description = source["description"];
+	        this.advice = source["advice"];
+	        this.startTime = source["startTime"];
+	        this.endTime = source["endTime"];
+	        this.active = source["active"];
+	    }
+	}
+	export class AlarmPage {
+	    pageList: Alarm[];
+	    rowCount: number;
+	    page: number;
+	    size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AlarmPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.pageList = this.convertValues(source["pageList"], Alarm);
+	        this.rowCount = source["rowCount"];
+	        this.page = source["page"];
+	        this.size = source["size"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class DevicePage {
+	    pageList: PlantDevice[];
+	    rowCount: number;
+	    page: number;
+	    size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DevicePage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.pageList = this.convertValues(source["pageList"], PlantDevice);
+	        this.rowCount = source["rowCount"];
+	        this.page = source["page"];
+	        this.size = source["size"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class DeviceRealTimeRequest {
+	    deviceType: number;
+	    psKeys: string[];
+	    pointIds: number[];
+	
+	    static createFrom(source: any = {}) {
+	        return new DeviceRealTimeRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.deviceType = source["deviceType"];
+	        this.psKeys = source["psKeys"];
+	        this.pointIds = source["pointIds"];
+	    }
+	}
+	export class Gateway {
+	    id: string;
+	    name: string;
+	    baseUrl: string;
 	    authUrl: string;
-	    accessToken?: string;
-	    refreshToken?: string;
-	    tokenExpiry?: number;
-	    gatewayUrl?: string;
 	
 	    static createFrom(source: any = {}) {
-	        return new Credentials(source);
+	        return new Gateway(source);
 	    }
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.appKey = source["appKey"];
-	        this.secretKey = source["secretKey"];
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.baseUrl = source["baseUrl"];
 	        this.authUrl = source["authUrl"];
-	        this.accessToken = source["accessToken"];
-	        this.refreshToken = source["refreshToken"];
-	        this.tokenExpiry = source["tokenExpiry"];
-	        this.gatewayUrl = source["gatewayUrl"];
 	    }
 	}
 	export class Plant {
@@ -43,6 +315,7 @@ export namespace main {
 	    grid_connection_time?: string;
 	    build_status: number;
 	    today_energy?: string;
+	    account_id?: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new Plant(source);
@@ -68,6 +341,7 @@ export namespace main {
 	        this.grid_connection_time = source["grid_connection_time"];
 	        this.build_status = source["build_status"];
 	        this.today_energy = source["today_energy"];
+	        this.account_id = source["account_id"];
 	    }
 	}
 	export class PlantDevice {
@@ -110,6 +384,613 @@ export namespace main {
 	        this.ps_id = source["ps_id"];
 	    }
 	}
+	export class PlantPage {
+	    pageList: Plant[];
+	    rowCount: number;
+	    page: number;
+	    size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PlantPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.pageList = this.convertValues(source["pageList"], Plant);
+	        this.rowCount = source["rowCount"];
+	        this.page = source["page"];
+	        this.size = source["size"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PointDef {
+	    id: number;
+	    key: string;
+	    name: string;
+	    unit: string;
+	    deviceType: number;
+	    scale: number;
+	    kind: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PointDef(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.key = source["key"];
+	        this.name = source["name"];
+	        this.unit = source["unit"];
+	        this.deviceType = source["deviceType"];
+	        this.scale = source["scale"];
+	        this.kind = source["kind"];
+	    }
+	}
+	export class Reading {
+	    psKey: string;
+	    pointId: number;
+	    key?: string;
+	    name: string;
+	    value: number;
+	    unit: string;
+	    timestamp: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Reading(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.psKey = source["psKey"];
+	        this.pointId = source["pointId"];
+	        this.key = source["key"];
+	        this.name = source["name"];
+	        this.value = source["value"];
+	        this.unit = source["unit"];
+	        this.timestamp = source["timestamp"];
+	    }
+	}
+	export class Sample {
+	    timestamp: number;
+	    value: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Sample(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.timestamp = source["timestamp"];
+	        this.value = source["value"];
+	    }
+	}
+	export class Series {
+	    key: string;
+	    pointId: number;
+	    samples: Sample[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Series(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.key = source["key"];
+	        this.pointId = source["pointId"];
+	        this.samples = this.convertValues(source["samples"], Sample);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace main {
+	
+	export class Account {
+	    id: string;
+	    name: string;
+	    appKey: string;
+	    gatewayUrl: string;
+	    active: boolean;
+	    authenticated: boolean;
+	    tokenExpiry: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Account(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.appKey = source["appKey"];
+	        this.gatewayUrl = source["gatewayUrl"];
+	        this.active = source["active"];
+	        this.authenticated = source["authenticated"];
+	        this.tokenExpiry = source["tokenExpiry"];
+	    }
+	}
+	export class AlertSettings {
+	    notifications: boolean;
+	    rules: alerts.Rule[];
+	
+	    static createFrom(source: any = {}) {
+	        return new AlertSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.notifications = source["notifications"];
+	        this.rules = this.convertValues(source["rules"], alerts.Rule);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AuthSettings {
+	    callbackTimeoutSeconds: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AuthSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.callbackTimeoutSeconds = source["callbackTimeoutSeconds"];
+	    }
+	}
+	export class Credentials {
+	    appKey: string;
+	    secretKey: string;
+	    authUrl: string;
+	    accessToken?: string;
+	    refreshToken?: string;
+	    tokenExpiry?: number;
+	    gatewayUrl?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Credentials(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.appKey = source["appKey"];
+	        this.secretKey = source["secretKey"];
+	        this.authUrl = source["authUrl"];
+	        this.accessToken = source["accessToken"];
+	        this.refreshToken = source["refreshToken"];
+	        this.tokenExpiry = source["tokenExpiry"];
+	        this.gatewayUrl = source["gatewayUrl"];
+	    }
+	}
+	export class DevicePointResult {
+	    points?: Record<string, any>;
+	    readings?: isolarcloud.Reading[];
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DevicePointResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.points = source["points"];
+	        this.readings = this.convertValues(source["readings"], isolarcloud.Reading);
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class GatewayStatus {
+	    url: string;
+	    name: string;
+	    state: string;
+	    degraded: boolean;
+	    failures: number;
+	    retryAt?: number;
+	    lastError?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new GatewayStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.url = source["url"];
+	        this.name = source["name"];
+	        this.state = source["state"];
+	        this.degraded = source["degraded"];
+	        this.failures = source["failures"];
+	        this.retryAt = source["retryAt"];
+	        this.lastError = source["lastError"];
+	    }
+	}
+	export class HistorySettings {
+	    rawRetentionDays: number;
+	    retentionDays: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new HistorySettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.rawRetentionDays = source["rawRetentionDays"];
+	        this.retentionDays = source["retentionDays"];
+	    }
+	}
+	export class MQTTSettings {
+	    enabled: boolean;
+	    broker: string;
+	    username: string;
+	    password?: string;
+	    clientId: string;
+	    topicPrefix: string;
+	    discovery: boolean;
+	    discoveryPrefix: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MQTTSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.broker = source["broker"];
+	        this.username = source["username"];
+	        this.password = source["password"];
+	        this.clientId = source["clientId"];
+	        this.topicPrefix = source["topicPrefix"];
+	        this.discovery = source["discovery"];
+	        this.discoveryPrefix = source["discoveryPrefix"];
+	    }
+	}
+	export class MetricsSettings {
+	    enabled: boolean;
+	    listenAddr: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MetricsSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.listenAddr = source["listenAddr"];
+	    }
+	}
+	export class PollTarget {
+	    psId: number;
+	    psKey: string;
+	    accountId?: string;
+	    deviceType: number;
+	    deviceName: string;
+	    pointIds: number[];
+	    tray: boolean;
+	    intervalSeconds?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PollTarget(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.psId = source["psId"];
+	        this.psKey = source["psKey"];
+	        this.accountId = source["accountId"];
+	        this.deviceType = source["deviceType"];
+	        this.deviceName = source["deviceName"];
+	        this.pointIds = source["pointIds"];
+	        this.tray = source["tray"];
+	        this.intervalSeconds = source["intervalSeconds"];
+	    }
+	}
+	export class PollerSettings {
+	    intervalSeconds: number;
+	    jitterSeconds: number;
+	    maxBackoffSeconds: number;
+	    targets: PollTarget[];
+	
+	    static createFrom(source: any = {}) {
+	        return new PollerSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.intervalSeconds = source["intervalSeconds"];
+	        this.jitterSeconds = source["jitterSeconds"];
+	        this.maxBackoffSeconds = source["maxBackoffSeconds"];
+	        this.targets = this.convertValues(source["targets"], PollTarget);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Settings {
+	    poller: PollerSettings;
+	    history: HistorySettings;
+	    metrics: MetricsSettings;
+	    mqtt: MQTTSettings;
+	    auth: AuthSettings;
+	    alerts: AlertSettings;
+	    webhook: WebhookSettings;
+	    tray: trayicon.Options;
+	    storedSecrets?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Settings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.poller = this.convertValues(source["poller"], PollerSettings);
+	        this.history = this.convertValues(source["history"], HistorySettings);
+	        this.metrics = this.convertValues(source["metrics"], MetricsSettings);
+	        this.mqtt = this.convertValues(source["mqtt"], MQTTSettings);
+	        this.auth = this.convertValues(source["auth"], AuthSettings);
+	        this.alerts = this.convertValues(source["alerts"], AlertSettings);
+	        this.webhook = this.convertValues(source["webhook"], WebhookSettings);
+	        this.tray = this.convertValues(source["tray"], trayicon.Options);
+	        this.storedSecrets = source["storedSecrets"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class WebhookSettings {
+	    enabled: boolean;
+	    url: string;
+	    secret?: string;
+	    template: string;
+	    alerts: boolean;
+	    statusChanges: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new WebhookSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.url = source["url"];
+	        this.secret = source["secret"];
+	        this.template = source["template"];
+	        this.alerts = source["alerts"];
+	        this.statusChanges = source["statusChanges"];
+	    }
+	}
+
+}
+
+export namespace status {
+	
+	export class Event {
+	    type: string;
+	    subject: string;
+	    psId: number;
+	    psKey?: string;
+	    name: string;
+	    severity?: string;
+	    message: string;
+	    timestamp: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Event(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.subject = source["subject"];
+	        this.psId = source["psId"];
+	        this.psKey = source["psKey"];
+	        this.name = source["name"];
+	        this.severity = source["severity"];
+	        this.message = source["message"];
+	        this.timestamp = source["timestamp"];
+	    }
+	}
+	export class Query {
+	    psId?: number;
+	    psKey?: string;
+	    types?: string[];
+	    from?: number;
+	    to?: number;
+	    limit?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Query(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.psId = source["psId"];
+	        this.psKey = source["psKey"];
+	        this.types = source["types"];
+	        this.from = source["from"];
+	        this.to = source["to"];
+	        this.limit = source["limit"];
+	    }
+	}
+
+}
+
+export namespace trayicon {
+	
+	export class Options {
+	    style: string;
+	    size: number;
+	    antialias: boolean;
+	    low: number;
+	    medium: number;
+	    palette: Palette;
+	
+	    static createFrom(source: any = {}) {
+	        return new Options(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.style = source["style"];
+	        this.size = source["size"];
+	        this.antialias = source["antialias"];
+	        this.low = source["low"];
+	        this.medium = source["medium"];
+	        this.palette = this.convertValues(source["palette"], Palette);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Palette {
+	    low: string;
+	    medium: string;
+	    high: string;
+	    empty: string;
+	    text: string;
+	    charging: string;
+	    discharging: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Palette(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.low = source["low"];
+	        this.medium = source["medium"];
+	        this.high = source["high"];
+	        this.empty = source["empty"];
+	        this.text = source["text"];
+	        this.charging = source["charging"];
+	        this.discharging = source["discharging"];
+	    }
+	}
+	export class State {
+	    percentage: number;
+	    flow: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new State(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.percentage = source["percentage"];
+	        this.flow = source["flow"];
+	    }
+	}
 
 }
 
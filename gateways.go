@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+)
+
+// gatewayProbeTimeout bounds how long DetectGateway waits for the slowest region
+const gatewayProbeTimeout = 20 * time.Second
+
+// GetGateways returns the iSolarCloud regions offered on the login form
+func (a *App) GetGateways() []isolarcloud.Gateway {
+	return isolarcloud.Gateways()
+}
+
+// DetectGateway finds the region an app key and secret key are registered in, for
+// users who don't know which server their account lives on
+func (a *App) DetectGateway(appKey, secretKey string) (isolarcloud.Gateway, error) {
+	if appKey == "" || secretKey == "" {
+		return isolarcloud.Gateway{}, fmt.Errorf("app key and secret key are required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gatewayProbeTimeout)
+	defer cancel()
+
+	results := isolarcloud.ProbeGateways(ctx, appKey, secretKey, isolarcloud.Gateways(),
+		isolarcloud.WithHTTPClient(a.httpClient),
+		isolarcloud.WithObserver(a.metrics.observeRequest),
+	)
+	for _, r := range results {
+		a.log.Printf("DetectGateway: %s %s %s\n", r.Gateway.ID, r.Status, r.Error)
+	}
+	return selectGateway(results)
+}
+
+// selectGateway picks the region that accepted the keys. The first is used if several
+// did, since regions are listed in order of preference.
+func selectGateway(results []isolarcloud.ProbeResult) (isolarcloud.Gateway, error) {
+	var unreachable []string
+	for _, r := range results {
+		switch r.Status {
+		case isolarcloud.ProbeAccepted:
+			return r.Gateway, nil
+		case isolarcloud.ProbeUnreachable:
+			unreachable = append(unreachable, r.Gateway.Name)
+		}
+	}
+
+	if len(unreachable) > 0 {
+		return isolarcloud.Gateway{}, fmt.Errorf("app key was not recognised, and %s could not be reached", strings.Join(unreachable, ", "))
+	}
+	return isolarcloud.Gateway{}, fmt.Errorf("app key was not recognised by any gateway, check the app key and secret key")
+}
+
+// withGatewayDefaults fills in the gateway and authorization URLs when unset, using the
+// default region or the authorization page of the chosen gateway's region
+func (c Credentials) withGatewayDefaults() Credentials {
+	if c.GatewayURL == "" {
+		c.GatewayURL = isolarcloud.DefaultGateway().BaseURL
+	}
+	if c.AuthURL == "" {
+		gateway, ok := isolarcloud.GatewayForURL(c.GatewayURL)
+		if !ok {
+			gateway = isolarcloud.DefaultGateway()
+		}
+		c.AuthURL = gateway.AuthURL
+	}
+	return c
+}
+
+// A gateway's circuit breaker opens after gatewayFailureThreshold consecutive failed
+// requests and lets a trial request through every gatewayCooldown
+const (
+	gatewayFailureThreshold = 5
+	gatewayCooldown         = time.Minute
+)
+
+// GatewayStatus is the health of a gateway in use
+type GatewayStatus struct {
+	URL       string                   `json:"url"`
+	Name      string                   `json:"name"`
+	State     isolarcloud.BreakerState `json:"state"`
+	Degraded  bool                     `json:"degraded"`
+	Failures  int                      `json:"failures"`
+	RetryAt   int64                    `json:"retryAt,omitempty"`
+	LastError string                   `json:"lastError,omitempty"`
+}
+
+// newGatewayStatus describes a gateway's breaker
+func newGatewayStatus(baseURL string, s isolarcloud.BreakerStatus) GatewayStatus {
+	name := baseURL
+	if g, ok := isolarcloud.GatewayForURL(baseURL); ok {
+		name = g.Name
+	}
+	return GatewayStatus{
+		URL:       baseURL,
+		Name:      name,
+		State:     s.State,
+		Degraded:  s.State != isolarcloud.BreakerClosed,
+		Failures:  s.Failures,
+		RetryAt:   s.RetryAt,
+		LastError: s.LastError,
+	}
+}
+
+// gatewayBreaker returns the circuit breaker shared by every client of a gateway
+func (a *App) gatewayBreaker(baseURL string) *isolarcloud.CircuitBreaker {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if baseURL == "" {
+		baseURL = isolarcloud.DefaultGateway().BaseURL
+	}
+
+	a.breakersMu.Lock()
+	defer a.breakersMu.Unlock()
+
+	b, ok := a.breakers[baseURL]
+	if !ok {
+		b = isolarcloud.NewCircuitBreaker(gatewayFailureThreshold, gatewayCooldown, func(s isolarcloud.BreakerStatus) {
+			a.handleGatewayStatus(newGatewayStatus(baseURL, s))
+		})
+		a.breakers[baseURL] = b
+	}
+	return b
+}
+
+// GetGatewayStatus returns the health of the gateways in use
+func (a *App) GetGatewayStatus() []GatewayStatus {
+	a.breakersMu.Lock()
+	defer a.breakersMu.Unlock()
+
+	statuses := make([]GatewayStatus, 0, len(a.breakers))
+	for baseURL, b := range a.breakers {
+		statuses = append(statuses, newGatewayStatus(baseURL, b.Status()))
+	}
+	slices.SortFunc(statuses, func(x, y GatewayStatus) int { return strings.Compare(x.URL, y.URL) })
+	return statuses
+}
+
+// handleGatewayStatus reports a gateway going down or recovering to the frontend and tray
+func (a *App) handleGatewayStatus(status GatewayStatus) {
+	if status.Degraded {
+		a.log.Printf("handleGatewayStatus: %s gateway degraded (%s), %d failures: %s\n", status.Name, status.State, status.Failures, status.LastError)
+	} else {
+		a.log.Printf("handleGatewayStatus: %s gateway recovered\n", status.Name)
+	}
+	a.emit("gateway:status", status)
+
+	var degraded []string
+	for _, s := range a.GetGatewayStatus() {
+		if s.Degraded {
+			degraded = append(degraded, s.Name)
+		}
+	}
+
+	a.trayMu.Lock()
+	title := a.trayTitle
+	a.trayMu.Unlock()
+	if len(degraded) > 0 {
+		title = fmt.Sprintf("Gateway degraded: %s", strings.Join(degraded, ", "))
+	}
+	if title != "" {
+		a.UpdateTrayTitle(title)
+	}
+}
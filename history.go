@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"wails-sungrow-isolarcloud-app/history"
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+)
+
+// HistorySettings controls how long polled point data is kept on disk
+type HistorySettings struct {
+	RawRetentionDays int `json:"rawRetentionDays"`
+	RetentionDays    int `json:"retentionDays"`
+}
+
+// policy converts the settings into a history retention policy
+func (s HistorySettings) policy() history.Policy {
+	return history.Policy{
+		RawRetention: time.Duration(s.RawRetentionDays) * 24 * time.Hour,
+		Retention:    time.Duration(s.RetentionDays) * 24 * time.Hour,
+	}
+}
+
+// openHistory opens the point history store and compacts it hourly until ctx is cancelled
+func (a *App) openHistory(ctx context.Context) {
+	appDir, err := appConfigDir()
+	if err != nil {
+		a.log.Printf("openHistory: %v\n", err)
+		return
+	}
+
+	store, err := history.Open(filepath.Join(appDir, "history"), a.GetSettings().History.policy())
+	if err != nil {
+		a.log.Printf("openHistory: %v\n", err)
+		return
+	}
+	a.history = store
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			if err := store.Compact(time.Now()); err != nil {
+				a.log.Printf("openHistory: compaction failed: %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// recordHistory stores every numeric point of a poll result
+func (a *App) recordHistory(r PollResult) {
+	if a.history == nil || r.Error != "" {
+		return
+	}
+
+	ts := time.UnixMilli(r.Timestamp)
+	for key, raw := range r.Points {
+		pointID, ok := isolarcloud.ParsePointKey(key)
+		if !ok {
+			continue
+		}
+		value, ok := isolarcloud.ParseValue(raw)
+		if !ok {
+			continue
+		}
+		if err := a.history.Record(r.PsKey, pointID, ts, value); err != nil {
+			a.log.Printf("recordHistory: %s p%d: %v\n", r.PsKey, pointID, err)
+		}
+	}
+}
+
+// GetPointHistory returns recorded values of a point between two Unix millisecond
+// timestamps. resolution is one of raw, 5m, 1h or 1d.
+func (a *App) GetPointHistory(psKey string, pointID int, from int64, to int64, resolution string) ([]history.Point, error) {
+	if a.history == nil {
+		return nil, fmt.Errorf("history store is not available")
+	}
+
+	return a.history.Query(psKey, pointID, time.UnixMilli(from), time.UnixMilli(to), history.Resolution(resolution))
+}
+
+// GetDeviceMinuteHistory retrieves minute-level history of device points from the gateway
+// between two Unix millisecond timestamps
+func (a *App) GetDeviceMinuteHistory(psKey string, pointIDs []int, from int64, to int64, intervalMinutes int) ([]isolarcloud.Series, error) {
+	client, err := a.clientForPsKey(psKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.DeviceMinuteData(context.Background(), []string{psKey}, pointIDs, time.UnixMilli(from), time.UnixMilli(to), time.Duration(intervalMinutes)*time.Minute)
+}
+
+// GetDeviceStatistics retrieves day, month or year statistics of device points from the gateway
+func (a *App) GetDeviceStatistics(psKey string, pointIDs []int, period string, from int64, to int64) ([]isolarcloud.Series, error) {
+	client, err := a.clientForPsKey(psKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.DeviceStatistics(context.Background(), []string{psKey}, pointIDs, isolarcloud.Period(period), time.UnixMilli(from), time.UnixMilli(to))
+}
+
+// GetPlantStatistics retrieves day, month or year statistics of plant points from the gateway
+func (a *App) GetPlantStatistics(psID int, pointIDs []int, period string, from int64, to int64) ([]isolarcloud.Series, error) {
+	client, err := a.clientForPlant(psID)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.PlantStatistics(context.Background(), []int{psID}, pointIDs, isolarcloud.Period(period), time.UnixMilli(from), time.UnixMilli(to))
+}
+
+// BackfillHistory loads 5 minute history of device points from the gateway into the local
+// store, skipping buckets that already hold polled samples. Returns the number of samples added.
+func (a *App) BackfillHistory(psKey string, pointIDs []int, from int64, to int64) (int, error) {
+	if a.history == nil {
+		return 0, fmt.Errorf("history store is not available")
+	}
+
+	series, err := a.GetDeviceMinuteHistory(psKey, pointIDs, from, to, 5)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, s := range series {
+		existing, err := a.history.Query(psKey, s.PointID, time.UnixMilli(from), time.UnixMilli(to), history.Resolution5Min)
+		if err != nil {
+			return added, err
+		}
+		covered := make(map[int64]bool, len(existing))
+		for _, p := range existing {
+			covered[p.Timestamp] = true
+		}
+
+		bucket := (5 * time.Minute).Milliseconds()
+		for _, sample := range s.Samples {
+			if covered[sample.Timestamp-sample.Timestamp%bucket] {
+				continue
+			}
+			if err := a.history.Record(psKey, s.PointID, time.UnixMilli(sample.Timestamp), sample.Value); err != nil {
+				return added, err
+			}
+			added++
+		}
+	}
+
+	a.log.Printf("BackfillHistory: added %d samples for %s\n", added, psKey)
+	return added, nil
+}
@@ -0,0 +1,352 @@
+// Package history is an embedded on-disk time-series store for device point readings.
+//
+// Each series (ps_key + point ID) is a directory holding one file per UTC day.
+// Recent days keep every sample in a .raw file, older days are downsampled into
+// 5 minute aggregates in a .5m file, and days past the retention period are deleted.
+// Query buckets follow local time, so daily values run from midnight to midnight where
+// the user is.
+package history
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rawExt         = ".raw"
+	downsampledExt = ".5m"
+	dayLayout      = "2006-01-02"
+)
+
+// Resolution is the bucket width of a query
+type Resolution string
+
+const (
+	ResolutionRaw  Resolution = "raw"
+	Resolution5Min Resolution = "5m"
+	ResolutionHour Resolution = "1h"
+	ResolutionDay  Resolution = "1d"
+)
+
+// downsampleBucket is the width of the aggregates old raw samples are reduced to
+const downsampleBucket = 5 * time.Minute
+
+// bucket returns the width of the resolution, or 0 for raw
+func (r Resolution) bucket() (time.Duration, error) {
+	switch r {
+	case ResolutionRaw, "":
+		return 0, nil
+	case Resolution5Min:
+		return 5 * time.Minute, nil
+	case ResolutionHour:
+		return time.Hour, nil
+	case ResolutionDay:
+		return 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("unknown resolution %q", r)
+}
+
+// Point is one value in a query result. Aggregated points carry the mean in Value.
+type Point struct {
+	Timestamp int64   `json:"timestamp"` // Unix milliseconds, start of the bucket when aggregated
+	Value     float64 `json:"value"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	Count     int     `json:"count"`
+}
+
+// Policy controls how long data is kept
+type Policy struct {
+	// RawRetention is how long every sample is kept before being downsampled to 5 minute buckets
+	RawRetention time.Duration
+	// Retention is how long downsampled data is kept before being deleted
+	Retention time.Duration
+}
+
+// DefaultPolicy keeps a week of raw samples and a year of 5 minute aggregates
+var DefaultPolicy = Policy{
+	RawRetention: 7 * 24 * time.Hour,
+	Retention:    365 * 24 * time.Hour,
+}
+
+// Store is a time-series store rooted at a directory
+type Store struct {
+	dir    string
+	mu     sync.Mutex
+	policy Policy
+	loc    *time.Location // query buckets are aligned to this zone's clock
+}
+
+// Open opens or creates a store in dir
+func Open(dir string, policy Policy) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &Store{dir: dir, loc: time.Local}
+	s.SetPolicy(policy)
+	return s, nil
+}
+
+// SetPolicy changes the retention policy applied by Compact
+func (s *Store) SetPolicy(policy Policy) {
+	if policy.RawRetention <= 0 {
+		policy.RawRetention = DefaultPolicy.RawRetention
+	}
+	if policy.Retention < policy.RawRetention {
+		policy.Retention = max(DefaultPolicy.Retention, policy.RawRetention)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+}
+
+// seriesDir returns the directory of a series
+func (s *Store) seriesDir(psKey string, pointID int) string {
+	return filepath.Join(s.dir, url.PathEscape(psKey), strconv.Itoa(pointID))
+}
+
+// Record appends a sample to a series
+func (s *Store) Record(psKey string, pointID int, ts time.Time, value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("cannot record %v", value)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.seriesDir(psKey, pointID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, ts.UTC().Format(dayLayout)+rawExt), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%d,%s\n", ts.UnixMilli(), strconv.FormatFloat(value, 'g', -1, 64))
+	return err
+}
+
+// Query returns a series' values between from and to (inclusive), bucketed by resolution
+func (s *Store) Query(psKey string, pointID int, from, to time.Time, resolution Resolution) ([]Point, error) {
+	bucket, err := resolution.bucket()
+	if err != nil {
+		return nil, err
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("query end is before start")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dir := s.seriesDir(psKey, pointID)
+	fromMs, toMs := from.UnixMilli(), to.UnixMilli()
+
+	var points []Point
+	for day := from.UTC().Truncate(24 * time.Hour); !day.After(to); day = day.Add(24 * time.Hour) {
+		dayPoints, err := readDay(dir, day.Format(dayLayout))
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range dayPoints {
+			if p.Timestamp >= fromMs && p.Timestamp <= toMs {
+				points = append(points, p)
+			}
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].Timestamp < points[j].Timestamp })
+
+	if bucket == 0 {
+		return points, nil
+	}
+	return aggregate(points, bucket, s.loc), nil
+}
+
+// Compact downsamples and deletes data according to the retention policy
+func (s *Store) Compact(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rawCutoff := now.Add(-s.policy.RawRetention).UTC().Format(dayLayout)
+	deleteCutoff := now.Add(-s.policy.Retention).UTC().Format(dayLayout)
+
+	series, err := filepath.Glob(filepath.Join(s.dir, "*", "*"))
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range series {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			day := strings.TrimSuffix(strings.TrimSuffix(name, rawExt), downsampledExt)
+
+			switch {
+			case day < deleteCutoff:
+				if err := os.Remove(filepath.Join(dir, name)); err != nil {
+					return err
+				}
+			case strings.HasSuffix(name, rawExt) && day < rawCutoff:
+				if err := downsampleDay(dir, day); err != nil {
+					return fmt.Errorf("downsampling %s/%s: %w", dir, day, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// downsampleDay replaces a day's raw samples with 5 minute aggregates
+func downsampleDay(dir, day string) error {
+	points, err := readDay(dir, day)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].Timestamp < points[j].Timestamp })
+	// UTC keeps the files independent of the machine's zone; 5 minute buckets line up
+	// with local ones in every zone anyway
+	buckets := aggregate(points, downsampleBucket, time.UTC)
+
+	var b strings.Builder
+	for _, p := range buckets {
+		fmt.Fprintf(&b, "%d,%s,%s,%s,%d\n", p.Timestamp,
+			strconv.FormatFloat(p.Value, 'g', -1, 64),
+			strconv.FormatFloat(p.Min, 'g', -1, 64),
+			strconv.FormatFloat(p.Max, 'g', -1, 64),
+			p.Count)
+	}
+
+	// Write then rename so a crash never loses the day
+	out := filepath.Join(dir, day+downsampledExt)
+	if err := os.WriteFile(out+".tmp", []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(out+".tmp", out); err != nil {
+		return err
+	}
+
+	return os.Remove(filepath.Join(dir, day+rawExt))
+}
+
+// readDay reads a day's raw samples and any downsampled buckets
+func readDay(dir, day string) ([]Point, error) {
+	var points []Point
+	for _, ext := range []string{downsampledExt, rawExt} {
+		filePoints, err := readFile(filepath.Join(dir, day+ext))
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, filePoints...)
+	}
+	return points, nil
+}
+
+// readFile parses a .raw ("ts,value") or .5m ("ts,mean,min,max,count") file.
+// A missing file is empty and malformed lines, e.g. from a torn write, are skipped.
+func readFile(path string) ([]Point, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var points []Point
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) != 2 && len(fields) != 5 {
+			continue
+		}
+
+		ts, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		values := make([]float64, len(fields)-1)
+		valid := true
+		for i, field := range fields[1:] {
+			if values[i], err = strconv.ParseFloat(field, 64); err != nil {
+				valid = false
+			}
+		}
+		if !valid {
+			continue
+		}
+
+		p := Point{Timestamp: ts, Value: values[0], Min: values[0], Max: values[0], Count: 1}
+		if len(values) == 4 {
+			p.Min, p.Max, p.Count = values[1], values[2], max(int(values[3]), 1)
+		}
+		points = append(points, p)
+	}
+
+	return points, scanner.Err()
+}
+
+// aggregate groups sorted points into buckets aligned to loc, weighting pre-aggregated
+// points by their count
+func aggregate(points []Point, bucket time.Duration, loc *time.Location) []Point {
+	var out []Point
+	var sum float64
+
+	for _, p := range points {
+		start := bucketStart(p.Timestamp, bucket, loc)
+		if len(out) == 0 || out[len(out)-1].Timestamp != start {
+			if len(out) > 0 {
+				out[len(out)-1].Value = sum / float64(out[len(out)-1].Count)
+			}
+			out = append(out, Point{Timestamp: start, Min: p.Min, Max: p.Max})
+			sum = 0
+		}
+
+		cur := &out[len(out)-1]
+		sum += p.Value * float64(p.Count)
+		cur.Count += p.Count
+		cur.Min = min(cur.Min, p.Min)
+		cur.Max = max(cur.Max, p.Max)
+	}
+	if len(out) > 0 {
+		out[len(out)-1].Value = sum / float64(out[len(out)-1].Count)
+	}
+
+	return out
+}
+
+// bucketStart returns the start of the bucket holding ts, in Unix milliseconds. Daily
+// buckets start at local midnight, so days around a DST change are 23 or 25 hours long,
+// and shorter buckets follow the local clock, e.g. hours at :30 in India.
+func bucketStart(ts int64, bucket time.Duration, loc *time.Location) int64 {
+	t := time.UnixMilli(ts).In(loc)
+	if bucket >= 24*time.Hour {
+		year, month, day := t.Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, loc).UnixMilli()
+	}
+
+	_, offset := t.Zone()
+	width := bucket.Milliseconds()
+	local := ts + int64(offset)*1000
+	return ts - (local%width+width)%width
+}
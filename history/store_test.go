@@ -0,0 +1,273 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+	_ "time/tzdata" // zones for the local time tests, whatever the machine has
+)
+
+// at parses an RFC 3339 time
+func at(t *testing.T, s string) time.Time {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+// ms parses an RFC 3339 time as Unix milliseconds
+func ms(t *testing.T, s string) int64 {
+	return at(t, s).UnixMilli()
+}
+
+// openTestStore opens a store in a temporary directory with buckets in loc
+func openTestStore(t *testing.T, policy Policy, loc *time.Location) *Store {
+	t.Helper()
+	s, err := Open(t.TempDir(), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.loc = loc
+	return s
+}
+
+// record stores samples of one series
+func record(t *testing.T, s *Store, samples map[string]float64) {
+	t.Helper()
+	for ts, v := range samples {
+		if err := s.Record("1_43_2_1", 58604, at(t, ts), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	s := openTestStore(t, DefaultPolicy, time.UTC)
+	record(t, s, map[string]float64{
+		"2024-03-01T23:58:00Z": 50,
+		"2024-03-01T23:59:00Z": 51,
+		"2024-03-02T00:00:00Z": 52, // the next UTC day's file
+		"2024-03-02T00:01:00Z": 53.5,
+		"2024-03-02T06:00:00Z": 60,
+	})
+	if err := s.Record("1_43_2_1", 58604, time.Now(), 0.0/zero()); err == nil {
+		t.Error("Record accepted NaN")
+	}
+
+	// A torn write leaves a partial line that is skipped
+	f, err := os.OpenFile(filepath.Join(s.seriesDir("1_43_2_1", 58604), "2024-03-02"+rawExt), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("1709338000000,5")
+	f.Close()
+
+	// Inclusive at both ends, across the day boundary, sorted
+	got, err := s.Query("1_43_2_1", 58604, at(t, "2024-03-01T23:59:00Z"), at(t, "2024-03-02T00:01:00Z"), ResolutionRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Point{
+		{Timestamp: ms(t, "2024-03-01T23:59:00Z"), Value: 51, Min: 51, Max: 51, Count: 1},
+		{Timestamp: ms(t, "2024-03-02T00:00:00Z"), Value: 52, Min: 52, Max: 52, Count: 1},
+		{Timestamp: ms(t, "2024-03-02T00:01:00Z"), Value: 53.5, Min: 53.5, Max: 53.5, Count: 1},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Query = %+v, want %+v", got, want)
+	}
+
+	if got, err := s.Query("1_43_2_1", 13141, at(t, "2024-03-01T00:00:00Z"), at(t, "2024-03-03T00:00:00Z"), ResolutionRaw); err != nil || got != nil {
+		t.Errorf("Query of another point = %v, %v, want nothing", got, err)
+	}
+	if _, err := s.Query("1_43_2_1", 58604, at(t, "2024-03-02T00:00:00Z"), at(t, "2024-03-01T00:00:00Z"), ResolutionRaw); err == nil {
+		t.Error("Query accepted an end before the start")
+	}
+	if _, err := s.Query("1_43_2_1", 58604, at(t, "2024-03-01T00:00:00Z"), at(t, "2024-03-02T00:00:00Z"), "2m"); err == nil {
+		t.Error("Query accepted an unknown resolution")
+	}
+}
+
+// zero hides a division by zero from the compiler
+func zero() float64 { return 0 }
+
+func TestAggregate(t *testing.T) {
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Fatal(err)
+	}
+	india := time.FixedZone("IST", 5*3600+1800)
+
+	raw := func(ts string, v float64) Point {
+		return Point{Timestamp: ms(t, ts), Value: v, Min: v, Max: v, Count: 1}
+	}
+	tests := []struct {
+		name   string
+		bucket time.Duration
+		loc    *time.Location
+		points []Point
+		want   []Point
+	}{
+		{
+			name:   "min, max and mean per bucket",
+			bucket: 5 * time.Minute,
+			loc:    time.UTC,
+			points: []Point{raw("2024-03-01T10:00:00Z", 10), raw("2024-03-01T10:01:00Z", 30), raw("2024-03-01T10:04:59Z", 20)},
+			want:   []Point{{Timestamp: ms(t, "2024-03-01T10:00:00Z"), Value: 20, Min: 10, Max: 30, Count: 3}},
+		},
+		{
+			name:   "partial buckets and gaps",
+			bucket: 5 * time.Minute,
+			loc:    time.UTC,
+			points: []Point{raw("2024-03-01T10:04:00Z", 1), raw("2024-03-01T10:05:00Z", 2), raw("2024-03-01T10:21:00Z", 4)},
+			want: []Point{
+				{Timestamp: ms(t, "2024-03-01T10:00:00Z"), Value: 1, Min: 1, Max: 1, Count: 1},
+				{Timestamp: ms(t, "2024-03-01T10:05:00Z"), Value: 2, Min: 2, Max: 2, Count: 1},
+				{Timestamp: ms(t, "2024-03-01T10:20:00Z"), Value: 4, Min: 4, Max: 4, Count: 1},
+			},
+		},
+		{
+			name:   "aggregates are weighted by their count",
+			bucket: time.Hour,
+			loc:    time.UTC,
+			points: []Point{
+				{Timestamp: ms(t, "2024-03-01T10:00:00Z"), Value: 10, Min: 5, Max: 15, Count: 3},
+				{Timestamp: ms(t, "2024-03-01T10:05:00Z"), Value: 30, Min: 25, Max: 40, Count: 1},
+			},
+			want: []Point{{Timestamp: ms(t, "2024-03-01T10:00:00Z"), Value: 15, Min: 5, Max: 40, Count: 4}},
+		},
+		{
+			name:   "hours follow a half hour offset",
+			bucket: time.Hour,
+			loc:    india,
+			points: []Point{raw("2024-03-01T04:29:00Z", 1), raw("2024-03-01T04:30:00Z", 3)}, // 09:59 and 10:00 IST
+			want: []Point{
+				{Timestamp: ms(t, "2024-03-01T03:30:00Z"), Value: 1, Min: 1, Max: 1, Count: 1},
+				{Timestamp: ms(t, "2024-03-01T04:30:00Z"), Value: 3, Min: 3, Max: 3, Count: 1},
+			},
+		},
+		{
+			name:   "days run from local midnight",
+			bucket: 24 * time.Hour,
+			loc:    sydney,
+			// 23:59 on the 1st and 00:00 and 10:00 on the 2nd in Sydney (AEDT, +11)
+			points: []Point{raw("2024-03-01T12:59:00Z", 1), raw("2024-03-01T13:00:00Z", 2), raw("2024-03-01T23:00:00Z", 4)},
+			want: []Point{
+				{Timestamp: ms(t, "2024-02-29T13:00:00Z"), Value: 1, Min: 1, Max: 1, Count: 1},
+				{Timestamp: ms(t, "2024-03-01T13:00:00Z"), Value: 3, Min: 2, Max: 4, Count: 2},
+			},
+		},
+		{
+			name:   "a day ending daylight saving time is 25 hours",
+			bucket: 24 * time.Hour,
+			loc:    sydney,
+			// Sydney left AEDT at 03:00 on 7 April 2024; 00:00 +11 to 23:59 +10 is one day
+			points: []Point{raw("2024-04-06T13:00:00Z", 1), raw("2024-04-07T13:59:00Z", 3), raw("2024-04-07T14:00:00Z", 5)},
+			want: []Point{
+				{Timestamp: ms(t, "2024-04-06T13:00:00Z"), Value: 2, Min: 1, Max: 3, Count: 2},
+				{Timestamp: ms(t, "2024-04-07T14:00:00Z"), Value: 5, Min: 5, Max: 5, Count: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aggregate(tt.points, tt.bucket, tt.loc); !slices.Equal(got, tt.want) {
+				t.Errorf("aggregate =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryDailyInLocalTime(t *testing.T) {
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := openTestStore(t, DefaultPolicy, sydney)
+	record(t, s, map[string]float64{
+		"2024-03-01T12:00:00Z": 10, // 23:00 on the 1st in Sydney
+		"2024-03-01T14:00:00Z": 20, // 01:00 on the 2nd, still the 1st in UTC
+		"2024-03-02T02:00:00Z": 40,
+	})
+
+	got, err := s.Query("1_43_2_1", 58604, at(t, "2024-03-01T00:00:00Z"), at(t, "2024-03-03T00:00:00Z"), ResolutionDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Point{
+		{Timestamp: ms(t, "2024-02-29T13:00:00Z"), Value: 10, Min: 10, Max: 10, Count: 1},
+		{Timestamp: ms(t, "2024-03-01T13:00:00Z"), Value: 30, Min: 20, Max: 40, Count: 2},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("daily Query = %+v, want %+v", got, want)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	s := openTestStore(t, Policy{RawRetention: 2 * 24 * time.Hour, Retention: 10 * 24 * time.Hour}, time.UTC)
+	record(t, s, map[string]float64{
+		"2024-03-01T12:00:00Z": 1, // past retention
+		"2024-03-15T12:00:00Z": 10,
+		"2024-03-15T12:02:00Z": 20,
+		"2024-03-15T12:07:00Z": 5,
+		"2024-03-15T23:59:59Z": 7,
+		"2024-03-19T12:00:00Z": 42, // recent
+	})
+	dir := s.seriesDir("1_43_2_1", 58604)
+	from, to := at(t, "2024-03-01T00:00:00Z"), at(t, "2024-03-20T00:00:00Z")
+
+	before, err := s.Query("1_43_2_1", 58604, from, to, Resolution5Min)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Twice, as it runs hourly
+	now := at(t, "2024-03-20T12:00:00Z")
+	for range 2 {
+		if err := s.Compact(now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, e := range entries {
+		files = append(files, e.Name())
+	}
+	if want := []string{"2024-03-15.5m", "2024-03-19.raw"}; !slices.Equal(files, want) {
+		t.Errorf("files after compacting = %v, want %v", files, want)
+	}
+
+	// Downsampling keeps 5 minute queries exact and drops what retention expired
+	after, err := s.Query("1_43_2_1", 58604, from, to, Resolution5Min)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(after, before[1:]) {
+		t.Errorf("5 minute Query after compacting =\n%+v\nwant\n%+v", after, before[1:])
+	}
+	want := []Point{
+		{Timestamp: ms(t, "2024-03-15T12:00:00Z"), Value: 15, Min: 10, Max: 20, Count: 2},
+		{Timestamp: ms(t, "2024-03-15T12:05:00Z"), Value: 5, Min: 5, Max: 5, Count: 1},
+		{Timestamp: ms(t, "2024-03-15T23:55:00Z"), Value: 7, Min: 7, Max: 7, Count: 1},
+		{Timestamp: ms(t, "2024-03-19T12:00:00Z"), Value: 42, Min: 42, Max: 42, Count: 1},
+	}
+	if !slices.Equal(after, want) {
+		t.Errorf("5 minute Query after compacting =\n%+v\nwant\n%+v", after, want)
+	}
+
+	// Coarser queries weight the downsampled buckets by their samples
+	daily, err := s.Query("1_43_2_1", 58604, at(t, "2024-03-15T00:00:00Z"), at(t, "2024-03-15T23:59:59Z"), ResolutionDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(daily) != 1 || daily[0].Value != 10.5 || daily[0].Count != 4 || daily[0].Min != 5 || daily[0].Max != 20 {
+		t.Errorf("daily Query after compacting = %+v, want the mean of all four samples", daily)
+	}
+}
@@ -0,0 +1,230 @@
+package isolarcloud
+
+import (
+	"context"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AlarmLevel is the severity the gateway gives a fault or alarm, 1 being the most severe
+type AlarmLevel int
+
+const (
+	AlarmLevelImportant  AlarmLevel = 1
+	AlarmLevelNormal     AlarmLevel = 2
+	AlarmLevelPrompt     AlarmLevel = 3
+	AlarmLevelSuggestion AlarmLevel = 4
+)
+
+// String names the level as the iSolarCloud portal does
+func (l AlarmLevel) String() string {
+	switch l {
+	case AlarmLevelImportant:
+		return "important"
+	case AlarmLevelNormal:
+		return "normal"
+	case AlarmLevelPrompt:
+		return "prompt"
+	case AlarmLevelSuggestion:
+		return "suggestion"
+	}
+	return "unknown"
+}
+
+// Alarm is a fault or alarm raised by a device of a plant
+type Alarm struct {
+	ID          string     `json:"id"`
+	PsID        int        `json:"psId"`
+	PsKey       string     `json:"psKey"`
+	DeviceName  string     `json:"deviceName"`
+	DeviceSN    string     `json:"deviceSn,omitempty"`
+	Code        string     `json:"code"`
+	Name        string     `json:"name"`
+	Level       AlarmLevel `json:"level"`
+	LevelName   string     `json:"levelName"`
+	Description string     `json:"description,omitempty"` // the likely cause
+	Advice      string     `json:"advice,omitempty"`      // suggested repair
+	StartTime   int64      `json:"startTime"`             // Unix milliseconds
+	EndTime     int64      `json:"endTime,omitempty"`     // Unix milliseconds, 0 while the alarm is active
+	Active      bool       `json:"active"`
+}
+
+// AlarmQuery filters a plant's alarms. Zero times leave that end of the range open.
+// Times are sent and parsed in From's location, which should match the plant's time
+// zone; UTC is used when From is zero.
+type AlarmQuery struct {
+	From       time.Time
+	To         time.Time
+	ActiveOnly bool
+}
+
+// AlarmPage is one page of a plant's alarms
+type AlarmPage struct {
+	PageList []Alarm `json:"pageList"`
+	RowCount int     `json:"rowCount"`
+	Page     int     `json:"page"`
+	Size     int     `json:"size"`
+}
+
+// HasMore reports whether pages after this one exist
+func (p *AlarmPage) HasMore() bool {
+	return p.Page*p.Size < p.RowCount
+}
+
+// alarmTimeLayouts are the formats alarm times are returned in
+var alarmTimeLayouts = []string{"2006-01-02 15:04:05", minuteLayout}
+
+// AlarmListPage retrieves a single page of a plant's faults and alarms, newest first.
+// Pages start at 1. Not every gateway applies the query's filters, so the page may hold
+// fewer alarms than its size even when more pages follow.
+func (c *Client) AlarmListPage(ctx context.Context, psID int, q AlarmQuery, page, size int) (*AlarmPage, error) {
+	alarms, rowCount, err := c.alarmRows(ctx, psID, q, page, size)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AlarmPage{PageList: []Alarm{}, RowCount: rowCount, Page: page, Size: size}
+	for _, alarm := range alarms {
+		if q.matches(alarm) {
+			result.PageList = append(result.PageList, alarm)
+		}
+	}
+	return result, nil
+}
+
+// Alarms iterates over a plant's faults and alarms, fetching further pages as needed
+func (c *Client) Alarms(ctx context.Context, psID int, q AlarmQuery) iter.Seq2[Alarm, error] {
+	rows := walkPages(ctx, DefaultPageSize, func(ctx context.Context, page, size int) ([]Alarm, int, error) {
+		return c.alarmRows(ctx, psID, q, page, size)
+	})
+	return func(yield func(Alarm, error) bool) {
+		for alarm, err := range rows {
+			if err == nil && !q.matches(alarm) {
+				continue
+			}
+			if !yield(alarm, err) {
+				return
+			}
+		}
+	}
+}
+
+// AlarmList retrieves every fault and alarm of a plant matching the query
+func (c *Client) AlarmList(ctx context.Context, psID int, q AlarmQuery) ([]Alarm, error) {
+	return collect(c.Alarms(ctx, psID, q))
+}
+
+// alarmRows requests a page of alarms, returning them unfiltered along with the
+// gateway's total row count
+func (c *Client) alarmRows(ctx context.Context, psID int, q AlarmQuery, page, size int) ([]Alarm, int, error) {
+	loc := q.location()
+	reqBody := map[string]interface{}{
+		"ps_id": strconv.Itoa(psID),
+		"page":  page,
+		"size":  size,
+	}
+	if !q.From.IsZero() {
+		reqBody["start_time"] = q.From.In(loc).Format(minuteLayout)
+	}
+	if !q.To.IsZero() {
+		reqBody["end_time"] = q.To.In(loc).Format(minuteLayout)
+	}
+	if q.ActiveOnly {
+		reqBody["process_status"] = "0"
+	}
+
+	var result struct {
+		PageList []map[string]interface{} `json:"pageList"`
+		RowCount int                      `json:"rowCount"`
+	}
+	if err := c.Do(ctx, "/openapi/platform/getFaultAlarmList", reqBody, &result); err != nil {
+		return nil, 0, err
+	}
+
+	alarms := make([]Alarm, 0, len(result.PageList))
+	for _, row := range result.PageList {
+		alarms = append(alarms, parseAlarm(row, psID, loc))
+	}
+	return alarms, result.RowCount, nil
+}
+
+// location is the time zone alarm times are sent and parsed in
+func (q AlarmQuery) location() *time.Location {
+	if q.From.IsZero() {
+		return time.UTC
+	}
+	return q.From.Location()
+}
+
+// matches reports whether an alarm was active at some point in the query's range
+func (q AlarmQuery) matches(alarm Alarm) bool {
+	switch {
+	case q.ActiveOnly && !alarm.Active:
+		return false
+	case !q.From.IsZero() && alarm.EndTime != 0 && alarm.EndTime < q.From.UnixMilli():
+		return false
+	case !q.To.IsZero() && alarm.StartTime > q.To.UnixMilli():
+		return false
+	}
+	return true
+}
+
+// parseAlarm reads an alarm row. Field names differ between gateway versions, so
+// several are tried for each.
+func parseAlarm(row map[string]interface{}, psID int, loc *time.Location) Alarm {
+	alarm := Alarm{
+		ID:          rowString(row, "id", "fault_id", "alarm_id"),
+		PsID:        psID,
+		PsKey:       rowString(row, "ps_key"),
+		DeviceName:  rowString(row, "device_name", "dev_name"),
+		DeviceSN:    rowString(row, "device_sn", "sn"),
+		Code:        rowString(row, "fault_code", "alarm_code"),
+		Name:        rowString(row, "fault_name", "alarm_name", "fault_type_name"),
+		Description: rowString(row, "fault_reason", "fault_desc", "alarm_reason"),
+		Advice:      rowString(row, "repair_advice", "opt_advice", "suggestion"),
+	}
+	if id, err := strconv.Atoi(rowString(row, "ps_id")); err == nil {
+		alarm.PsID = id
+	}
+	if level, err := strconv.Atoi(rowString(row, "fault_level", "alarm_level")); err == nil {
+		alarm.Level = AlarmLevel(level)
+	}
+	alarm.LevelName = alarm.Level.String()
+
+	alarm.StartTime = rowMillis(row, loc, "create_time", "happen_time", "start_time")
+	alarm.EndTime = rowMillis(row, loc, "recover_time", "end_time")
+	// process_status only tracks whether someone handled the alarm, not whether it cleared
+	alarm.Active = alarm.EndTime == 0
+	return alarm
+}
+
+// rowString returns the first of fields present in a row, as a string
+func rowString(row map[string]interface{}, fields ...string) string {
+	for _, field := range fields {
+		switch v := row[field].(type) {
+		case string:
+			if v = strings.TrimSpace(v); v != "" {
+				return v
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return ""
+}
+
+// rowMillis parses the first of fields present in a row as a time
+func rowMillis(row map[string]interface{}, loc *time.Location, fields ...string) int64 {
+	raw := rowString(row, fields...)
+	if raw == "" {
+		return 0
+	}
+	for _, layout := range alarmTimeLayouts {
+		if ts, err := time.ParseInLocation(layout, raw, loc); err == nil {
+			return ts.UnixMilli()
+		}
+	}
+	return 0
+}
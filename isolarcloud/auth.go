@@ -0,0 +1,52 @@
+package isolarcloud
+
+import (
+	"context"
+	"time"
+)
+
+// LoginResultData contains OAuth token data
+type LoginResultData struct {
+	AccessToken  string   `json:"access_token"`
+	TokenType    string   `json:"token_type"`
+	RefreshToken string   `json:"refresh_token"`
+	ExpiresIn    int      `json:"expires_in"`
+	AuthPsList   []string `json:"auth_ps_list"`
+	AuthUser     int      `json:"auth_user"`
+}
+
+// ExpiryMillis returns the token expiry as a Unix millisecond timestamp relative to now
+func (l *LoginResultData) ExpiryMillis() int64 {
+	return time.Now().Add(time.Duration(l.ExpiresIn) * time.Second).UnixMilli()
+}
+
+// ExchangeCode exchanges an OAuth authorization code for access tokens
+func (c *Client) ExchangeCode(ctx context.Context, code, redirectURL string) (*LoginResultData, error) {
+	return c.tokenRequest(ctx, "/openapi/apiManage/token", map[string]interface{}{
+		"grant_type":   "authorization_code",
+		"code":         code,
+		"redirect_uri": redirectURL,
+	})
+}
+
+// RefreshToken exchanges a refresh token for a new access token
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*LoginResultData, error) {
+	return c.tokenRequest(ctx, "/openapi/apiManage/refreshToken", map[string]interface{}{
+		"refresh_token": refreshToken,
+	})
+}
+
+// tokenRequest calls an unauthenticated token endpoint
+func (c *Client) tokenRequest(ctx context.Context, path string, reqBody map[string]interface{}) (*LoginResultData, error) {
+	apiResp, err := c.post(ctx, path, reqBody, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var loginData LoginResultData
+	if err := decodeResult(path, apiResp, &loginData); err != nil {
+		return nil, err
+	}
+
+	return &loginData, nil
+}
@@ -0,0 +1,210 @@
+package isolarcloud
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxRealTimePsKeys is the most devices sent in one getDeviceRealTimeData request
+const maxRealTimePsKeys = 50
+
+// WithRealTimeBatching merges DeviceRealTime calls for the same device type made within
+// window of each other into a single getDeviceRealTimeData request
+func WithRealTimeBatching(window time.Duration) Option {
+	return func(c *Client) {
+		if window > 0 {
+			c.realTime = &realTimeBatcher{client: c, window: window, pending: map[int]*realTimeBatch{}}
+		} else {
+			c.realTime = nil
+		}
+	}
+}
+
+// realTimeBatcher collects real-time requests until their batch is sent
+type realTimeBatcher struct {
+	client  *Client
+	window  time.Duration
+	mu      sync.Mutex
+	pending map[int]*realTimeBatch // by device type
+}
+
+// realTimeBatch is one getDeviceRealTimeData request shared by several callers
+type realTimeBatch struct {
+	psKeys   []string
+	pointIDs []int
+	callers  int
+	done     chan struct{}
+	data     *RealTimeData
+	err      error
+}
+
+// fetch adds a request to the pending batch for its device type and waits for the result
+func (b *realTimeBatcher) fetch(ctx context.Context, deviceType int, psKeys []string, pointIDs []int) (*RealTimeData, error) {
+	b.mu.Lock()
+	batch := b.pending[deviceType]
+	if batch == nil || len(batch.psKeys)+len(psKeys) > maxRealTimePsKeys {
+		batch = &realTimeBatch{done: make(chan struct{})}
+		b.pending[deviceType] = batch
+		time.AfterFunc(b.window, func() { b.send(deviceType, batch) })
+	}
+	for _, key := range psKeys {
+		if !slices.Contains(batch.psKeys, key) {
+			batch.psKeys = append(batch.psKeys, key)
+		}
+	}
+	for _, id := range pointIDs {
+		if !slices.Contains(batch.pointIDs, id) {
+			batch.pointIDs = append(batch.pointIDs, id)
+		}
+	}
+	batch.callers++
+	b.mu.Unlock()
+
+	select {
+	case <-batch.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if batch.err != nil {
+		return nil, batch.err
+	}
+	if batch.callers == 1 {
+		return batch.data, nil
+	}
+	return batch.data.subset(psKeys, pointIDs), nil
+}
+
+// send closes a batch to new callers and requests its data. The callers' contexts may
+// already be done, so the request is bounded by the HTTP client's timeout instead.
+func (b *realTimeBatcher) send(deviceType int, batch *realTimeBatch) {
+	b.mu.Lock()
+	if b.pending[deviceType] == batch {
+		delete(b.pending, deviceType)
+	}
+	b.mu.Unlock()
+
+	batch.data, batch.err = b.client.deviceRealTime(context.Background(), deviceType, batch.psKeys, batch.pointIDs)
+	close(batch.done)
+}
+
+// subset returns the devices and points one caller of a batch asked for
+func (d *RealTimeData) subset(psKeys []string, pointIDs []int) *RealTimeData {
+	wanted := map[string]bool{}
+	for _, id := range pointIDs {
+		wanted[fmt.Sprintf("p%d", id)] = true
+	}
+
+	result := &RealTimeData{}
+	for _, device := range d.DevicePoints {
+		psKey, _ := device["ps_key"].(string)
+		if !slices.Contains(psKeys, psKey) {
+			continue
+		}
+
+		// Keep device metadata, drop points another caller asked for
+		points := map[string]interface{}{}
+		for key, value := range device {
+			if _, isPoint := ParsePointKey(key); isPoint && !wanted[key] {
+				continue
+			}
+			points[key] = value
+		}
+		result.DevicePoints = append(result.DevicePoints, points)
+	}
+
+	for _, entry := range d.PointDict {
+		if slices.Contains(pointIDs, entry.PointID) {
+			result.PointDict = append(result.PointDict, entry)
+		}
+	}
+
+	return result
+}
+
+// DeviceRealTimeRequest asks for points of devices that share a device type
+type DeviceRealTimeRequest struct {
+	DeviceType int      `json:"deviceType"`
+	PsKeys     []string `json:"psKeys"`
+	PointIDs   []int    `json:"pointIds"`
+}
+
+// DeviceResult is the outcome for one device of a batch
+type DeviceResult struct {
+	Points map[string]interface{} // device_point keyed by "p<pointID>" plus device metadata
+	Err    error
+}
+
+// RealTimeBatchResult holds the devices of a batch keyed by ps_key, along with the
+// dictionary of every point returned
+type RealTimeBatchResult struct {
+	Devices   map[string]DeviceResult
+	PointDict []PointDictEntry
+}
+
+// DeviceRealTimeBatch fetches real-time points of many devices. Each request is split into
+// chunks the gateway accepts and up to workers chunks are fetched at once. A failed chunk
+// sets the error of each of its devices rather than failing the batch.
+func (c *Client) DeviceRealTimeBatch(ctx context.Context, requests []DeviceRealTimeRequest, workers int) *RealTimeBatchResult {
+	type chunk struct {
+		deviceType int
+		psKeys     []string
+		pointIDs   []int
+	}
+	var chunks []chunk
+	for _, req := range requests {
+		for keys := range slices.Chunk(req.PsKeys, maxRealTimePsKeys) {
+			chunks = append(chunks, chunk{req.DeviceType, keys, req.PointIDs})
+		}
+	}
+
+	result := &RealTimeBatchResult{Devices: map[string]DeviceResult{}}
+	var mu sync.Mutex
+	jobs := make(chan chunk)
+	var wg sync.WaitGroup
+	for range min(max(workers, 1), len(chunks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ch := range jobs {
+				data, err := c.deviceRealTime(ctx, ch.deviceType, ch.psKeys, ch.pointIDs)
+
+				mu.Lock()
+				if err != nil {
+					for _, key := range ch.psKeys {
+						result.Devices[key] = DeviceResult{Err: err}
+					}
+					mu.Unlock()
+					continue
+				}
+				for _, device := range data.DevicePoints {
+					key, _ := device["ps_key"].(string)
+					if key == "" && len(ch.psKeys) == 1 {
+						key = ch.psKeys[0]
+					}
+					if key != "" {
+						result.Devices[key] = DeviceResult{Points: device}
+					}
+				}
+				for _, key := range ch.psKeys {
+					if _, ok := result.Devices[key]; !ok {
+						result.Devices[key] = DeviceResult{Err: fmt.Errorf("no data returned for %s", key)}
+					}
+				}
+				result.PointDict = append(result.PointDict, data.PointDict...)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, ch := range chunks {
+		jobs <- ch
+	}
+	close(jobs)
+	wg.Wait()
+
+	return result
+}
@@ -0,0 +1,323 @@
+package isolarcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// realTimeRequest is a getDeviceRealTimeData request received by realTimeGateway
+type realTimeRequest struct {
+	deviceType int
+	psKeys     []string
+	pointIDs   []string
+}
+
+// realTimeGateway answers getDeviceRealTimeData with every point requested for every
+// device, valued "<ps_key>/p<point>". respond may answer a request itself instead, by
+// returning true.
+type realTimeGateway struct {
+	mu       sync.Mutex
+	requests []realTimeRequest
+	respond  func(w http.ResponseWriter, req realTimeRequest) bool
+}
+
+// newRealTimeClient creates a client for a realTimeGateway that doesn't retry
+func newRealTimeClient(t *testing.T, g *realTimeGateway, options ...Option) *Client {
+	srv := testGateway(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		req := realTimeRequest{deviceType: int(body["device_type"].(float64))}
+		for _, key := range body["ps_key_list"].([]interface{}) {
+			req.psKeys = append(req.psKeys, key.(string))
+		}
+		for _, id := range body["point_id_list"].([]interface{}) {
+			req.pointIDs = append(req.pointIDs, id.(string))
+		}
+		g.mu.Lock()
+		g.requests = append(g.requests, req)
+		respond := g.respond
+		g.mu.Unlock()
+		if respond != nil && respond(w, req) {
+			return
+		}
+
+		var devices []interface{}
+		for _, key := range req.psKeys {
+			point := map[string]interface{}{"ps_key": key, "device_name": "Device " + key}
+			for _, id := range req.pointIDs {
+				point["p"+id] = key + "/p" + id
+			}
+			devices = append(devices, map[string]interface{}{"device_point": point})
+		}
+		var dict []interface{}
+		for _, id := range req.pointIDs {
+			dict = append(dict, map[string]interface{}{"point_id": id, "point_name": "Point " + id, "point_unit": "W"})
+		}
+		writeResult(w, "1", "success", map[string]interface{}{"device_point_list": devices, "point_dict": dict})
+	})
+	return NewClient("test-appkey", "test-secret", append([]Option{
+		WithBaseURL(srv.URL),
+		WithHTTPClient(srv.Client()),
+		WithAuth(StaticToken("tok")),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+	}, options...)...)
+}
+
+// received returns the requests made so far, ordered by device type then first ps_key
+func (g *realTimeGateway) received() []realTimeRequest {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	requests := slices.Clone(g.requests)
+	slices.SortFunc(requests, func(a, b realTimeRequest) int {
+		if a.deviceType != b.deviceType {
+			return a.deviceType - b.deviceType
+		}
+		return slices.Compare(a.psKeys, b.psKeys)
+	})
+	return requests
+}
+
+// psKeysOf lists the ps_key of each device, sorted
+func psKeysOf(devices []map[string]interface{}) []string {
+	var keys []string
+	for _, device := range devices {
+		key, _ := device["ps_key"].(string)
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// numberedKeys returns n ps_keys starting at first
+func numberedKeys(first, n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("1_14_1_%d", first+i)
+	}
+	return keys
+}
+
+func TestRealTimeBatchingMergesRequests(t *testing.T) {
+	gateway := &realTimeGateway{}
+	client := newRealTimeClient(t, gateway, WithRealTimeBatching(200*time.Millisecond))
+
+	calls := []struct {
+		deviceType int
+		psKeys     []string
+		pointIDs   []int
+	}{
+		{14, []string{"1_14_1_1", "1_14_1_2"}, []int{13011}},
+		{14, []string{"1_14_1_2", "1_14_1_3"}, []int{13141}},
+		{43, []string{"1_43_2_1"}, []int{58604}},
+	}
+	results := make([]*RealTimeData, len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := client.DeviceRealTime(context.Background(), call.deviceType, call.psKeys, call.pointIDs)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = data
+		}()
+	}
+	wg.Wait()
+
+	// One request per device type, asking for everything its callers wanted
+	want := []realTimeRequest{
+		{deviceType: 14, psKeys: []string{"1_14_1_1", "1_14_1_2", "1_14_1_3"}, pointIDs: []string{"13011", "13141"}},
+		{deviceType: 43, psKeys: []string{"1_43_2_1"}, pointIDs: []string{"58604"}},
+	}
+	got := gateway.received()
+	for i := range got {
+		slices.Sort(got[i].psKeys)
+		slices.Sort(got[i].pointIDs)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("gateway received %v, want %v", got, want)
+	}
+
+	// Each caller gets back its own devices and points, and the devices' metadata
+	for i, call := range calls {
+		data := results[i]
+		if keys := psKeysOf(data.DevicePoints); !slices.Equal(keys, call.psKeys) {
+			t.Errorf("caller %d got devices %v, want %v", i, keys, call.psKeys)
+		}
+		for _, device := range data.DevicePoints {
+			key := device["ps_key"].(string)
+			point := fmt.Sprintf("p%d", call.pointIDs[0])
+			if len(device) != 3 || device[point] != key+"/"+point || device["device_name"] != "Device "+key {
+				t.Errorf("caller %d got %v for %s, want only %s and metadata", i, device, key, point)
+			}
+		}
+		if len(data.PointDict) != 1 || data.PointDict[0].PointID != call.pointIDs[0] {
+			t.Errorf("caller %d got point dictionary %+v", i, data.PointDict)
+		}
+	}
+}
+
+func TestRealTimeBatchingLimitsDevicesPerRequest(t *testing.T) {
+	gateway := &realTimeGateway{}
+	client := newRealTimeClient(t, gateway, WithRealTimeBatching(200*time.Millisecond))
+
+	// Two callers with 30 devices each don't fit in one request
+	var wg sync.WaitGroup
+	for _, first := range []int{1, 31} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := client.DeviceRealTime(context.Background(), 14, numberedKeys(first, 30), []int{13011})
+			if err != nil {
+				t.Error(err)
+			} else if len(data.DevicePoints) != 30 {
+				t.Errorf("caller got %d devices, want 30", len(data.DevicePoints))
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := gateway.received()
+	if len(got) != 2 || len(got[0].psKeys) != 30 || len(got[1].psKeys) != 30 {
+		t.Errorf("gateway received %v, want two requests of 30 devices", got)
+	}
+}
+
+func TestRealTimeBatchingSharesErrors(t *testing.T) {
+	gateway := &realTimeGateway{respond: func(w http.ResponseWriter, req realTimeRequest) bool {
+		writeResult(w, "E00004", "no permission", nil)
+		return true
+	}}
+	client := newRealTimeClient(t, gateway, WithRealTimeBatching(200*time.Millisecond))
+
+	var wg sync.WaitGroup
+	for _, key := range []string{"1_14_1_1", "1_14_1_2"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.DeviceRealTime(context.Background(), 14, []string{key}, []int{13011}); err == nil {
+				t.Errorf("caller for %s got no error", key)
+			}
+		}()
+	}
+
+	// A caller giving up doesn't stop the batch for the others
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.DeviceRealTime(ctx, 14, []string{"1_14_1_3"}, []int{13011}); err != context.DeadlineExceeded {
+		t.Errorf("cancelled caller got %v", err)
+	}
+	wg.Wait()
+
+	if got := gateway.received(); len(got) != 1 || len(got[0].psKeys) != 3 {
+		t.Errorf("gateway received %v, want one request for all three devices", got)
+	}
+}
+
+func TestDeviceRealTimeBatch(t *testing.T) {
+	var inFlight, maxInFlight int
+	var mu sync.Mutex
+	gateway := &realTimeGateway{}
+	gateway.respond = func(w http.ResponseWriter, req realTimeRequest) bool {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		switch req.psKeys[0] {
+		case "1_14_1_51":
+			// The second chunk fails
+			writeResult(w, "E00004", "no permission", nil)
+			return true
+		case "1_43_2_1":
+			// Only one of the batteries comes back
+			point := map[string]interface{}{"ps_key": "1_43_2_1", "p58604": 87}
+			writeResult(w, "1", "success", map[string]interface{}{
+				"device_point_list": []interface{}{map[string]interface{}{"device_point": point}},
+			})
+			return true
+		}
+		return false
+	}
+	client := newRealTimeClient(t, gateway)
+
+	inverters := numberedKeys(1, 120)
+	result := client.DeviceRealTimeBatch(context.Background(), []DeviceRealTimeRequest{
+		{DeviceType: 14, PsKeys: inverters, PointIDs: []int{13011}},
+		{DeviceType: 43, PsKeys: []string{"1_43_2_1", "1_43_2_2"}, PointIDs: []int{58604}},
+	}, 2)
+
+	// 120 inverters take three requests, the batteries one
+	var sizes []int
+	for _, req := range gateway.received() {
+		sizes = append(sizes, len(req.psKeys))
+	}
+	slices.Sort(sizes)
+	if !slices.Equal(sizes, []int{2, 20, 50, 50}) {
+		t.Errorf("gateway received requests of %v devices, want 50, 50 and 20 inverters and 2 batteries", sizes)
+	}
+	if maxInFlight > 2 {
+		t.Errorf("%d requests in flight at once, want at most 2 workers", maxInFlight)
+	}
+
+	if len(result.Devices) != 122 {
+		t.Errorf("result has %d devices, want 122", len(result.Devices))
+	}
+	for i, key := range inverters {
+		device := result.Devices[key]
+		if failed := i >= 50 && i < 100; failed {
+			if device.Err == nil || device.Points != nil {
+				t.Errorf("%s from the failed chunk = %+v, want its error", key, device)
+			}
+			continue
+		}
+		if device.Err != nil || device.Points["p13011"] != key+"/p13011" {
+			t.Errorf("%s = %+v, want its points", key, device)
+		}
+	}
+	if device := result.Devices["1_43_2_1"]; device.Err != nil || device.Points["p58604"] != float64(87) {
+		t.Errorf("1_43_2_1 = %+v, want its points", device)
+	}
+	if device := result.Devices["1_43_2_2"]; device.Err == nil {
+		t.Errorf("1_43_2_2 = %+v, want an error as nothing came back for it", device)
+	}
+	if len(result.PointDict) != 2 {
+		t.Errorf("point dictionary = %+v, want one entry per successful inverter chunk", result.PointDict)
+	}
+}
+
+func TestDeviceRealTimeBatchCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gateway := &realTimeGateway{respond: func(w http.ResponseWriter, req realTimeRequest) bool {
+		cancel()
+		return false
+	}}
+	client := newRealTimeClient(t, gateway)
+
+	// One worker, so the batch is cancelled during the first of five chunks
+	keys := numberedKeys(1, 250)
+	result := client.DeviceRealTimeBatch(ctx, []DeviceRealTimeRequest{{DeviceType: 14, PsKeys: keys, PointIDs: []int{13011}}}, 1)
+
+	time.Sleep(50 * time.Millisecond) // for any request sent in the background to arrive
+	if got := gateway.received(); len(got) != 1 {
+		t.Errorf("gateway received %d requests, want none after the cancellation", len(got))
+	}
+	if len(result.Devices) != len(keys) {
+		t.Errorf("result has %d devices, want all %d", len(result.Devices), len(keys))
+	}
+	for _, key := range keys[maxRealTimePsKeys:] {
+		if err := result.Devices[key].Err; !errors.Is(err, context.Canceled) {
+			t.Errorf("%s after the cancellation = %v, want context.Canceled", key, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package isolarcloud
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a gateway's circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // the gateway is healthy
+	BreakerOpen     BreakerState = "open"      // the gateway is failing, requests are refused
+	BreakerHalfOpen BreakerState = "half_open" // one trial request is checking if it recovered
+)
+
+// BreakerStatus describes a circuit breaker for display
+type BreakerStatus struct {
+	State     BreakerState `json:"state"`
+	Failures  int          `json:"failures"`          // consecutive failed requests
+	RetryAt   int64        `json:"retryAt,omitempty"` // Unix milliseconds the next trial is allowed, while open
+	LastError string       `json:"lastError,omitempty"`
+}
+
+// CircuitBreaker stops requests to a gateway after consecutive transport errors or 5xx
+// responses, so an outage isn't made worse by every poller retrying. After cooldown a
+// single trial request is let through; its success closes the breaker again. Clients
+// of the same gateway should share one.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	onChange  func(BreakerStatus)
+
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial request is in flight
+	lastErr  string
+}
+
+// NewCircuitBreaker opens after threshold consecutive failures and tries again after
+// cooldown. onChange, which may be nil, is called whenever the state changes.
+func NewCircuitBreaker(threshold int, cooldown time.Duration, onChange func(BreakerStatus)) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: max(threshold, 1),
+		cooldown:  cooldown,
+		onChange:  onChange,
+		state:     BreakerClosed,
+	}
+}
+
+// WithCircuitBreaker refuses requests while the breaker is open
+func WithCircuitBreaker(b *CircuitBreaker) Option {
+	return func(c *Client) {
+		c.breaker = b
+	}
+}
+
+// Status returns the breaker's current state
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.statusLocked()
+}
+
+// statusLocked builds the status. The caller must hold mu.
+func (b *CircuitBreaker) statusLocked() BreakerStatus {
+	status := BreakerStatus{State: b.state, Failures: b.failures, LastError: b.lastErr}
+	if b.state == BreakerOpen {
+		status.RetryAt = b.openedAt.Add(b.cooldown).UnixMilli()
+	}
+	return status
+}
+
+// allow reports whether a request may be sent, moving an open breaker to half-open
+// once the cooldown has passed
+func (b *CircuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	var changed *BreakerStatus
+	defer func() { b.notify(changed) }()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		retryAt := b.openedAt.Add(b.cooldown)
+		if time.Now().Before(retryAt) {
+			return fmt.Errorf("%w, retrying after %s", ErrGatewayUnavailable, retryAt.Format(time.TimeOnly))
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+		status := b.statusLocked()
+		changed = &status
+	case BreakerHalfOpen:
+		if b.trial {
+			return fmt.Errorf("%w, checking whether it recovered", ErrGatewayUnavailable)
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of a request it allowed. Requests the
+// caller gave up on say nothing about the gateway and only end a trial.
+func (b *CircuitBreaker) record(ctx context.Context, apiResp *ApiResponse, err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	var changed *BreakerStatus
+	defer func() { b.notify(changed) }()
+	defer b.mu.Unlock()
+
+	b.trial = false
+
+	failed := false
+	switch {
+	case ctx.Err() != nil:
+		return
+	case err != nil:
+		failed = true
+		b.lastErr = err.Error()
+	case isGatewayFailure(apiResp):
+		failed = true
+		b.lastErr = fmt.Sprintf("HTTP %d", apiResp.httpStatus)
+	}
+
+	previous := b.state
+	if failed {
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+			b.state = BreakerOpen
+			b.openedAt = time.Now()
+		}
+	} else {
+		b.failures = 0
+		b.lastErr = ""
+		b.state = BreakerClosed
+	}
+
+	if b.state != previous {
+		status := b.statusLocked()
+		changed = &status
+	}
+}
+
+// notify calls onChange outside the lock
+func (b *CircuitBreaker) notify(status *BreakerStatus) {
+	if status != nil && b.onChange != nil {
+		b.onChange(*status)
+	}
+}
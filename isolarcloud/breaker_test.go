@@ -0,0 +1,148 @@
+package isolarcloud
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// switchableGateway creates a client, with its own circuit breaker, for a gateway that
+// fails with HTTP 500 while failing is set. It also returns the gateway's request count
+// and a func listing the breaker's state changes so far.
+func switchableGateway(t *testing.T, failing *atomic.Bool) (*Client, *atomic.Int32, *CircuitBreaker, func() []BreakerState) {
+	var mu sync.Mutex
+	var changes []BreakerState
+	breaker := NewCircuitBreaker(2, 100*time.Millisecond, func(s BreakerStatus) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, s.State)
+	})
+
+	srv, requests := scriptedGateway(t, func(w http.ResponseWriter) {
+		if failing.Load() {
+			httpError(http.StatusInternalServerError, "")(w)
+			return
+		}
+		succeed(w)
+	})
+	client := retryingClient(srv, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, WithCircuitBreaker(breaker))
+
+	return client, requests, breaker, func() []BreakerState {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(changes)
+	}
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	client, requests, breaker, changes := switchableGateway(t, &failing)
+
+	// The second failed attempt opens the breaker, which stops the third
+	err := client.Do(context.Background(), "/openapi/test", nil, nil)
+	if !errors.Is(err, ErrGatewayUnavailable) || ErrorKind(err) != "gateway_unavailable" {
+		t.Errorf("Do while failing = %v, want ErrGatewayUnavailable", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("gateway got %d requests, want 2 before the breaker opened", n)
+	}
+	status := breaker.Status()
+	if status.State != BreakerOpen || status.Failures != 2 || status.LastError != "HTTP 500" || status.RetryAt == 0 {
+		t.Errorf("status = %+v, want open after 2 failures", status)
+	}
+
+	// While open nothing reaches the gateway, and nothing is retried
+	start := time.Now()
+	for range 3 {
+		if err := client.Do(context.Background(), "/openapi/test", nil, nil); !errors.Is(err, ErrGatewayUnavailable) {
+			t.Errorf("Do while open = %v, want ErrGatewayUnavailable", err)
+		}
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("gateway got %d requests while open, want none", n)
+	}
+	if waited := time.Since(start); waited > 50*time.Millisecond {
+		t.Errorf("refusing requests took %s", waited)
+	}
+
+	// After the cooldown a trial request that fails opens it again
+	time.Sleep(150 * time.Millisecond)
+	client.Do(context.Background(), "/openapi/test", nil, nil)
+	if n := requests.Load(); n != 3 {
+		t.Errorf("gateway got %d requests after the cooldown, want one trial", n)
+	}
+	if state := breaker.Status().State; state != BreakerOpen {
+		t.Errorf("state after a failed trial = %s, want open", state)
+	}
+
+	// and one that succeeds closes it
+	failing.Store(false)
+	time.Sleep(150 * time.Millisecond)
+	if err := client.Do(context.Background(), "/openapi/test", nil, nil); err != nil {
+		t.Errorf("Do after recovery = %v", err)
+	}
+	if status := breaker.Status(); status.State != BreakerClosed || status.Failures != 0 || status.LastError != "" {
+		t.Errorf("status after recovery = %+v, want closed", status)
+	}
+
+	want := []BreakerState{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if got := changes(); !slices.Equal(got, want) {
+		t.Errorf("onChange saw %v, want %v", got, want)
+	}
+}
+
+func TestCircuitBreakerSingleTrial(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Millisecond, nil)
+	b.record(context.Background(), nil, errors.New("connection refused"))
+	time.Sleep(5 * time.Millisecond)
+
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow after the cooldown = %v, want a trial", err)
+	}
+	err := b.allow()
+	if !errors.Is(err, ErrGatewayUnavailable) || !strings.Contains(err.Error(), "checking whether it recovered") {
+		t.Errorf("allow during the trial = %v, want ErrGatewayUnavailable", err)
+	}
+
+	// A trial the caller gave up on says nothing about the gateway, but lets another through
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.record(ctx, nil, context.Canceled)
+	if status := b.Status(); status.State != BreakerHalfOpen || status.LastError != "connection refused" {
+		t.Errorf("status after a cancelled trial = %+v, want still half open", status)
+	}
+	if err := b.allow(); err != nil {
+		t.Errorf("allow after a cancelled trial = %v, want another trial", err)
+	}
+}
+
+func TestCircuitBreakerPerGateway(t *testing.T) {
+	var failing, healthy atomic.Bool
+	failing.Store(true)
+	down, downRequests, _, _ := switchableGateway(t, &failing)
+	up, _, _, _ := switchableGateway(t, &healthy)
+
+	down.Do(context.Background(), "/openapi/test", nil, nil)
+	if err := down.Do(context.Background(), "/openapi/test", nil, nil); !errors.Is(err, ErrGatewayUnavailable) {
+		t.Fatalf("Do to the failing gateway = %v, want ErrGatewayUnavailable", err)
+	}
+	if err := up.Do(context.Background(), "/openapi/test", nil, nil); err != nil {
+		t.Errorf("Do to another gateway = %v, want it unaffected", err)
+	}
+
+	// Clients sharing the failing gateway's breaker are refused too
+	other := NewClient("test-appkey", "test-secret", WithBaseURL(down.BaseURL()), WithAuth(StaticToken("tok")), WithCircuitBreaker(down.breaker))
+	if err := other.Do(context.Background(), "/openapi/test", nil, nil); !errors.Is(err, ErrGatewayUnavailable) {
+		t.Errorf("Do from another client of the failing gateway = %v, want ErrGatewayUnavailable", err)
+	}
+	if n := downRequests.Load(); n != 2 {
+		t.Errorf("failing gateway got %d requests, want 2", n)
+	}
+}
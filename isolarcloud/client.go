@@ -0,0 +1,262 @@
+// Package isolarcloud is a client for the Sungrow iSolarCloud OpenAPI gateway.
+package isolarcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the gateway used when none is configured
+const DefaultBaseURL = "https://augateway.isolarcloud.com"
+
+// AuthProvider supplies access tokens for authenticated requests
+type AuthProvider interface {
+	// AccessToken returns the token to send with the next request
+	AccessToken(ctx context.Context) (string, error)
+	// RefreshAccessToken is called after the gateway rejected staleToken and returns its replacement
+	RefreshAccessToken(ctx context.Context, staleToken string) (string, error)
+}
+
+// StaticToken is an AuthProvider for a fixed access token that cannot be refreshed
+type StaticToken string
+
+// AccessToken returns the fixed token
+func (t StaticToken) AccessToken(ctx context.Context) (string, error) {
+	if t == "" {
+		return "", ErrNotAuthenticated
+	}
+	return string(t), nil
+}
+
+// RefreshAccessToken always fails as a static token cannot be refreshed
+func (t StaticToken) RefreshAccessToken(ctx context.Context, staleToken string) (string, error) {
+	return "", fmt.Errorf("access token rejected and cannot be refreshed: %w", ErrTokenExpired)
+}
+
+// Client talks to a single iSolarCloud gateway on behalf of one appkey
+type Client struct {
+	baseURL    string
+	appKey     string
+	secretKey  string
+	httpClient *http.Client
+	auth       AuthProvider
+	observer   func(RequestInfo)
+	limiter    *RateLimiter
+	retry      RetryPolicy
+	breaker    *CircuitBreaker
+	flights    flightGroup
+	realTime   *realTimeBatcher
+}
+
+// RequestInfo describes a completed gateway request, for instrumentation
+type RequestInfo struct {
+	Path       string
+	Duration   time.Duration
+	HTTPStatus int
+	ResultCode string // empty when no response was decoded
+	Err        error  // transport or decoding error
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithBaseURL sets the gateway URL, e.g. https://gateway.isolarcloud.eu
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if baseURL != "" {
+			c.baseURL = strings.TrimRight(baseURL, "/")
+		}
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		if hc != nil {
+			c.httpClient = hc
+		}
+	}
+}
+
+// WithAuth sets the provider of access tokens for authenticated requests
+func WithAuth(auth AuthProvider) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+// WithObserver registers a function called after every gateway request
+func WithObserver(observer func(RequestInfo)) Option {
+	return func(c *Client) {
+		c.observer = observer
+	}
+}
+
+// NewClient creates a client for the given appkey and secret key
+func NewClient(appKey, secretKey string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:   DefaultBaseURL,
+		appKey:    appKey,
+		secretKey: secretKey,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		retry: DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// BaseURL returns the gateway URL the client sends requests to
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Do sends an authenticated request and decodes result_data into out (which may be nil).
+// If the gateway rejects the access token it is refreshed and the request retried once.
+// Callers making an identical request while one is in flight share its response.
+func (c *Client) Do(ctx context.Context, path string, reqBody map[string]interface{}, out interface{}) error {
+	if c.auth == nil {
+		return ErrNotAuthenticated
+	}
+
+	var apiResp *ApiResponse
+	var err error
+	if key, ok := requestKey(path, reqBody); ok {
+		apiResp, err = c.flights.do(ctx, key, func(ctx context.Context) (*ApiResponse, error) {
+			return c.authorizedPost(ctx, path, reqBody)
+		})
+	} else {
+		apiResp, err = c.authorizedPost(ctx, path, reqBody)
+	}
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(path, apiResp, out)
+}
+
+// authorizedPost sends a request with the current access token, refreshing it once if rejected
+func (c *Client) authorizedPost(ctx context.Context, path string, reqBody map[string]interface{}) (*ApiResponse, error) {
+	token, err := c.auth.AccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	apiResp, err := c.postWithRetry(ctx, path, reqBody, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if isAuthFailure(path, apiResp) {
+		token, err = c.auth.RefreshAccessToken(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		return c.postWithRetry(ctx, path, reqBody, token)
+	}
+
+	return apiResp, nil
+}
+
+// post performs a single gateway request once the rate limiter and circuit breaker allow
+// it. An empty token sends no Authorization header.
+func (c *Client) post(ctx context.Context, path string, reqBody map[string]interface{}, token string) (*ApiResponse, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	apiResp, err := c.roundTrip(ctx, path, reqBody, token)
+	c.breaker.record(ctx, apiResp, err)
+
+	if c.observer != nil {
+		info := RequestInfo{Path: path, Duration: time.Since(start), Err: err}
+		if apiResp != nil {
+			info.HTTPStatus = apiResp.httpStatus
+			info.ResultCode = apiResp.ResultCode
+		}
+		c.observer(info)
+	}
+
+	return apiResp, err
+}
+
+// roundTrip sends the request and decodes the response envelope
+func (c *Client) roundTrip(ctx context.Context, path string, reqBody map[string]interface{}, token string) (*ApiResponse, error) {
+	body := map[string]interface{}{"appkey": c.appKey}
+	for k, v := range reqBody {
+		body[k] = v
+	}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-access-key", c.secretKey)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResp ApiResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		if resp.StatusCode >= 400 {
+			// Gateways and proxies answer some errors with HTML or an empty body,
+			// leave it to decodeResult to report the status
+			return &ApiResponse{httpStatus: resp.StatusCode, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"))}, nil
+		}
+		return nil, fmt.Errorf("invalid response from %s (HTTP %d): %w", path, resp.StatusCode, err)
+	}
+	apiResp.httpStatus = resp.StatusCode
+	apiResp.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+
+	return &apiResp, nil
+}
+
+// decodeResult checks the result code and unmarshals result_data into out
+func decodeResult(path string, apiResp *ApiResponse, out interface{}) error {
+	if apiResp.ResultCode != "1" {
+		return newAPIError(path, apiResp)
+	}
+
+	if out == nil || len(apiResp.ResultData) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(apiResp.ResultData, out)
+}
+
+// isAuthFailure reports whether an API response was rejected because of the access token
+func isAuthFailure(path string, apiResp *ApiResponse) bool {
+	return apiResp.ResultCode != "1" && errors.Is(newAPIError(path, apiResp), ErrTokenExpired)
+}
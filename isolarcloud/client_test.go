@@ -0,0 +1,206 @@
+package isolarcloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// testGateway is an httptest stand-in for an iSolarCloud gateway. respond is called with
+// each request and its decoded body, and writes the response.
+func testGateway(t *testing.T, respond func(w http.ResponseWriter, r *http.Request, body map[string]interface{})) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		respond(w, r, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// writeResult writes a gateway response envelope
+func writeResult(w http.ResponseWriter, code, msg string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"req_serial_num": "test-serial",
+		"result_code":    code,
+		"result_msg":     msg,
+		"result_data":    data,
+	})
+}
+
+// testClient creates a client for a test gateway that doesn't retry
+func testClient(srv *httptest.Server, auth AuthProvider) *Client {
+	return NewClient("test-appkey", "test-secret",
+		WithBaseURL(srv.URL),
+		WithHTTPClient(srv.Client()),
+		WithAuth(auth),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1}),
+	)
+}
+
+// refreshingAuth hands out tokens from a list, moving to the next on each refresh
+type refreshingAuth struct {
+	mu        sync.Mutex
+	tokens    []string
+	refreshes int
+	stale     []string
+}
+
+func (a *refreshingAuth) AccessToken(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.tokens[a.refreshes], nil
+}
+
+func (a *refreshingAuth) RefreshAccessToken(ctx context.Context, staleToken string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stale = append(a.stale, staleToken)
+	a.refreshes++
+	return a.tokens[a.refreshes], nil
+}
+
+func TestDoSendsCredentialsAndDecodesResult(t *testing.T) {
+	srv := testGateway(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		if r.Method != http.MethodPost || r.URL.Path != "/openapi/test" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer tok" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.Header.Get("x-access-key"); got != "test-secret" {
+			t.Errorf("x-access-key = %q", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		if body["appkey"] != "test-appkey" || body["ps_id"] != float64(42) {
+			t.Errorf("body = %v", body)
+		}
+		writeResult(w, "1", "success", map[string]interface{}{"answer": 42})
+	})
+
+	var out struct {
+		Answer int `json:"answer"`
+	}
+	err := testClient(srv, StaticToken("tok")).Do(context.Background(), "/openapi/test", map[string]interface{}{"ps_id": 42}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Answer != 42 {
+		t.Errorf("answer = %d, want 42", out.Answer)
+	}
+}
+
+func TestDoReturnsAPIError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		code       string
+		wantKind   string
+		wantIs     error
+		wantStatus int
+	}{
+		{"no permission", http.StatusOK, "E00004", "no_permission", ErrNoPermission, http.StatusOK},
+		{"invalid appkey", http.StatusOK, "er_invalid_appkey", "invalid_appkey", ErrInvalidAppKey, http.StatusOK},
+		{"rate limited", http.StatusOK, "er_request_too_frequent", "rate_limited", ErrRateLimited, http.StatusOK},
+		{"unknown code", http.StatusOK, "E99999", "api_error", nil, http.StatusOK},
+		{"HTML error page", http.StatusForbidden, "", "no_permission", ErrNoPermission, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := testGateway(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+				if tt.code == "" {
+					w.WriteHeader(tt.status)
+					w.Write([]byte("<html>Forbidden</html>"))
+					return
+				}
+				w.WriteHeader(tt.status)
+				writeResult(w, tt.code, "rejected", nil)
+			})
+
+			err := testClient(srv, StaticToken("tok")).Do(context.Background(), "/openapi/test", nil, nil)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error = %v, want *APIError", err)
+			}
+			if apiErr.ResultCode != tt.code || apiErr.HTTPStatus != tt.wantStatus || apiErr.Path != "/openapi/test" {
+				t.Errorf("APIError = %+v", apiErr)
+			}
+			if tt.code != "" && (apiErr.ResultMsg != "rejected" || apiErr.ReqSerialNum != "test-serial") {
+				t.Errorf("APIError = %+v", apiErr)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.wantIs)
+			}
+			if kind := ErrorKind(err); kind != tt.wantKind {
+				t.Errorf("ErrorKind = %q, want %q", kind, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestDoRefreshesRejectedTokenOnce(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	srv := testGateway(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		mu.Lock()
+		seen = append(seen, r.Header.Get("Authorization"))
+		mu.Unlock()
+		if r.Header.Get("Authorization") == "Bearer fresh" {
+			writeResult(w, "1", "success", nil)
+			return
+		}
+		writeResult(w, "E00003", "token invalid", nil)
+	})
+
+	auth := &refreshingAuth{tokens: []string{"stale", "fresh"}}
+	if err := testClient(srv, auth).Do(context.Background(), "/openapi/test", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if auth.refreshes != 1 || auth.stale[0] != "stale" {
+		t.Errorf("refreshes = %d of %v, want one of the stale token", auth.refreshes, auth.stale)
+	}
+	if len(seen) != 2 || seen[0] != "Bearer stale" || seen[1] != "Bearer fresh" {
+		t.Errorf("Authorization headers = %v", seen)
+	}
+}
+
+func TestDoGivesUpWhenRefreshedTokenIsRejected(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	srv := testGateway(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		writeResult(w, "er_invalid_token", "token invalid", nil)
+	})
+
+	auth := &refreshingAuth{tokens: []string{"stale", "also-stale", "never-used"}}
+	err := testClient(srv, auth).Do(context.Background(), "/openapi/test", nil, nil)
+	if !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("error = %v, want ErrTokenExpired", err)
+	}
+	if auth.refreshes != 1 || requests != 2 {
+		t.Errorf("refreshes = %d, requests = %d, want 1 and 2", auth.refreshes, requests)
+	}
+}
+
+func TestDoWithoutTokenIsNotAuthenticated(t *testing.T) {
+	srv := testGateway(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		t.Error("request sent without a token")
+	})
+
+	err := testClient(srv, StaticToken("")).Do(context.Background(), "/openapi/test", nil, nil)
+	if !errors.Is(err, ErrNotAuthenticated) {
+		t.Errorf("error = %v, want ErrNotAuthenticated", err)
+	}
+}
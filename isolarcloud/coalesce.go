@@ -0,0 +1,64 @@
+package isolarcloud
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// flightGroup de-duplicates identical requests that are in flight at the same time
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// flight is a request shared by every caller that asked for it while it ran
+type flight struct {
+	done chan struct{}
+	resp *ApiResponse
+	err  error
+}
+
+// requestKey identifies a request by endpoint and body. Map keys are marshalled in
+// sorted order, so equal bodies give equal keys.
+func requestKey(path string, reqBody map[string]interface{}) (string, bool) {
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", false
+	}
+	return path + "\x00" + string(body), true
+}
+
+// do runs fn, or waits for the identical request already running. fn is not cancelled
+// when the caller that started it gives up, as others may still be waiting for it, but
+// a caller that has already given up doesn't start one.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (*ApiResponse, error)) (*ApiResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flight{}
+	}
+	f, ok := g.calls[key]
+	if !ok {
+		f = &flight{done: make(chan struct{})}
+		g.calls[key] = f
+		go func() {
+			f.resp, f.err = fn(context.WithoutCancel(ctx))
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(f.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.resp, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,133 @@
+package isolarcloud
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFlightGroupSharesCall(t *testing.T) {
+	var g flightGroup
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	fn := func(ctx context.Context) (*ApiResponse, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		if ctx.Err() != nil {
+			t.Errorf("shared call cancelled: %v", ctx.Err())
+		}
+		return &ApiResponse{ReqSerialNum: "shared"}, nil
+	}
+
+	// The first caller gives up, the call carries on for the rest
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := g.do(ctx, "key", fn)
+		first <- err
+	}()
+	<-started
+
+	var wg sync.WaitGroup
+	responses := make([]*ApiResponse, 10)
+	for i := range responses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := g.do(context.Background(), "key", fn)
+			if err != nil {
+				t.Error(err)
+			}
+			responses[i] = resp
+		}()
+	}
+
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond) // let the callers join
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn called %d times, want once", n)
+	}
+	for i, resp := range responses {
+		if resp == nil || resp != responses[0] {
+			t.Errorf("caller %d got %v, want the shared response", i, resp)
+		}
+	}
+}
+
+func TestFlightGroupDoesNotCacheErrors(t *testing.T) {
+	var g flightGroup
+	errGateway := errors.New("gateway down")
+	calls := 0
+	fn := func(ctx context.Context) (*ApiResponse, error) {
+		calls++
+		if calls == 1 {
+			return nil, errGateway
+		}
+		return &ApiResponse{}, nil
+	}
+
+	if _, err := g.do(context.Background(), "key", fn); !errors.Is(err, errGateway) {
+		t.Errorf("first do = %v, want the gateway error", err)
+	}
+	if _, err := g.do(context.Background(), "key", fn); err != nil {
+		t.Errorf("do after a failure = %v, want a new call", err)
+	}
+	if calls != 2 {
+		t.Errorf("fn called %d times, want twice", calls)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.calls) != 0 {
+		t.Errorf("%d calls left in flight", len(g.calls))
+	}
+}
+
+func TestDoSharesIdenticalRequests(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	srv := testGateway(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		requests.Add(1)
+		<-release
+		writeResult(w, "1", "success", map[string]interface{}{"ps_id": body["ps_id"]})
+	})
+	client := testClient(srv, StaticToken("tok"))
+
+	// Two callers ask for plant 1, one for plant 2
+	var wg sync.WaitGroup
+	got := make([]int, 3)
+	for i, psID := range []int{1, 1, 2} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out struct {
+				PsID int `json:"ps_id"`
+			}
+			if err := client.Do(context.Background(), "/openapi/test", map[string]interface{}{"ps_id": psID}, &out); err != nil {
+				t.Error(err)
+			}
+			got[i] = out.PsID
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := requests.Load(); n != 2 {
+		t.Errorf("gateway got %d requests, want one per distinct body", n)
+	}
+	if got[0] != 1 || got[1] != 1 || got[2] != 2 {
+		t.Errorf("callers got plants %v, want 1, 1, 2", got)
+	}
+}
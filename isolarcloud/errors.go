@@ -0,0 +1,117 @@
+package isolarcloud
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors for the failure classes callers commonly need to tell apart.
+// An *APIError matches one of these with errors.Is.
+var (
+	ErrNotAuthenticated = errors.New("not authenticated")
+	ErrTokenExpired     = errors.New("access token expired or invalid")
+	ErrRateLimited      = errors.New("rate limited by gateway")
+	ErrInvalidAppKey    = errors.New("invalid appkey or secret key")
+	ErrNoPermission     = errors.New("no permission for this resource")
+	// ErrGatewayUnavailable is returned without contacting a gateway whose circuit breaker is open
+	ErrGatewayUnavailable = errors.New("gateway unavailable")
+)
+
+// resultCodeErrors maps gateway result codes to the sentinel they represent
+var resultCodeErrors = map[string]error{
+	"E00003":                  ErrTokenExpired,
+	"er_token_login_invalid":  ErrTokenExpired,
+	"er_invalid_token":        ErrTokenExpired,
+	"E00004":                  ErrNoPermission,
+	"er_no_permission":        ErrNoPermission,
+	"er_unauthorized_ps":      ErrNoPermission,
+	"E00001":                  ErrInvalidAppKey,
+	"er_invalid_appkey":       ErrInvalidAppKey,
+	"er_invalid_access_key":   ErrInvalidAppKey,
+	"E00005":                  ErrRateLimited,
+	"er_request_too_frequent": ErrRateLimited,
+	"er_flow_limit":           ErrRateLimited,
+}
+
+// httpStatusErrors maps HTTP status codes to a sentinel when the result code is not recognised
+var httpStatusErrors = map[int]error{
+	http.StatusUnauthorized:    ErrTokenExpired,
+	http.StatusForbidden:       ErrNoPermission,
+	http.StatusTooManyRequests: ErrRateLimited,
+}
+
+// APIError is returned when the gateway answers with a result code other than "1"
+// or a non-JSON error status
+type APIError struct {
+	Path         string `json:"path"`
+	ResultCode   string `json:"resultCode"`
+	ResultMsg    string `json:"resultMsg"`
+	ReqSerialNum string `json:"reqSerialNum,omitempty"`
+	HTTPStatus   int    `json:"httpStatus"`
+
+	kind error
+}
+
+// newAPIError builds an APIError from a decoded gateway response
+func newAPIError(path string, apiResp *ApiResponse) *APIError {
+	e := &APIError{
+		Path:         path,
+		ResultCode:   apiResp.ResultCode,
+		ResultMsg:    apiResp.ResultMsg,
+		ReqSerialNum: apiResp.ReqSerialNum,
+		HTTPStatus:   apiResp.httpStatus,
+	}
+	e.kind = resultCodeErrors[e.ResultCode]
+	if e.kind == nil {
+		e.kind = httpStatusErrors[e.HTTPStatus]
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	msg := e.ResultMsg
+	if msg == "" {
+		msg = http.StatusText(e.HTTPStatus)
+	}
+	if e.ResultCode == "" {
+		return fmt.Sprintf("API error: %s (HTTP %d)", msg, e.HTTPStatus)
+	}
+	return fmt.Sprintf("API error: %s (code %s)", msg, e.ResultCode)
+}
+
+// Unwrap returns the sentinel matching the result code, so errors.Is(err, ErrTokenExpired) works
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// Kind returns a short machine-readable name for the error class
+func (e *APIError) Kind() string {
+	return ErrorKind(e)
+}
+
+// ErrorKind returns a short machine-readable name for the class of err
+func ErrorKind(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrNotAuthenticated):
+		return "not_authenticated"
+	case errors.Is(err, ErrTokenExpired):
+		return "token_expired"
+	case errors.Is(err, ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, ErrInvalidAppKey):
+		return "invalid_appkey"
+	case errors.Is(err, ErrNoPermission):
+		return "no_permission"
+	case errors.Is(err, ErrGatewayUnavailable):
+		return "gateway_unavailable"
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return "api_error"
+	}
+	return "error"
+}
@@ -0,0 +1,140 @@
+package isolarcloud
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DefaultGatewayID is the region used when none is configured
+const DefaultGatewayID = "au"
+
+// Gateway is an iSolarCloud region
+type Gateway struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	BaseURL string `json:"baseUrl"`
+	// AuthURL is the page users authorise an app on. The developer portal shows the
+	// exact link for an app, which takes precedence when it differs.
+	AuthURL string `json:"authUrl"`
+}
+
+// gateways are the known regions
+var gateways = []Gateway{
+	{
+		ID:      "au",
+		Name:    "Australia",
+		BaseURL: DefaultBaseURL,
+		AuthURL: "https://auapi.isolarcloud.com:443/openapi/apiManage/token",
+	},
+	{
+		ID:      "eu",
+		Name:    "Europe",
+		BaseURL: "https://gateway.isolarcloud.eu",
+		AuthURL: "https://gateway.isolarcloud.eu/openapi/apiManage/token",
+	},
+	{
+		ID:      "intl",
+		Name:    "International",
+		BaseURL: "https://gateway.isolarcloud.com.hk",
+		AuthURL: "https://gateway.isolarcloud.com.hk/openapi/apiManage/token",
+	},
+	{
+		ID:      "cn",
+		Name:    "China",
+		BaseURL: "https://gateway.isolarcloud.com",
+		AuthURL: "https://gateway.isolarcloud.com/openapi/apiManage/token",
+	},
+}
+
+// Gateways returns the known regions, the default first
+func Gateways() []Gateway {
+	return append([]Gateway(nil), gateways...)
+}
+
+// GatewayByID returns the region with an ID
+func GatewayByID(id string) (Gateway, bool) {
+	for _, g := range gateways {
+		if g.ID == id {
+			return g, true
+		}
+	}
+	return Gateway{}, false
+}
+
+// GatewayForURL returns the region a gateway URL belongs to
+func GatewayForURL(baseURL string) (Gateway, bool) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	for _, g := range gateways {
+		if strings.EqualFold(g.BaseURL, baseURL) {
+			return g, true
+		}
+	}
+	return Gateway{}, false
+}
+
+// DefaultGateway returns the region used when none is configured
+func DefaultGateway() Gateway {
+	g, _ := GatewayByID(DefaultGatewayID)
+	return g
+}
+
+// ProbeStatus is the outcome of probing a gateway with an appkey
+type ProbeStatus string
+
+const (
+	ProbeAccepted    ProbeStatus = "accepted"    // the gateway knows the appkey and secret key
+	ProbeRejected    ProbeStatus = "rejected"    // the gateway refused the appkey or secret key
+	ProbeUnreachable ProbeStatus = "unreachable" // the gateway could not be asked
+)
+
+// ProbeResult is the outcome of probing one gateway
+type ProbeResult struct {
+	Gateway Gateway     `json:"gateway"`
+	Status  ProbeStatus `json:"status"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// ProbeGateways asks each gateway whether it recognises an appkey and secret key, to find
+// the region an app is registered in. Each gateway is sent a token refresh with a
+// placeholder token: a region that doesn't know the app answers with an invalid appkey
+// error, while any other API error means the keys were accepted. Gateways are probed
+// concurrently and results are returned in the same order.
+func ProbeGateways(ctx context.Context, appKey, secretKey string, gateways []Gateway, opts ...Option) []ProbeResult {
+	results := make([]ProbeResult, len(gateways))
+
+	var wg sync.WaitGroup
+	for i, g := range gateways {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Clone so concurrent probes don't append into a shared backing array
+			client := NewClient(appKey, secretKey, append(slices.Clone(opts), WithBaseURL(g.BaseURL))...)
+			_, err := client.RefreshToken(ctx, "probe")
+			results[i] = probeResult(g, err)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// probeResult classifies the error from a probe request
+func probeResult(g Gateway, err error) ProbeResult {
+	result := ProbeResult{Gateway: g, Status: ProbeAccepted}
+	if err == nil {
+		return result
+	}
+
+	result.Error = err.Error()
+	var apiErr *APIError
+	switch {
+	case errors.Is(err, ErrInvalidAppKey):
+		result.Status = ProbeRejected
+	case !errors.As(err, &apiErr):
+		result.Status = ProbeUnreachable
+	}
+	return result
+}
@@ -0,0 +1,35 @@
+package isolarcloud
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestProbeGateways(t *testing.T) {
+	rejecting := testGateway(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		writeResult(w, "er_invalid_appkey", "invalid appkey", nil)
+	})
+	accepting := testGateway(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		writeResult(w, "er_invalid_token", "refresh token invalid", nil)
+	})
+	down := testGateway(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {})
+	down.Close()
+
+	gateways := []Gateway{
+		{ID: "rejecting", BaseURL: rejecting.URL},
+		{ID: "accepting", BaseURL: accepting.URL},
+		{ID: "down", BaseURL: down.URL},
+	}
+	// Spare capacity would let probes overwrite each other's base URL if opts were shared
+	opts := make([]Option, 0, 8)
+	opts = append(opts, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+
+	results := ProbeGateways(context.Background(), "appkey", "secret", gateways, opts...)
+	want := []ProbeStatus{ProbeRejected, ProbeAccepted, ProbeUnreachable}
+	for i, result := range results {
+		if result.Gateway.ID != gateways[i].ID || result.Status != want[i] {
+			t.Errorf("result %d = %s %s (%s), want %s %s", i, result.Gateway.ID, result.Status, result.Error, gateways[i].ID, want[i])
+		}
+	}
+}
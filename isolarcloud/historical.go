@@ -0,0 +1,268 @@
+package isolarcloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Period is the granularity of statistic queries
+type Period string
+
+const (
+	PeriodDay   Period = "day"
+	PeriodMonth Period = "month"
+	PeriodYear  Period = "year"
+)
+
+// maxMinuteDataSpan is the longest time span the gateway accepts per minute data
+// request. Longer ranges are split into chunks.
+const maxMinuteDataSpan = 3 * time.Hour
+
+// periodFormat describes how a statistic period is encoded in requests
+type periodFormat struct {
+	dataType string
+	layout   string
+	periods  int // most periods the gateway accepts per request
+	// start returns the start of the period t is in, and add moves a period start n
+	// periods on
+	start func(t time.Time) time.Time
+	add   func(t time.Time, n int) time.Time
+}
+
+var periodFormats = map[Period]periodFormat{
+	PeriodDay: {
+		dataType: "1", layout: "20060102", periods: 31,
+		start: func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()) },
+		add:   func(t time.Time, n int) time.Time { return t.AddDate(0, 0, n) },
+	},
+	PeriodMonth: {
+		dataType: "2", layout: "200601", periods: 12,
+		start: func(t time.Time) time.Time { return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()) },
+		add:   func(t time.Time, n int) time.Time { return t.AddDate(0, n, 0) },
+	},
+	PeriodYear: {
+		dataType: "3", layout: "2006", periods: 10,
+		start: func(t time.Time) time.Time { return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location()) },
+		add:   func(t time.Time, n int) time.Time { return t.AddDate(n, 0, 0) },
+	},
+}
+
+// chunks splits [from, to] into windows of whole periods. Requests name periods rather
+// than instants, so each window starts on the period after the previous one ended;
+// overlapping windows would return the boundary period twice.
+func (f periodFormat) chunks(from, to time.Time) [][2]time.Time {
+	var chunks [][2]time.Time
+	for start := f.start(from); !start.After(to); start = f.add(start, f.periods) {
+		end := f.add(start, f.periods-1)
+		if end.After(to) {
+			end = to
+		}
+		chunks = append(chunks, [2]time.Time{start, end})
+	}
+	return chunks
+}
+
+// minuteLayout is the timestamp format used by the minute data endpoints
+const minuteLayout = "20060102150405"
+
+// Sample is a single timestamped value
+type Sample struct {
+	Timestamp int64   `json:"timestamp"` // Unix milliseconds
+	Value     float64 `json:"value"`
+}
+
+// Series is the history of one point of one device (ps_key) or plant (ps_id)
+type Series struct {
+	Key     string   `json:"key"`
+	PointID int      `json:"pointId"`
+	Samples []Sample `json:"samples"`
+}
+
+// DeviceMinuteData retrieves minute-level history of device points. Times are sent and
+// parsed in from's location, which should match the plant's time zone.
+func (c *Client) DeviceMinuteData(ctx context.Context, psKeys []string, pointIDs []int, from, to time.Time, interval time.Duration) ([]Series, error) {
+	return c.minuteData(ctx, "/openapi/platform/getDevicePointMinuteDataList", "ps_key_list", psKeys, pointIDs, from, to, interval)
+}
+
+// PlantMinuteData retrieves minute-level history of plant points. Times are sent and
+// parsed in from's location, which should match the plant's time zone.
+func (c *Client) PlantMinuteData(ctx context.Context, psIDs []int, pointIDs []int, from, to time.Time, interval time.Duration) ([]Series, error) {
+	return c.minuteData(ctx, "/openapi/platform/getPowerStationPointMinuteDataList", "ps_id_list", intStrings(psIDs), pointIDs, from, to, interval)
+}
+
+// DeviceStatistics retrieves daily, monthly or yearly statistics of device points
+func (c *Client) DeviceStatistics(ctx context.Context, psKeys []string, pointIDs []int, period Period, from, to time.Time) ([]Series, error) {
+	return c.statistics(ctx, "/openapi/platform/getDevicePointsDayMonthYearDataList", "ps_key_list", psKeys, pointIDs, period, from, to)
+}
+
+// PlantStatistics retrieves daily, monthly or yearly statistics of plant points
+func (c *Client) PlantStatistics(ctx context.Context, psIDs []int, pointIDs []int, period Period, from, to time.Time) ([]Series, error) {
+	return c.statistics(ctx, "/openapi/platform/getPowerStationPointDayMonthYearDataList", "ps_id_list", intStrings(psIDs), pointIDs, period, from, to)
+}
+
+// minuteData queries a minute data endpoint, splitting the range into allowed spans
+func (c *Client) minuteData(ctx context.Context, path, keyField string, keys []string, pointIDs []int, from, to time.Time, interval time.Duration) ([]Series, error) {
+	if interval < time.Minute {
+		interval = 5 * time.Minute
+	}
+
+	merged := newSeriesSet()
+	for _, chunk := range chunkRange(from, to, maxMinuteDataSpan) {
+		var result map[string][]map[string]interface{}
+		err := c.Do(ctx, path, map[string]interface{}{
+			keyField:           keys,
+			"points":           pointList(pointIDs),
+			"start_time_stamp": chunk[0].Format(minuteLayout),
+			"end_time_stamp":   chunk[1].Format(minuteLayout),
+			"minute_interval":  int(interval.Minutes()),
+		}, &result)
+		if err != nil {
+			return nil, err
+		}
+		merged.add(result, minuteLayout, from.Location())
+	}
+
+	return merged.series(), nil
+}
+
+// statistics queries a day/month/year endpoint, splitting the range into allowed spans
+func (c *Client) statistics(ctx context.Context, path, keyField string, keys []string, pointIDs []int, period Period, from, to time.Time) ([]Series, error) {
+	format, ok := periodFormats[period]
+	if !ok {
+		return nil, fmt.Errorf("unknown period %q", period)
+	}
+
+	merged := newSeriesSet()
+	for _, chunk := range format.chunks(from, to) {
+		var result map[string][]map[string]interface{}
+		err := c.Do(ctx, path, map[string]interface{}{
+			keyField:     keys,
+			"data_point": pointList(pointIDs),
+			"data_type":  format.dataType,
+			"start_time": chunk[0].Format(format.layout),
+			"end_time":   chunk[1].Format(format.layout),
+			"order":      "0",
+		}, &result)
+		if err != nil {
+			return nil, err
+		}
+		merged.add(result, format.layout, from.Location())
+	}
+
+	return merged.series(), nil
+}
+
+// chunkRange splits [from, to] into consecutive windows no longer than span, for
+// requests with second resolution
+func chunkRange(from, to time.Time, span time.Duration) [][2]time.Time {
+	var chunks [][2]time.Time
+	for start := from; !start.After(to); {
+		end := start.Add(span)
+		if end.After(to) {
+			end = to
+		}
+		chunks = append(chunks, [2]time.Time{start, end})
+		if !end.Before(to) {
+			break
+		}
+		start = end.Add(time.Second)
+	}
+	return chunks
+}
+
+// pointList formats point IDs as the comma separated "p<ID>" list the history endpoints expect
+func pointList(pointIDs []int) string {
+	points := make([]string, len(pointIDs))
+	for i, id := range pointIDs {
+		points[i] = fmt.Sprintf("p%d", id)
+	}
+	return strings.Join(points, ",")
+}
+
+// intStrings converts IDs to the string form used in request lists
+func intStrings(ids []int) []string {
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = strconv.Itoa(id)
+	}
+	return strs
+}
+
+// timestampFields are the row fields that may hold a history row's time
+var timestampFields = []string{"time_stamp", "date_id", "time"}
+
+// seriesSet merges history rows from several chunked responses
+type seriesSet struct {
+	byKey map[string]*Series
+}
+
+func newSeriesSet() *seriesSet {
+	return &seriesSet{byKey: map[string]*Series{}}
+}
+
+// add merges a response of rows keyed by ps_key or ps_id
+func (s *seriesSet) add(result map[string][]map[string]interface{}, layout string, loc *time.Location) {
+	for key, rows := range result {
+		for _, row := range rows {
+			ts, ok := rowTime(row, layout, loc)
+			if !ok {
+				continue
+			}
+			for field, raw := range row {
+				pointID, ok := ParsePointKey(field)
+				if !ok {
+					continue
+				}
+				value, ok := ParseValue(raw)
+				if !ok {
+					continue
+				}
+
+				id := fmt.Sprintf("%s/%d", key, pointID)
+				series, ok := s.byKey[id]
+				if !ok {
+					series = &Series{Key: key, PointID: pointID}
+					s.byKey[id] = series
+				}
+				series.Samples = append(series.Samples, Sample{Timestamp: ts.UnixMilli(), Value: value})
+			}
+		}
+	}
+}
+
+// series returns the merged series sorted by key and point, with samples in time order
+func (s *seriesSet) series() []Series {
+	out := make([]Series, 0, len(s.byKey))
+	for _, series := range s.byKey {
+		sort.Slice(series.Samples, func(i, j int) bool { return series.Samples[i].Timestamp < series.Samples[j].Timestamp })
+		out = append(out, *series)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Key != out[j].Key {
+			return out[i].Key < out[j].Key
+		}
+		return out[i].PointID < out[j].PointID
+	})
+	return out
+}
+
+// rowTime parses the time of a history row
+func rowTime(row map[string]interface{}, layout string, loc *time.Location) (time.Time, bool) {
+	for _, field := range timestampFields {
+		raw, ok := row[field].(string)
+		if !ok || raw == "" {
+			continue
+		}
+		// Minute rows may carry a shorter layout than requested, e.g. without seconds
+		for _, l := range []string{layout, minuteLayout, "200601021504", "2006-01-02 15:04:05"} {
+			if ts, err := time.ParseInLocation(l, raw, loc); err == nil {
+				return ts, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,93 @@
+package isolarcloud
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatisticsChunksOnPeriodBoundaries(t *testing.T) {
+	tests := []struct {
+		name        string
+		period      Period
+		from, to    time.Time
+		wantChunks  [][2]string
+		wantSamples int
+	}{
+		{
+			name:   "days",
+			period: PeriodDay,
+			from:   time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+			to:     time.Date(2024, 3, 15, 8, 0, 0, 0, time.UTC),
+			wantChunks: [][2]string{
+				{"20240101", "20240131"},
+				{"20240201", "20240302"},
+				{"20240303", "20240315"},
+			},
+			wantSamples: 75,
+		},
+		{
+			name:   "months",
+			period: PeriodMonth,
+			from:   time.Date(2022, 5, 20, 0, 0, 0, 0, time.UTC),
+			to:     time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+			wantChunks: [][2]string{
+				{"202205", "202304"},
+				{"202305", "202401"},
+			},
+			wantSamples: 21,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := periodFormats[tt.period].layout
+			var mu sync.Mutex
+			var chunks [][2]string
+			srv := testGateway(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+				start, _ := body["start_time"].(string)
+				end, _ := body["end_time"].(string)
+				mu.Lock()
+				chunks = append(chunks, [2]string{start, end})
+				mu.Unlock()
+
+				// One row per period in the requested range, inclusive
+				format := periodFormats[tt.period]
+				from, _ := time.ParseInLocation(layout, start, time.UTC)
+				to, _ := time.ParseInLocation(layout, end, time.UTC)
+				var rows []map[string]interface{}
+				for ts := from; !ts.After(to); ts = format.add(ts, 1) {
+					rows = append(rows, map[string]interface{}{"time_stamp": ts.Format(layout), "p83022": "1.5", "ps_id": 1})
+				}
+				writeResult(w, "1", "success", map[string]interface{}{"1": rows})
+			})
+
+			series, err := testClient(srv, StaticToken("tok")).PlantStatistics(context.Background(), []int{1}, []int{83022}, tt.period, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chunks) != len(tt.wantChunks) {
+				t.Fatalf("chunks = %v, want %v", chunks, tt.wantChunks)
+			}
+			for i := range chunks {
+				if chunks[i] != tt.wantChunks[i] {
+					t.Errorf("chunk %d = %v, want %v", i, chunks[i], tt.wantChunks[i])
+				}
+			}
+
+			if len(series) != 1 || series[0].Key != "1" || series[0].PointID != 83022 {
+				t.Fatalf("series = %+v", series)
+			}
+			samples := series[0].Samples
+			if len(samples) != tt.wantSamples {
+				t.Errorf("%d samples, want %d", len(samples), tt.wantSamples)
+			}
+			for i := 1; i < len(samples); i++ {
+				if samples[i].Timestamp <= samples[i-1].Timestamp {
+					t.Fatalf("sample %d at %d repeats or precedes %d", i, samples[i].Timestamp, samples[i-1].Timestamp)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package isolarcloud
+
+import (
+	"context"
+	"iter"
+)
+
+// DefaultPageSize is the page size used when walking every page of a listing
+const DefaultPageSize = 50
+
+// walkPages yields every item of a paged listing, fetching pages on demand.
+// fetch returns one page of items and the gateway's total row count.
+func walkPages[T any](ctx context.Context, size int, fetch func(ctx context.Context, page, size int) ([]T, int, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		fetched := 0
+		for page := 1; ; page++ {
+			items, rowCount, err := fetch(ctx, page, size)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			// Stop on a short page too, in case the gateway omits rowCount
+			fetched += len(items)
+			if len(items) < size || (rowCount > 0 && fetched >= rowCount) {
+				return
+			}
+		}
+	}
+}
+
+// collect drains a paged iterator into a slice
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var all []T
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, item)
+	}
+	return all, nil
+}
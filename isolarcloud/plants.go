@@ -0,0 +1,96 @@
+package isolarcloud
+
+import (
+	"context"
+	"fmt"
+	"iter"
+)
+
+// PlantPage is one page of the plant listing
+type PlantPage struct {
+	PageList []Plant `json:"pageList"`
+	RowCount int     `json:"rowCount"`
+	Page     int     `json:"page"`
+	Size     int     `json:"size"`
+}
+
+// HasMore reports whether pages after this one exist
+func (p *PlantPage) HasMore() bool {
+	return p.Page*p.Size < p.RowCount
+}
+
+// DevicePage is one page of a plant's device listing
+type DevicePage struct {
+	PageList []PlantDevice `json:"pageList"`
+	RowCount int           `json:"rowCount"`
+	Page     int           `json:"page"`
+	Size     int           `json:"size"`
+}
+
+// HasMore reports whether pages after this one exist
+func (p *DevicePage) HasMore() bool {
+	return p.Page*p.Size < p.RowCount
+}
+
+// PlantListPage retrieves a single page of the plants the access token is authorised for.
+// Pages start at 1.
+func (c *Client) PlantListPage(ctx context.Context, page, size int) (*PlantPage, error) {
+	result := PlantPage{Page: page, Size: size}
+	err := c.Do(ctx, "/openapi/platform/queryPowerStationList", map[string]interface{}{
+		"page": page,
+		"size": size,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Plants iterates over every plant, fetching further pages as needed
+func (c *Client) Plants(ctx context.Context) iter.Seq2[Plant, error] {
+	return walkPages(ctx, DefaultPageSize, func(ctx context.Context, page, size int) ([]Plant, int, error) {
+		p, err := c.PlantListPage(ctx, page, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		return p.PageList, p.RowCount, nil
+	})
+}
+
+// PlantList retrieves every plant the access token is authorised for
+func (c *Client) PlantList(ctx context.Context) ([]Plant, error) {
+	return collect(c.Plants(ctx))
+}
+
+// DeviceListPage retrieves a single page of the devices belonging to a plant.
+// Pages start at 1.
+func (c *Client) DeviceListPage(ctx context.Context, psID, page, size int) (*DevicePage, error) {
+	result := DevicePage{Page: page, Size: size}
+	err := c.Do(ctx, "/openapi/platform/getDeviceListByPsId", map[string]interface{}{
+		"ps_id": fmt.Sprintf("%d", psID),
+		"page":  page,
+		"size":  size,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Devices iterates over every device of a plant, fetching further pages as needed
+func (c *Client) Devices(ctx context.Context, psID int) iter.Seq2[PlantDevice, error] {
+	return walkPages(ctx, DefaultPageSize, func(ctx context.Context, page, size int) ([]PlantDevice, int, error) {
+		p, err := c.DeviceListPage(ctx, psID, page, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		return p.PageList, p.RowCount, nil
+	})
+}
+
+// DeviceList retrieves every device belonging to a plant
+func (c *Client) DeviceList(ctx context.Context, psID int) ([]PlantDevice, error) {
+	return collect(c.Devices(ctx, psID))
+}
@@ -0,0 +1,164 @@
+package isolarcloud
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Device types of the devices whose points are in the catalogue
+const (
+	DeviceTypeEnergyStorage = 14 // hybrid inverter / energy storage system
+	DeviceTypeBattery       = 43
+)
+
+// ValueKind describes how a point's value behaves over time
+type ValueKind string
+
+const (
+	KindGauge   ValueKind = "gauge"   // an instantaneous measurement, e.g. power
+	KindCounter ValueKind = "counter" // a running total that only resets, e.g. daily yield
+)
+
+// PointDef describes a well known point
+type PointDef struct {
+	ID         int       `json:"id"`
+	Key        string    `json:"key"` // stable name, e.g. "battery_soc"
+	Name       string    `json:"name"`
+	Unit       string    `json:"unit"` // unit of the scaled value
+	DeviceType int       `json:"deviceType"`
+	Scale      float64   `json:"scale"` // multiplies the raw value
+	Kind       ValueKind `json:"kind"`
+}
+
+// catalogue holds common Sungrow points
+var catalogue = []PointDef{
+	{ID: 13003, Key: "pv_power", Name: "Total DC Power", Unit: "W", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindGauge},
+	{ID: 13011, Key: "active_power", Name: "Total Active Power", Unit: "W", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindGauge},
+	{ID: 13112, Key: "daily_pv_yield", Name: "Daily PV Yield", Unit: "Wh", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindCounter},
+	{ID: 13119, Key: "load_power", Name: "Total Load Active Power", Unit: "W", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindGauge},
+	{ID: 13121, Key: "grid_export_power", Name: "Total Export Active Power", Unit: "W", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindGauge},
+	{ID: 13126, Key: "battery_charge_power", Name: "Battery Charging Power", Unit: "W", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindGauge},
+	{ID: 13138, Key: "battery_voltage", Name: "Battery Voltage", Unit: "V", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindGauge},
+	{ID: 13139, Key: "battery_current", Name: "Battery Current", Unit: "A", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindGauge},
+	{ID: 13141, Key: "battery_level", Name: "Battery Level (SOC)", Unit: "%", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindGauge},
+	{ID: 13142, Key: "battery_health", Name: "Battery Health (SOH)", Unit: "%", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindGauge},
+	{ID: 13143, Key: "battery_temperature", Name: "Battery Temperature", Unit: "°C", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindGauge},
+	{ID: 13149, Key: "grid_import_power", Name: "Purchased Power", Unit: "W", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindGauge},
+	{ID: 13150, Key: "battery_discharge_power", Name: "Battery Discharging Power", Unit: "W", DeviceType: DeviceTypeEnergyStorage, Scale: 1, Kind: KindGauge},
+	// Reported as a 0..1 fraction
+	{ID: 58604, Key: "battery_soc", Name: "Battery SOC", Unit: "%", DeviceType: DeviceTypeBattery, Scale: 100, Kind: KindGauge},
+}
+
+// Catalogue returns the well known points
+func Catalogue() []PointDef {
+	return slices.Clone(catalogue)
+}
+
+// LookupPoint returns the catalogue entry of a point ID
+func LookupPoint(id int) (PointDef, bool) {
+	for _, def := range catalogue {
+		if def.ID == id {
+			return def, true
+		}
+	}
+	return PointDef{}, false
+}
+
+// PointByKey returns the catalogue entry with a key such as "battery_soc"
+func PointByKey(key string) (PointDef, bool) {
+	for _, def := range catalogue {
+		if def.Key == key {
+			return def, true
+		}
+	}
+	return PointDef{}, false
+}
+
+// Reading is a point value converted to a number in its display unit
+type Reading struct {
+	PsKey     string  `json:"psKey"`
+	PointID   int     `json:"pointId"`
+	Key       string  `json:"key,omitempty"` // catalogue key, empty for points not in the catalogue
+	Name      string  `json:"name"`
+	Value     float64 `json:"value"`
+	Unit      string  `json:"unit"`
+	Timestamp int64   `json:"timestamp"` // Unix milliseconds
+}
+
+// ParsePointKey extracts the point ID from a device_point key such as "p58604"
+func ParsePointKey(key string) (int, bool) {
+	digits, ok := strings.CutPrefix(key, "p")
+	if !ok || digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, false
+	}
+	id, err := strconv.Atoi(digits)
+	return id, err == nil
+}
+
+// ParseValue converts a device_point value, which the gateway sends as a string, to a number
+func ParseValue(raw interface{}) (float64, bool) {
+	switch v := raw.(type) {
+	case float64:
+		return v, true
+	case string:
+		value, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return value, err == nil
+	}
+	return 0, false
+}
+
+// deviceTimeLayout is the format of a device_point's device_time
+const deviceTimeLayout = "20060102150405"
+
+// ParseReadings converts the numeric points of a device_point to readings. Catalogue points
+// are scaled to their unit; others take their name and unit from dict, which may be nil.
+// Readings are timestamped with the device_time the gateway reports, or now.
+func ParseReadings(devicePoint map[string]interface{}, dict func(pointID int) (PointDictEntry, bool)) []Reading {
+	psKey, _ := devicePoint["ps_key"].(string)
+	timestamp := time.Now()
+	if deviceTime, ok := devicePoint["device_time"].(string); ok {
+		if t, err := time.ParseInLocation(deviceTimeLayout, deviceTime, time.Local); err == nil {
+			timestamp = t
+		}
+	}
+
+	var readings []Reading
+	for key, raw := range devicePoint {
+		pointID, ok := ParsePointKey(key)
+		if !ok {
+			continue
+		}
+		value, ok := ParseValue(raw)
+		if !ok {
+			continue
+		}
+
+		reading := Reading{PsKey: psKey, PointID: pointID, Name: fmt.Sprintf("Point %d", pointID), Value: value, Timestamp: timestamp.UnixMilli()}
+		if def, ok := LookupPoint(pointID); ok {
+			reading.Key = def.Key
+			reading.Name = def.Name
+			reading.Unit = def.Unit
+			reading.Value = value * def.Scale
+		} else if entry, ok := lookupDict(dict, pointID); ok {
+			if entry.PointName != "" {
+				reading.Name = entry.PointName
+			}
+			reading.Unit = entry.PointUnit
+		}
+		readings = append(readings, reading)
+	}
+
+	slices.SortFunc(readings, func(a, b Reading) int { return a.PointID - b.PointID })
+	return readings
+}
+
+// lookupDict looks a point up in an optional dictionary
+func lookupDict(dict func(pointID int) (PointDictEntry, bool), pointID int) (PointDictEntry, bool) {
+	if dict == nil {
+		return PointDictEntry{}, false
+	}
+	return dict(pointID)
+}
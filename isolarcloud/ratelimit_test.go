@@ -0,0 +1,99 @@
+package isolarcloud
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// timeWait returns how long Wait blocked
+func timeWait(t *testing.T, l *RateLimiter) time.Duration {
+	t.Helper()
+	start := time.Now()
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return time.Since(start)
+}
+
+func TestRateLimiterBurstAndRefill(t *testing.T) {
+	l := NewRateLimiter(10, 3)
+
+	// useBurst takes the whole burst, which shouldn't wait, then one more which waits
+	// for a token at 10 per second
+	useBurst := func() {
+		t.Helper()
+		for i := range 3 {
+			if waited := timeWait(t, l); waited > 20*time.Millisecond {
+				t.Errorf("request %d of the burst waited %s", i+1, waited)
+			}
+		}
+		if waited := timeWait(t, l); waited < 80*time.Millisecond || waited > time.Second {
+			t.Errorf("request after the burst waited %s, want about 100ms", waited)
+		}
+	}
+	useBurst()
+
+	// An hour idle refills the bucket, but only up to the burst
+	l.mu.Lock()
+	l.last = l.last.Add(-time.Hour)
+	l.mu.Unlock()
+	useBurst()
+}
+
+func TestRateLimiterQueuesWaiters(t *testing.T) {
+	l := NewRateLimiter(20, 1)
+	timeWait(t, l)
+
+	// Each waiter takes the next token, so three at once wait 50, 100 and 150ms
+	start := time.Now()
+	done := make(chan time.Duration, 3)
+	for range 3 {
+		go func() {
+			l.Wait(context.Background())
+			done <- time.Since(start)
+		}()
+	}
+	var last time.Duration
+	for range 3 {
+		last = max(last, <-done)
+	}
+	if last < 130*time.Millisecond || last > time.Second {
+		t.Errorf("last of three waiters done after %s, want about 150ms", last)
+	}
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	l := NewRateLimiter(0.1, 1)
+	timeWait(t, l)
+
+	// The next token is 10s away
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want the context's error", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("cancelled Wait returned after %s", waited)
+	}
+
+	// The token it reserved is given back, so the next caller doesn't wait twice as long
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+	if tokens < -0.1 || tokens > 0.1 {
+		t.Errorf("tokens after a cancelled Wait = %.2f, want none reserved", tokens)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	for _, l := range []*RateLimiter{nil, NewRateLimiter(0, 1)} {
+		for range 100 {
+			if waited := timeWait(t, l); waited > 20*time.Millisecond {
+				t.Fatalf("Wait without a rate waited %s", waited)
+			}
+		}
+	}
+}
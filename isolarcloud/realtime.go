@@ -0,0 +1,99 @@
+package isolarcloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// PointDictEntry describes a point, as returned when requesting data with is_get_point_dict
+type PointDictEntry struct {
+	PointID   int    `json:"point_id"`
+	PointName string `json:"point_name"`
+	PointUnit string `json:"point_unit"`
+}
+
+// UnmarshalJSON accepts point_id as either a number or a numeric string
+func (e *PointDictEntry) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		PointID   json.Number `json:"point_id"`
+		PointName string      `json:"point_name"`
+		PointUnit string      `json:"point_unit"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	id, err := raw.PointID.Int64()
+	if err != nil {
+		return fmt.Errorf("invalid point_id %q: %w", raw.PointID, err)
+	}
+
+	e.PointID = int(id)
+	e.PointName = raw.PointName
+	e.PointUnit = raw.PointUnit
+	return nil
+}
+
+// RealTimeData is the latest data of one or more devices along with the point dictionary
+type RealTimeData struct {
+	// DevicePoints holds one map per device keyed by "p<pointID>" plus metadata such as ps_key
+	DevicePoints []map[string]interface{}
+	PointDict    []PointDictEntry
+}
+
+// DeviceRealTime retrieves the latest values of pointIDs for devices of one device type,
+// together with names and units of the points. With WithRealTimeBatching, calls made
+// close together are sent as one request.
+func (c *Client) DeviceRealTime(ctx context.Context, deviceType int, psKeys []string, pointIDs []int) (*RealTimeData, error) {
+	if c.realTime != nil && len(psKeys) <= maxRealTimePsKeys && len(pointIDs) > 0 {
+		return c.realTime.fetch(ctx, deviceType, psKeys, pointIDs)
+	}
+	return c.deviceRealTime(ctx, deviceType, psKeys, pointIDs)
+}
+
+// deviceRealTime requests real-time data straight away
+func (c *Client) deviceRealTime(ctx context.Context, deviceType int, psKeys []string, pointIDs []int) (*RealTimeData, error) {
+	// Convert point IDs to strings
+	pointIDStrs := make([]string, len(pointIDs))
+	for i, id := range pointIDs {
+		pointIDStrs[i] = fmt.Sprintf("%d", id)
+	}
+
+	var result struct {
+		DevicePointList []struct {
+			DevicePoint map[string]interface{} `json:"device_point"`
+		} `json:"device_point_list"`
+		PointDict []PointDictEntry `json:"point_dict"`
+	}
+	err := c.Do(ctx, "/openapi/platform/getDeviceRealTimeData", map[string]interface{}{
+		"device_type":       deviceType,
+		"ps_key_list":       psKeys,
+		"point_id_list":     pointIDStrs,
+		"is_get_point_dict": "1",
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract device points
+	data := &RealTimeData{
+		DevicePoints: make([]map[string]interface{}, len(result.DevicePointList)),
+		PointDict:    result.PointDict,
+	}
+	for i, item := range result.DevicePointList {
+		data.DevicePoints[i] = item.DevicePoint
+	}
+
+	return data, nil
+}
+
+// DeviceRealTimeData retrieves the latest values of pointIDs for devices of one device type.
+// Each returned map is a device_point keyed by "p<pointID>" plus device metadata such as ps_key.
+func (c *Client) DeviceRealTimeData(ctx context.Context, deviceType int, psKeys []string, pointIDs []int) ([]map[string]interface{}, error) {
+	data, err := c.DeviceRealTime(ctx, deviceType, psKeys, pointIDs)
+	if err != nil {
+		return nil, err
+	}
+	return data.DevicePoints, nil
+}
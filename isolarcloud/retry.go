@@ -0,0 +1,105 @@
+package isolarcloud
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how query requests are retried after transport errors, 5xx
+// responses and rate limiting. Token requests are never retried.
+type RetryPolicy struct {
+	MaxAttempts int           // including the first, 1 disables retries
+	BaseDelay   time.Duration // wait before the second attempt, doubling after each failure
+	MaxDelay    time.Duration // longest wait between attempts; a longer Retry-After gives up
+}
+
+// DefaultRetryPolicy is used unless WithRetryPolicy is given
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+
+// WithRetryPolicy sets how failed query requests are retried
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = p
+	}
+}
+
+// postWithRetry sends a query request, retrying failures that may be transient
+func (c *Client) postWithRetry(ctx context.Context, path string, reqBody map[string]interface{}, token string) (*ApiResponse, error) {
+	for attempt := 1; ; attempt++ {
+		apiResp, err := c.post(ctx, path, reqBody, token)
+		if attempt >= c.retry.MaxAttempts || ctx.Err() != nil || !isRetryable(path, apiResp, err) {
+			return apiResp, err
+		}
+
+		var retryAfter time.Duration
+		if apiResp != nil {
+			retryAfter = apiResp.retryAfter
+		}
+		delay, ok := c.retry.delay(attempt, retryAfter)
+		if !ok {
+			return apiResp, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// delay returns the wait before the attempt after the given one, with full jitter on
+// the upper half so clients recovering from an outage don't retry in step. A Retry-After
+// longer than MaxDelay isn't waited out.
+func (p RetryPolicy) delay(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > 0 {
+		return retryAfter, retryAfter <= p.MaxDelay
+	}
+
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d < 2 {
+		return d, true
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2))), true
+}
+
+// isRetryable reports whether a failed attempt may succeed if repeated
+func isRetryable(path string, apiResp *ApiResponse, err error) bool {
+	if err != nil {
+		return !errors.Is(err, ErrGatewayUnavailable)
+	}
+	if isGatewayFailure(apiResp) || apiResp.httpStatus == http.StatusTooManyRequests {
+		return true
+	}
+	return apiResp.ResultCode != "1" && errors.Is(newAPIError(path, apiResp), ErrRateLimited)
+}
+
+// isGatewayFailure reports whether a response shows the gateway itself is failing
+func isGatewayFailure(apiResp *ApiResponse) bool {
+	return apiResp.httpStatus >= 500
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(header string) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
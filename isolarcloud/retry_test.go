@@ -0,0 +1,205 @@
+package isolarcloud
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// response writes one canned gateway response
+type response func(w http.ResponseWriter)
+
+// succeed is a successful response
+func succeed(w http.ResponseWriter) {
+	writeResult(w, "1", "success", nil)
+}
+
+// reject is a response with a failing result code
+func reject(code string) response {
+	return func(w http.ResponseWriter) {
+		writeResult(w, code, "rejected", nil)
+	}
+}
+
+// httpError is an HTML error page with an optional Retry-After header
+func httpError(status int, retryAfter string) response {
+	return func(w http.ResponseWriter) {
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte("<html>error</html>"))
+	}
+}
+
+// scriptedGateway answers requests with responses in turn, repeating the last, and
+// counts the requests
+func scriptedGateway(t *testing.T, responses ...response) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	srv := testGateway(t, func(w http.ResponseWriter, r *http.Request, body map[string]interface{}) {
+		n := int(requests.Add(1))
+		responses[min(n, len(responses))-1](w)
+	})
+	return srv, &requests
+}
+
+// retryingClient creates a client for a test gateway retrying with policy
+func retryingClient(srv *httptest.Server, policy RetryPolicy, options ...Option) *Client {
+	return NewClient("test-appkey", "test-secret", append([]Option{
+		WithBaseURL(srv.URL),
+		WithHTTPClient(srv.Client()),
+		WithAuth(StaticToken("tok")),
+		WithRetryPolicy(policy),
+	}, options...)...)
+}
+
+func TestRetryTransientFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure response
+	}{
+		{"server error", httpError(http.StatusBadGateway, "")},
+		{"too many requests", httpError(http.StatusTooManyRequests, "")},
+		{"rate limited result", reject("er_request_too_frequent")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := scriptedGateway(t, tt.failure, tt.failure, succeed)
+			client := retryingClient(srv, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second})
+			if err := client.Do(context.Background(), "/openapi/test", nil, nil); err != nil {
+				t.Errorf("Do = %v, want success on the third attempt", err)
+			}
+			if n := requests.Load(); n != 3 {
+				t.Errorf("gateway got %d requests, want 3", n)
+			}
+		})
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	srv, requests := scriptedGateway(t, httpError(http.StatusServiceUnavailable, ""))
+	client := retryingClient(srv, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second})
+
+	var apiErr *APIError
+	if err := client.Do(context.Background(), "/openapi/test", nil, nil); !errors.As(err, &apiErr) || apiErr.HTTPStatus != http.StatusServiceUnavailable {
+		t.Errorf("Do = %v, want the last HTTP 503", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("gateway got %d requests, want 3", n)
+	}
+}
+
+func TestNoRetryOfPermanentErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		failure  response
+		wantKind string
+	}{
+		{"no permission", reject("E00004"), "no_permission"},
+		{"invalid appkey", reject("er_invalid_appkey"), "invalid_appkey"},
+		{"unknown result code", reject("E99999"), "api_error"},
+		{"forbidden", httpError(http.StatusForbidden, ""), "no_permission"},
+		{"not found", httpError(http.StatusNotFound, ""), "api_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := scriptedGateway(t, tt.failure, succeed)
+			client := retryingClient(srv, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second})
+			err := client.Do(context.Background(), "/openapi/test", nil, nil)
+			if kind := ErrorKind(err); kind != tt.wantKind {
+				t.Errorf("Do = %v (%s), want %s", err, kind, tt.wantKind)
+			}
+			if n := requests.Load(); n != 1 {
+				t.Errorf("gateway got %d requests, want no retries", n)
+			}
+		})
+	}
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	// A computed backoff would retry after at most 2ms
+	policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Second}
+	srv, requests := scriptedGateway(t, httpError(http.StatusTooManyRequests, "1"), succeed)
+
+	start := time.Now()
+	if err := retryingClient(srv, policy).Do(context.Background(), "/openapi/test", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 900*time.Millisecond || waited > 3*time.Second {
+		t.Errorf("retried after %s, want the 1s Retry-After", waited)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("gateway got %d requests, want 2", n)
+	}
+
+	// A Retry-After longer than MaxDelay isn't waited out
+	srv, requests = scriptedGateway(t, httpError(http.StatusServiceUnavailable, "3600"), succeed)
+	start = time.Now()
+	if err := retryingClient(srv, policy).Do(context.Background(), "/openapi/test", nil, nil); err == nil {
+		t.Error("Do succeeded, want the HTTP 503 without waiting an hour")
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("gave up after %s", waited)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("gateway got %d requests, want 1", n)
+	}
+}
+
+func TestRetryCancelledDuringBackoff(t *testing.T) {
+	srv, requests := scriptedGateway(t, httpError(http.StatusServiceUnavailable, ""))
+	client := retryingClient(srv, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.Do(ctx, "/openapi/test", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do = %v, want the context's error", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("gateway got %d requests, want 1", n)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	for attempt, full := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		for range 100 {
+			d, ok := p.delay(attempt+1, 0)
+			if !ok || d < full/2 || d >= full {
+				t.Fatalf("delay after attempt %d = %s, %t, want within [%s, %s)", attempt+1, d, ok, full/2, full)
+			}
+		}
+	}
+
+	if d, ok := p.delay(1, 7*time.Second); d != 7*time.Second || !ok {
+		t.Errorf("delay with Retry-After 7s = %s, %t", d, ok)
+	}
+	if _, ok := p.delay(1, time.Minute); ok {
+		t.Error("delay with a Retry-After over MaxDelay should give up")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	inAMinute := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	tests := []struct {
+		header   string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"120", 2 * time.Minute, 2 * time.Minute},
+		{" 5 ", 5 * time.Second, 5 * time.Second},
+		{"-5", 0, 0},
+		{inAMinute, 58 * time.Second, time.Minute},
+		{"Mon, 01 Jan 2001 00:00:00 GMT", 0, 0},
+		{"soon", 0, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %s, want within [%s, %s]", tt.header, got, tt.min, tt.max)
+		}
+	}
+}
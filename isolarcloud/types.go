@@ -0,0 +1,62 @@
+package isolarcloud
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ApiResponse wraps API responses
+type ApiResponse struct {
+	ReqSerialNum string          `json:"req_serial_num,omitempty"`
+	ResultCode   string          `json:"result_code"`
+	ResultMsg    string          `json:"result_msg"`
+	ResultData   json.RawMessage `json:"result_data"`
+
+	httpStatus int
+	retryAfter time.Duration
+}
+
+// Plant represents a solar plant
+type Plant struct {
+	PsID                 int     `json:"ps_id"`
+	PsName               string  `json:"ps_name"`
+	Description          *string `json:"description"`
+	PsType               int     `json:"ps_type"`
+	OnlineStatus         int     `json:"online_status"`
+	ValidFlag            int     `json:"valid_flag"`
+	GridConnectionStatus int     `json:"grid_connection_status"`
+	InstallDate          string  `json:"install_date"`
+	PsLocation           string  `json:"ps_location"`
+	Latitude             float64 `json:"latitude"`
+	Longitude            float64 `json:"longitude"`
+	PsFaultStatus        int     `json:"ps_fault_status"`
+	ConnectType          int     `json:"connect_type"`
+	UpdateTime           string  `json:"update_time"`
+	PsCurrentTimeZone    string  `json:"ps_current_time_zone"`
+	GridConnectionTime   *string `json:"grid_connection_time"`
+	BuildStatus          int     `json:"build_status"`
+	TodayEnergy          string  `json:"today_energy,omitempty"`
+
+	// AccountID identifies the login the plant was listed with when an application
+	// combines several accounts. It is never set by the API.
+	AccountID string `json:"account_id,omitempty"`
+}
+
+// PlantDevice represents a device in a plant
+type PlantDevice struct {
+	UUID               int    `json:"uuid"`
+	PsKey              string `json:"ps_key"`
+	DeviceSN           string `json:"device_sn"`
+	DeviceName         string `json:"device_name"`
+	DeviceType         int    `json:"device_type"`
+	TypeName           string `json:"type_name"`
+	DeviceModelID      int    `json:"device_model_id"`
+	DeviceModelCode    string `json:"device_model_code"`
+	DevFaultStatus     int    `json:"dev_fault_status"`
+	DevStatus          string `json:"dev_status"`
+	ClaimState         int    `json:"claim_state"`
+	DeviceCode         int    `json:"device_code"`
+	ChnnlID            int    `json:"chnnl_id"`
+	CommunicationDevSN string `json:"communication_dev_sn"`
+	PsID               int    `json:"ps_id"`
+}
@@ -0,0 +1,78 @@
+//go:build darwin
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// securityNotFound is the exit status of the security tool when no item matches
+const securityNotFound = 44
+
+// keyringAvailable reports whether the macOS security tool can be run
+func keyringAvailable() bool {
+	_, err := exec.LookPath("/usr/bin/security")
+	return err == nil
+}
+
+// keyringUnlock does nothing, as the security tool prompts by itself when it needs the
+// keychain unlocked
+func keyringUnlock() error {
+	return nil
+}
+
+func keyringGet(service, key string) ([]byte, error) {
+	out, err := exec.Command("/usr/bin/security", "find-generic-password", "-s", service, "-a", key, "-w").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == securityNotFound {
+			return nil, errSecretNotFound
+		}
+		return nil, err
+	}
+
+	// Secrets are stored hex encoded so they survive the command line round trip
+	return hex.DecodeString(strings.TrimSpace(string(out)))
+}
+
+func keyringSet(service, key string, data []byte) error {
+	if !keychainName(service) || !keychainName(key) {
+		return fmt.Errorf("invalid keychain item %q/%q", service, key)
+	}
+
+	// The command goes to security's interactive mode on stdin rather than its
+	// arguments, where any local user could read the secret with ps
+	cmd := exec.Command("/usr/bin/security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n", service, key, hex.EncodeToString(data)))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("security: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	// Interactive mode exits successfully even when a command fails, reporting it in
+	// the output instead
+	if msg := strings.TrimSpace(string(out)); strings.Contains(msg, "security: ") {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// keychainName reports whether a service or account name can be passed to security's
+// interactive mode without quoting
+func keychainName(name string) bool {
+	return name != "" && !strings.ContainsFunc(name, func(r rune) bool {
+		return r <= ' ' || r == '"' || r == '\'' || r == '\\'
+	})
+}
+
+func keyringDelete(service, key string) error {
+	err := exec.Command("/usr/bin/security", "delete-generic-password", "-s", service, "-a", key).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityNotFound {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,272 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Secret Service (GNOME Keyring, KWallet) D-Bus names
+const (
+	secretServiceName       = "org.freedesktop.secrets"
+	secretServicePath       = "/org/freedesktop/secrets"
+	secretDefaultCollection = "/org/freedesktop/secrets/aliases/default"
+	secretServiceIface      = "org.freedesktop.Secret.Service"
+	secretCollectionIface   = "org.freedesktop.Secret.Collection"
+	secretItemIface         = "org.freedesktop.Secret.Item"
+	secretSessionIface      = "org.freedesktop.Secret.Session"
+	secretPromptIface       = "org.freedesktop.Secret.Prompt"
+)
+
+// secretPromptTimeout is how long an unlock prompt is left open before it's dismissed
+const secretPromptTimeout = 2 * time.Minute
+
+// secretServiceSecret mirrors the Secret Service (oayays) Secret struct
+type secretServiceSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// keyringAvailable reports whether a Secret Service provider is running on the session bus
+func keyringAvailable() bool {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return false
+	}
+
+	var names []string
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names); err != nil {
+		return false
+	}
+	for _, name := range names {
+		if name == secretServiceName {
+			return true
+		}
+	}
+
+	var activatable []string
+	if err := conn.BusObject().Call("org.freedesktop.DBus.ListActivatableNames", 0).Store(&activatable); err != nil {
+		return false
+	}
+	for _, name := range activatable {
+		if name == secretServiceName {
+			return true
+		}
+	}
+	return false
+}
+
+// keyringUnlock unlocks the default collection, asking the provider to prompt for the
+// password if it needs one. It returns errKeyringLocked if the collection stays locked.
+func keyringUnlock() error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+
+	locked, err := conn.Object(secretServiceName, secretDefaultCollection).GetProperty(secretCollectionIface + ".Locked")
+	if err != nil {
+		return err
+	}
+	if isLocked, _ := locked.Value().(bool); !isLocked {
+		return nil
+	}
+
+	return secretUnlock(conn, []dbus.ObjectPath{secretDefaultCollection})
+}
+
+// secretUnlock unlocks collections or items, going through the provider's prompt if it
+// shows one
+func secretUnlock(conn *dbus.Conn, objects []dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	err := conn.Object(secretServiceName, secretServicePath).
+		Call(secretServiceIface+".Unlock", 0, objects).
+		Store(&unlocked, &prompt)
+	if err != nil {
+		return fmt.Errorf("cannot unlock keyring: %w", err)
+	}
+	if prompt != "/" {
+		return secretPrompt(conn, prompt)
+	}
+	if len(unlocked) < len(objects) {
+		return errKeyringLocked
+	}
+	return nil
+}
+
+// secretPrompt shows a Secret Service prompt and waits for the user to complete it. A
+// dismissed or abandoned prompt leaves the keyring locked.
+func secretPrompt(conn *dbus.Conn, prompt dbus.ObjectPath) error {
+	match := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(prompt),
+		dbus.WithMatchInterface(secretPromptIface),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := conn.AddMatchSignal(match...); err != nil {
+		return err
+	}
+	defer conn.RemoveMatchSignal(match...)
+
+	signals := make(chan *dbus.Signal, 1)
+	conn.Signal(signals)
+	defer conn.RemoveSignal(signals)
+
+	if err := conn.Object(secretServiceName, prompt).Call(secretPromptIface+".Prompt", 0, "").Err; err != nil {
+		return fmt.Errorf("cannot show keyring prompt: %w", err)
+	}
+
+	timeout := time.After(secretPromptTimeout)
+	for {
+		select {
+		case signal := <-signals:
+			if signal.Path != prompt || signal.Name != secretPromptIface+".Completed" {
+				continue
+			}
+			if len(signal.Body) > 0 {
+				if dismissed, _ := signal.Body[0].(bool); dismissed {
+					return errKeyringLocked
+				}
+			}
+			return nil
+		case <-timeout:
+			conn.Object(secretServiceName, prompt).Call(secretPromptIface+".Dismiss", 0)
+			return errKeyringLocked
+		}
+	}
+}
+
+// secretSession opens a plain-transfer Secret Service session, to be closed with
+// closeSecretSession
+func secretSession() (*dbus.Conn, dbus.ObjectPath, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	err = conn.Object(secretServiceName, secretServicePath).
+		Call(secretServiceIface+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot open secret service session: %w", err)
+	}
+
+	return conn, session, nil
+}
+
+// closeSecretSession closes a session opened by secretSession. The provider would
+// otherwise keep it until the app disconnects from the bus.
+func closeSecretSession(conn *dbus.Conn, session dbus.ObjectPath) {
+	conn.Object(secretServiceName, session).Call(secretSessionIface+".Close", 0)
+}
+
+// secretItems finds the keyring items for a service and key, unlocking them if needed
+func secretItems(conn *dbus.Conn, service, key string) ([]dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	err := conn.Object(secretServiceName, secretServicePath).
+		Call(secretServiceIface+".SearchItems", 0, map[string]string{"service": service, "username": key}).
+		Store(&unlocked, &locked)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(unlocked) == 0 && len(locked) > 0 {
+		if err := secretUnlock(conn, locked); err != nil {
+			return nil, err
+		}
+		return locked, nil
+	}
+
+	return unlocked, nil
+}
+
+func keyringGet(service, key string) ([]byte, error) {
+	conn, session, err := secretSession()
+	if err != nil {
+		return nil, err
+	}
+	defer closeSecretSession(conn, session)
+
+	items, err := secretItems(conn, service, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errSecretNotFound
+	}
+
+	var secret secretServiceSecret
+	err = conn.Object(secretServiceName, items[0]).
+		Call(secretItemIface+".GetSecret", 0, session).
+		Store(&secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.Value, nil
+}
+
+func keyringSet(service, key string, data []byte) error {
+	conn, session, err := secretSession()
+	if err != nil {
+		return err
+	}
+	defer closeSecretSession(conn, session)
+
+	properties := map[string]dbus.Variant{
+		secretItemIface + ".Label":      dbus.MakeVariant(fmt.Sprintf("%s %s", service, key)),
+		secretItemIface + ".Attributes": dbus.MakeVariant(map[string]string{"service": service, "username": key}),
+	}
+	secret := secretServiceSecret{
+		Session:     session,
+		Value:       data,
+		ContentType: "application/json",
+	}
+
+	var item, prompt dbus.ObjectPath
+	err = conn.Object(secretServiceName, secretDefaultCollection).
+		Call(secretCollectionIface+".CreateItem", 0, properties, secret, true).
+		Store(&item, &prompt)
+	if err != nil {
+		return err
+	}
+	if prompt != "/" {
+		// The collection is locked, and the item is created once it's unlocked
+		return secretPrompt(conn, prompt)
+	}
+
+	return nil
+}
+
+func keyringDelete(service, key string) error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+
+	items, err := secretItems(conn, service, key)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		var prompt dbus.ObjectPath
+		if err := conn.Object(secretServiceName, item).Call(secretItemIface+".Delete", 0).Store(&prompt); err != nil {
+			return err
+		}
+		if prompt != "/" {
+			if err := secretPrompt(conn, prompt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
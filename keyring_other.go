@@ -0,0 +1,27 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import "fmt"
+
+// keyringAvailable reports whether an OS keyring is supported on this platform
+func keyringAvailable() bool {
+	return false
+}
+
+// keyringUnlock has nothing to unlock on this platform
+func keyringUnlock() error {
+	return nil
+}
+
+func keyringGet(service, key string) ([]byte, error) {
+	return nil, fmt.Errorf("OS keyring not supported on this platform")
+}
+
+func keyringSet(service, key string, data []byte) error {
+	return fmt.Errorf("OS keyring not supported on this platform")
+}
+
+func keyringDelete(service, key string) error {
+	return fmt.Errorf("OS keyring not supported on this platform")
+}
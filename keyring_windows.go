@@ -0,0 +1,114 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// winCredential mirrors the Win32 CREDENTIALW struct
+type winCredential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// keyringAvailable reports whether the Credential Manager API can be loaded
+func keyringAvailable() bool {
+	return procCredReadW.Find() == nil
+}
+
+func credentialTarget(service, key string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(service + ":" + key)
+}
+
+// keyringUnlock does nothing, as Credential Manager is unlocked with the user's session
+func keyringUnlock() error {
+	return nil
+}
+
+func keyringGet(service, key string) ([]byte, error) {
+	target, err := credentialTarget(service, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var cred *winCredential
+	ret, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ret == 0 {
+		if errors.Is(err, errorNotFound) {
+			return nil, errSecretNotFound
+		}
+		return nil, err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	return append([]byte(nil), blob...), nil
+}
+
+func keyringSet(service, key string, data []byte) error {
+	target, err := credentialTarget(service, key)
+	if err != nil {
+		return err
+	}
+	userName, err := syscall.UTF16PtrFromString(key)
+	if err != nil {
+		return err
+	}
+
+	cred := winCredential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(data)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+	if len(data) > 0 {
+		cred.CredentialBlob = &data[0]
+	}
+
+	ret, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if ret == 0 {
+		return err
+	}
+	return nil
+}
+
+func keyringDelete(service, key string) error {
+	target, err := credentialTarget(service, key)
+	if err != nil {
+		return err
+	}
+
+	ret, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if ret == 0 && !errors.Is(err, errorNotFound) {
+		return err
+	}
+	return nil
+}
@@ -26,6 +26,11 @@ var pngIconData []byte
 var app *App
 
 func main() {
+	// Subcommands run without a window or tray, e.g. from cron or as a systemd service
+	if code, ok := runCommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
 	// Create an instance of the app structure
 	app = NewApp()
 	app.BaseIcon = pngIconData
@@ -45,6 +50,8 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 15, G: 23, B: 42, A: 1},
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
+		ErrorFormatter:   formatError,
 		Bind: []interface{}{
 			app,
 		},
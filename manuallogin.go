@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// manualRedirectURL is the redirect used when the code is pasted back by hand. It matches
+// the first port tried by Authenticate, so it is already registered for most app keys.
+const manualRedirectURL = "http://localhost:8080/callback"
+
+// pendingLogin is a manual login waiting for its authorization code
+type pendingLogin struct {
+	account     *account
+	creds       Credentials
+	redirectURL string
+	state       string
+}
+
+// authorizationURL adds the redirect and state to the app's authorization URL
+func authorizationURL(authURL, redirectURL, state string) (string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid auth URL: %w", err)
+	}
+
+	query := u.Query()
+	query.Set("redirectUrl", redirectURL)
+	query.Set("state", state)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// BeginManualLogin starts a login of the active account for machines that can't receive
+// the OAuth callback, returning the URL to open in any browser. An empty redirectURL uses manualRedirectURL.
+func (a *App) BeginManualLogin(creds Credentials, redirectURL string) (string, error) {
+	if creds.AppKey == "" || creds.SecretKey == "" {
+		return "", fmt.Errorf("app key and secret key are required")
+	}
+	creds = creds.withGatewayDefaults()
+	if redirectURL == "" {
+		redirectURL = manualRedirectURL
+	}
+
+	state, err := newOAuthState()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := authorizationURL(creds.AuthURL, redirectURL, state)
+	if err != nil {
+		return "", err
+	}
+
+	a.authMu.Lock()
+	a.pendingLogin = &pendingLogin{account: a.loginAccount(), creds: creds, redirectURL: redirectURL, state: state}
+	a.authMu.Unlock()
+
+	return authURL, nil
+}
+
+// CompleteManualLogin finishes a login started with BeginManualLogin. input is the
+// address the browser was redirected to, or just the code from it.
+func (a *App) CompleteManualLogin(input string) (map[string]interface{}, error) {
+	code, state, err := extractAuthCode(input)
+	if err != nil {
+		return nil, err
+	}
+
+	a.authMu.Lock()
+	pending := a.pendingLogin
+	a.authMu.Unlock()
+
+	if pending == nil {
+		return nil, fmt.Errorf("no login in progress")
+	}
+	// A bare code has no state to check, but a pasted address must be from this login
+	if state != "" && subtle.ConstantTimeCompare([]byte(state), []byte(pending.state)) != 1 {
+		return nil, fmt.Errorf("the redirect address is from a different sign in, start again")
+	}
+
+	a.authMu.Lock()
+	a.pendingLogin = nil
+	a.authMu.Unlock()
+
+	pending.account.setCredentials(&pending.creds)
+	return a.exchangeCodeForTokens(pending.account, code, pending.creds, pending.redirectURL)
+}
+
+// extractAuthCode reads the authorization code, and state if present, from a pasted
+// redirect URL, query string or bare code
+func extractAuthCode(input string) (code, state string, err error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", "", fmt.Errorf("no authorization code given")
+	}
+
+	if !strings.Contains(input, "=") {
+		if strings.ContainsAny(input, " /?&") {
+			return "", "", fmt.Errorf("cannot find an authorization code in %q", input)
+		}
+		return input, "", nil
+	}
+
+	rawQuery := input
+	if u, err := url.Parse(input); err == nil && u.RawQuery != "" {
+		rawQuery = u.RawQuery
+	}
+	query, err := url.ParseQuery(strings.TrimPrefix(rawQuery, "?"))
+	if err != nil {
+		return "", "", fmt.Errorf("cannot parse redirect URL: %w", err)
+	}
+
+	if code := query.Get("code"); code != "" {
+		return code, query.Get("state"), nil
+	}
+	if reason := query.Get("error"); reason != "" {
+		return "", "", fmt.Errorf("authorization was refused: %s", reason)
+	}
+	return "", "", fmt.Errorf("the redirect URL has no code parameter")
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	r := NewRegistry()
+	soc := r.NewGauge("sungrow_battery_soc", "Battery state of charge", "ps_key")
+	soc.Set(87.5, `1_14_1_1`)
+	soc.Set(12, "a\"b")
+	r.NewCounter("sungrow_api_requests_total", "API requests", "path").Inc("/openapi/test")
+	r.NewGaugeFunc("sungrow_token_expiry", "Token expiry\nin seconds", func() float64 { return 42 })
+	r.NewHistogram("sungrow_api_seconds", "API latency", []float64{0.5, 1}).Observe(0.75)
+
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP sungrow_api_requests_total API requests
+# TYPE sungrow_api_requests_total counter
+sungrow_api_requests_total{path="/openapi/test"} 1
+# HELP sungrow_api_seconds API latency
+# TYPE sungrow_api_seconds histogram
+sungrow_api_seconds_bucket{le="0.5"} 0
+sungrow_api_seconds_bucket{le="1"} 1
+sungrow_api_seconds_bucket{le="+Inf"} 1
+sungrow_api_seconds_sum 0.75
+sungrow_api_seconds_count 1
+# HELP sungrow_battery_soc Battery state of charge
+# TYPE sungrow_battery_soc gauge
+sungrow_battery_soc{ps_key="1_14_1_1"} 87.5
+sungrow_battery_soc{ps_key="a\"b"} 12
+# HELP sungrow_token_expiry Token expiry\nin seconds
+# TYPE sungrow_token_expiry gauge
+sungrow_token_expiry 42
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteTo =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestWriteToWhileRegistering renders while families are registered, for the race
+// detector
+func TestWriteToWhileRegistering(t *testing.T) {
+	r := NewRegistry()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				r.WriteTo(io.Discard)
+			}
+		}
+	}()
+	for i := range 2000 {
+		r.NewGauge(fmt.Sprintf("gauge_%d", i), "help").Set(float64(i))
+	}
+	close(done)
+	wg.Wait()
+}
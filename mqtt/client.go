@@ -0,0 +1,330 @@
+// Package mqtt is a small MQTT 3.1.1 publisher with last will support and automatic reconnects.
+//
+// It implements only what the app's publisher needs: CONNECT with a last will, QoS 0 and
+// 1 PUBLISH, and keep-alive pings. Like the metrics package, it avoids a dependency for
+// a small slice of a protocol; paho.mqtt.golang would bring subscriptions, message
+// stores and a websocket transport that go unused, and its reconnect and on-connect
+// hooks would still need wrapping to republish availability and discovery.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrNotConnected is returned when publishing while the broker connection is down
+var ErrNotConnected = errors.New("mqtt: not connected")
+
+// Message is a message to publish
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte // 0 or 1
+	Retain  bool
+}
+
+// Options configures a Client
+type Options struct {
+	// Broker is the broker URL, e.g. tcp://localhost:1883 or tls://broker:8883
+	Broker    string
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration
+	// Will is published by the broker if the connection drops without a DISCONNECT
+	Will *Message
+	// OnConnect is called after every successful (re)connect
+	OnConnect func(c *Client)
+	// Dial overrides how the connection is opened, e.g. to use an in-memory broker stand-in
+	Dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	TLSConfig *tls.Config
+	// MaxReconnectDelay caps the exponential reconnect backoff
+	MaxReconnectDelay time.Duration
+}
+
+// Reconnect backoff starts at minReconnectDelay and doubles after each failed attempt
+// up to Options.MaxReconnectDelay, starting over once a connection has stayed up for
+// healthyConnection
+const (
+	minReconnectDelay = time.Second
+	healthyConnection = time.Minute
+)
+
+// Client publishes messages to a broker, reconnecting as needed
+type Client struct {
+	opts Options
+
+	mu       sync.Mutex
+	conn     net.Conn
+	nextID   uint16
+	inflight map[uint16]chan error
+	lastErr  error
+}
+
+// NewClient creates a client. Call Run to connect.
+func NewClient(opts Options) *Client {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = 60 * time.Second
+	}
+	if opts.MaxReconnectDelay <= 0 {
+		opts.MaxReconnectDelay = 2 * time.Minute
+	}
+	if opts.Dial == nil {
+		dialer := &net.Dialer{Timeout: 10 * time.Second}
+		opts.Dial = dialer.DialContext
+	}
+	return &Client{opts: opts, inflight: map[uint16]chan error{}}
+}
+
+// Run keeps the client connected until ctx is cancelled, then disconnects cleanly
+func (c *Client) Run(ctx context.Context) {
+	var delay time.Duration
+	for {
+		up, err := c.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		c.mu.Lock()
+		c.lastErr = err
+		c.mu.Unlock()
+
+		delay = c.reconnectDelay(delay, up)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// reconnectDelay returns how long to wait before reconnecting, given the previous delay
+// and how long the connection that just ended was up
+func (c *Client) reconnectDelay(prev, up time.Duration) time.Duration {
+	if prev == 0 || up >= healthyConnection {
+		return minReconnectDelay
+	}
+	return min(prev*2, c.opts.MaxReconnectDelay)
+}
+
+// Connected reports whether the broker connection is up
+func (c *Client) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
+}
+
+// LastError returns the error that ended the previous connection attempt
+func (c *Client) LastError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastErr
+}
+
+// Publish sends a message. QoS 1 messages wait for the broker's acknowledgement.
+func (c *Client) Publish(msg Message) error {
+	c.mu.Lock()
+	conn := c.conn
+	if conn == nil {
+		c.mu.Unlock()
+		return ErrNotConnected
+	}
+
+	var acked chan error
+	var id uint16
+	if msg.QoS > 0 {
+		msg.QoS = 1
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		id = c.nextID
+		acked = make(chan error, 1)
+		c.inflight[id] = acked
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := conn.Write(encodePublish(msg, id))
+	c.mu.Unlock()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	if acked == nil {
+		return nil
+	}
+
+	select {
+	case err := <-acked:
+		return err
+	case <-time.After(10 * time.Second):
+		c.mu.Lock()
+		delete(c.inflight, id)
+		c.mu.Unlock()
+		return fmt.Errorf("mqtt: no acknowledgement for %s", msg.Topic)
+	}
+}
+
+// session connects, serves the connection until it fails, and cleans up. It returns how
+// long the connection was up, or 0 if it never connected.
+func (c *Client) session(ctx context.Context) (time.Duration, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReader(conn)
+
+	if err := c.handshake(conn, reader); err != nil {
+		conn.Close()
+		return 0, err
+	}
+	connected := time.Now()
+
+	c.mu.Lock()
+	c.conn = conn
+	c.lastErr = nil
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		c.mu.Lock()
+		c.conn = nil
+		for id, ch := range c.inflight {
+			ch <- ErrNotConnected
+			delete(c.inflight, id)
+		}
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	go c.keepAlive(conn, done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.mu.Lock()
+			conn.Write(encodePacket(packetDisconnect, 0, nil))
+			c.mu.Unlock()
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	if c.opts.OnConnect != nil {
+		go c.opts.OnConnect(c)
+	}
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(c.opts.KeepAlive * 3 / 2))
+		p, err := readPacket(reader)
+		if err != nil {
+			return time.Since(connected), err
+		}
+
+		if p.kind == packetPuback && len(p.body) >= 2 {
+			id := binary.BigEndian.Uint16(p.body)
+			c.mu.Lock()
+			if ch, ok := c.inflight[id]; ok {
+				ch <- nil
+				delete(c.inflight, id)
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// dial opens the network connection described by the broker URL
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	u, err := url.Parse(c.opts.Broker)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt: invalid broker URL: %w", err)
+	}
+
+	secure := u.Scheme == "tls" || u.Scheme == "ssl" || u.Scheme == "mqtts"
+	addr := u.Host
+	if u.Port() == "" {
+		if secure {
+			addr = net.JoinHostPort(u.Hostname(), "8883")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "1883")
+		}
+	}
+
+	conn, err := c.opts.Dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if secure {
+		cfg := c.opts.TLSConfig
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		cfg = cfg.Clone()
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return tlsConn, nil
+	}
+
+	return conn, nil
+}
+
+// handshake sends CONNECT and waits for CONNACK
+func (c *Client) handshake(conn net.Conn, reader *bufio.Reader) error {
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := conn.Write(encodeConnect(&c.opts)); err != nil {
+		return err
+	}
+
+	p, err := readPacket(reader)
+	if err != nil {
+		return err
+	}
+	if p.kind != packetConnack || len(p.body) < 2 {
+		return fmt.Errorf("mqtt: expected CONNACK, got packet type %d", p.kind)
+	}
+	if p.body[1] != 0 {
+		return connackError(p.body[1])
+	}
+
+	return nil
+}
+
+// keepAlive pings the broker so idle connections aren't dropped
+func (c *Client) keepAlive(conn net.Conn, done chan struct{}) {
+	ticker := time.NewTicker(c.opts.KeepAlive / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			_, err := conn.Write(encodePacket(packetPingreq, 0, nil))
+			c.mu.Unlock()
+			if err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// connectPacket is a decoded CONNECT
+type connectPacket struct {
+	protocol  string
+	level     byte
+	flags     byte
+	keepAlive uint16
+	clientID  string
+	willTopic string
+	will      string
+	username  string
+	password  string
+}
+
+// readString reads a length-prefixed string from the front of b
+func readString(t *testing.T, b []byte) (string, []byte) {
+	t.Helper()
+	if len(b) < 2 || len(b) < 2+int(binary.BigEndian.Uint16(b)) {
+		t.Fatalf("truncated string in %x", b)
+	}
+	n := 2 + int(binary.BigEndian.Uint16(b))
+	return string(b[2:n]), b[n:]
+}
+
+// readConnect reads and decodes a CONNECT packet
+func readConnect(t *testing.T, r *bufio.Reader) connectPacket {
+	t.Helper()
+	p, err := readPacket(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.kind != packetConnect {
+		t.Fatalf("packet type = %d, want CONNECT", p.kind)
+	}
+
+	var c connectPacket
+	c.protocol, p.body = readString(t, p.body)
+	c.level, c.flags = p.body[0], p.body[1]
+	c.keepAlive = binary.BigEndian.Uint16(p.body[2:])
+	c.clientID, p.body = readString(t, p.body[4:])
+	if c.flags&0x04 != 0 {
+		c.willTopic, p.body = readString(t, p.body)
+		c.will, p.body = readString(t, p.body)
+	}
+	if c.flags&0x80 != 0 {
+		c.username, p.body = readString(t, p.body)
+	}
+	if c.flags&0x40 != 0 {
+		c.password, _ = readString(t, p.body)
+	}
+	return c
+}
+
+// readPublish reads a PUBLISH packet, returning its topic, packet ID and payload
+func readPublish(t *testing.T, r *bufio.Reader) (p *packet, topic string, id uint16, payload string) {
+	t.Helper()
+	p, err := readPacket(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.kind != packetPublish {
+		t.Fatalf("packet type = %d, want PUBLISH", p.kind)
+	}
+	topic, rest := readString(t, p.body)
+	if p.flags&0x06 != 0 {
+		id, rest = binary.BigEndian.Uint16(rest), rest[2:]
+	}
+	return p, topic, id, string(rest)
+}
+
+func TestClientAgainstBroker(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	client := NewClient(Options{
+		Broker:    "tcp://" + ln.Addr().String(),
+		ClientID:  "sungrow-test",
+		Username:  "user",
+		Password:  "pass",
+		KeepAlive: 30 * time.Second,
+		Will:      &Message{Topic: "sungrow/status", Payload: []byte("offline"), QoS: 1, Retain: true},
+		OnConnect: func(c *Client) {
+			c.Publish(Message{Topic: "sungrow/status", Payload: []byte("online"), QoS: 1, Retain: true})
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		client.Run(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	// serve accepts a connection, checks the CONNECT and the retained availability
+	// message published on connect, and acknowledges both
+	serve := func() (net.Conn, *bufio.Reader) {
+		t.Helper()
+		ln.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+		conn, err := ln.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+
+		got := readConnect(t, r)
+		want := connectPacket{
+			protocol: "MQTT", level: 4, flags: 0x80 | 0x40 | 0x20 | 0x08 | 0x04 | 0x02, keepAlive: 30,
+			clientID: "sungrow-test", willTopic: "sungrow/status", will: "offline", username: "user", password: "pass",
+		}
+		if got != want {
+			t.Errorf("CONNECT = %+v, want %+v", got, want)
+		}
+		conn.Write(encodePacket(packetConnack, 0, []byte{0, 0}))
+
+		p, topic, id, payload := readPublish(t, r)
+		if topic != "sungrow/status" || payload != "online" || p.flags != 0x02|0x01 || id == 0 {
+			t.Errorf("availability PUBLISH = flags %#x %s %q (id %d), want retained QoS 1 online", p.flags, topic, payload, id)
+		}
+		conn.Write(encodePacket(packetPuback, 0, binary.BigEndian.AppendUint16(nil, id)))
+		return conn, r
+	}
+
+	conn, r := serve()
+
+	// QoS 1 publishes wait for PUBACK, QoS 0 publishes don't
+	published := make(chan error, 1)
+	go func() {
+		published <- client.Publish(Message{Topic: "sungrow/1_14_1_1/p13141/state", Payload: []byte("87"), QoS: 1})
+	}()
+	p, topic, id, payload := readPublish(t, r)
+	if topic != "sungrow/1_14_1_1/p13141/state" || payload != "87" || p.flags != 0x02 {
+		t.Errorf("PUBLISH = flags %#x %s %q, want QoS 1 87", p.flags, topic, payload)
+	}
+	select {
+	case err := <-published:
+		t.Fatalf("Publish returned %v before PUBACK", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	conn.Write(encodePacket(packetPuback, 0, binary.BigEndian.AppendUint16(nil, id)))
+	if err := <-published; err != nil {
+		t.Fatalf("Publish = %v", err)
+	}
+
+	if err := client.Publish(Message{Topic: "sungrow/raw", Payload: []byte("1")}); err != nil {
+		t.Fatalf("QoS 0 Publish = %v", err)
+	}
+	if p, _, id, _ := readPublish(t, r); p.flags != 0 || id != 0 {
+		t.Errorf("QoS 0 PUBLISH flags = %#x, id = %d", p.flags, id)
+	}
+
+	// Dropping the connection reconnects, republishing availability
+	conn.Close()
+	conn, r = serve()
+	if !client.Connected() {
+		t.Error("Connected() = false after reconnecting")
+	}
+
+	// Cancelling disconnects cleanly, so the broker doesn't publish the will
+	cancel()
+	<-stopped
+	if p, err := readPacket(r); err != nil || p.kind != packetDisconnect {
+		t.Errorf("packet after cancel = %v, %v, want DISCONNECT", p, err)
+	}
+	conn.Close()
+}
+
+func TestClientRefusedConnection(t *testing.T) {
+	server, clientConn := net.Pipe()
+	client := NewClient(Options{
+		Broker: "tcp://broker",
+		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr != "broker:1883" {
+				t.Errorf("dialled %s, want broker:1883", addr)
+			}
+			return clientConn, nil
+		},
+	})
+
+	go func() {
+		r := bufio.NewReader(server)
+		if _, err := readPacket(r); err == nil {
+			server.Write(encodePacket(packetConnack, 0, []byte{0, 4}))
+		}
+		server.Close()
+	}()
+
+	_, err := client.session(context.Background())
+	if err == nil || err.Error() != "connection refused: bad username or password" {
+		t.Errorf("session error = %v", err)
+	}
+	if client.Connected() {
+		t.Error("Connected() = true after a refused connection")
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	c := NewClient(Options{MaxReconnectDelay: 10 * time.Second})
+	tests := []struct {
+		name     string
+		prev, up time.Duration
+		want     time.Duration
+	}{
+		{"first attempt", 0, 0, time.Second},
+		{"failed connect doubles", time.Second, 0, 2 * time.Second},
+		{"brief connection doubles", 4 * time.Second, 5 * time.Second, 8 * time.Second},
+		{"capped", 8 * time.Second, 0, 10 * time.Second},
+		{"healthy connection starts over", 10 * time.Second, healthyConnection, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.reconnectDelay(tt.prev, tt.up); got != tt.want {
+				t.Errorf("reconnectDelay(%v, %v) = %v, want %v", tt.prev, tt.up, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	packetConnect    byte = 1
+	packetConnack    byte = 2
+	packetPublish    byte = 3
+	packetPuback     byte = 4
+	packetPingreq    byte = 12
+	packetPingresp   byte = 13
+	packetDisconnect byte = 14
+)
+
+// packet is a decoded control packet
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+// appendString appends a length-prefixed UTF-8 string
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// appendBytes appends length-prefixed binary data
+func appendBytes(b []byte, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+// encodePacket frames a packet with its fixed header
+func encodePacket(kind, flags byte, body []byte) []byte {
+	out := []byte{kind<<4 | flags&0x0f}
+	n := len(body)
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		out = append(out, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...)
+}
+
+// encodeConnect builds a CONNECT packet
+func encodeConnect(opts *Options) []byte {
+	var flags byte = 0x02 // clean session
+	if opts.Will != nil {
+		flags |= 0x04 | (opts.Will.QoS&0x03)<<3
+		if opts.Will.Retain {
+			flags |= 0x20
+		}
+	}
+	if opts.Username != "" {
+		flags |= 0x80
+	}
+	if opts.Password != "" {
+		flags |= 0x40
+	}
+
+	body := appendString(nil, "MQTT")
+	body = append(body, 4, flags) // protocol level 3.1.1
+	body = binary.BigEndian.AppendUint16(body, uint16(opts.KeepAlive.Seconds()))
+	body = appendString(body, opts.ClientID)
+	if opts.Will != nil {
+		body = appendString(body, opts.Will.Topic)
+		body = appendBytes(body, opts.Will.Payload)
+	}
+	if opts.Username != "" {
+		body = appendString(body, opts.Username)
+	}
+	if opts.Password != "" {
+		body = appendString(body, opts.Password)
+	}
+
+	return encodePacket(packetConnect, 0, body)
+}
+
+// encodePublish builds a PUBLISH packet. packetID is only sent for QoS 1.
+func encodePublish(msg Message, packetID uint16) []byte {
+	var flags byte
+	if msg.Retain {
+		flags |= 0x01
+	}
+	flags |= (msg.QoS & 0x03) << 1
+
+	body := appendString(nil, msg.Topic)
+	if msg.QoS > 0 {
+		body = binary.BigEndian.AppendUint16(body, packetID)
+	}
+	body = append(body, msg.Payload...)
+
+	return encodePacket(packetPublish, flags, body)
+}
+
+// readPacket reads one control packet
+func readPacket(r *bufio.Reader) (*packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	length := 0
+	for multiplier := 1; ; multiplier *= 128 {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length += int(digit&0x7f) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		if multiplier > 128*128*128 {
+			return nil, errors.New("malformed remaining length")
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	return &packet{kind: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+// connackError describes a CONNACK refusal code
+func connackError(code byte) error {
+	reasons := map[byte]string{
+		1: "unacceptable protocol version",
+		2: "client identifier rejected",
+		3: "server unavailable",
+		4: "bad username or password",
+		5: "not authorized",
+	}
+	if reason, ok := reasons[code]; ok {
+		return fmt.Errorf("connection refused: %s", reason)
+	}
+	return fmt.Errorf("connection refused: code %d", code)
+}
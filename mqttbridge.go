@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+	"wails-sungrow-isolarcloud-app/mqtt"
+)
+
+// MQTTSettings controls publishing polled points to an MQTT broker
+type MQTTSettings struct {
+	Enabled         bool   `json:"enabled"`
+	Broker          string `json:"broker"` // e.g. tcp://homeassistant.local:1883
+	Username        string `json:"username"`
+	Password        string `json:"password,omitempty"`
+	ClientID        string `json:"clientId"`
+	TopicPrefix     string `json:"topicPrefix"`
+	Discovery       bool   `json:"discovery"`
+	DiscoveryPrefix string `json:"discoveryPrefix"`
+}
+
+// withDefaults fills in unset values
+func (s MQTTSettings) withDefaults() MQTTSettings {
+	def := defaultSettings().MQTT
+	if s.TopicPrefix == "" {
+		s.TopicPrefix = def.TopicPrefix
+	}
+	if s.DiscoveryPrefix == "" {
+		s.DiscoveryPrefix = def.DiscoveryPrefix
+	}
+	if s.ClientID == "" {
+		hostname, _ := os.Hostname()
+		s.ClientID = "sungrow-monitor-" + hostname
+	}
+	s.TopicPrefix = strings.Trim(s.TopicPrefix, "/")
+	s.DiscoveryPrefix = strings.Trim(s.DiscoveryPrefix, "/")
+	return s
+}
+
+// mqttBridge publishes poll results and Home Assistant discovery configs
+type mqttBridge struct {
+	client   *mqtt.Client
+	settings MQTTSettings
+	cancel   context.CancelFunc
+
+	mu        sync.Mutex
+	announced map[string]bool // discovery configs sent on the current connection
+	log       *log.Logger
+}
+
+// availabilityTopic is where "online"/"offline" is published, the latter as last will
+func (b *mqttBridge) availabilityTopic() string {
+	return b.settings.TopicPrefix + "/status"
+}
+
+// stateTopic is where a point's value is published
+func (b *mqttBridge) stateTopic(psKey string, pointID int) string {
+	return fmt.Sprintf("%s/%s/p%d/state", b.settings.TopicPrefix, psKey, pointID)
+}
+
+// onConnect marks the app online and forces discovery configs to be resent
+func (b *mqttBridge) onConnect(c *mqtt.Client) {
+	b.mu.Lock()
+	b.announced = map[string]bool{}
+	b.mu.Unlock()
+
+	err := c.Publish(mqtt.Message{Topic: b.availabilityTopic(), Payload: []byte("online"), QoS: 1, Retain: true})
+	if err != nil {
+		b.log.Printf("mqttBridge: cannot publish availability: %v\n", err)
+		return
+	}
+	b.log.Printf("mqttBridge: connected to %s\n", b.settings.Broker)
+}
+
+// publish sends every numeric point of a poll result, announcing new points to Home Assistant first
+func (b *mqttBridge) publish(t PollTarget, r PollResult) {
+	if r.Error != "" || !b.client.Connected() {
+		return
+	}
+
+	for _, reading := range r.Readings {
+		if b.settings.Discovery {
+			if err := b.announce(t, reading); err != nil {
+				b.log.Printf("mqttBridge: discovery for %s p%d failed: %v\n", t.PsKey, reading.PointID, err)
+			}
+		}
+
+		err := b.client.Publish(mqtt.Message{
+			Topic:   b.stateTopic(t.PsKey, reading.PointID),
+			Payload: []byte(strconv.FormatFloat(reading.Value, 'f', -1, 64)),
+		})
+		if err != nil {
+			b.log.Printf("mqttBridge: publish %s p%d failed: %v\n", t.PsKey, reading.PointID, err)
+			return
+		}
+	}
+}
+
+// haDiscoveryConfig is a Home Assistant MQTT sensor discovery payload
+type haDiscoveryConfig struct {
+	Name              string   `json:"name"`
+	UniqueID          string   `json:"unique_id"`
+	StateTopic        string   `json:"state_topic"`
+	AvailabilityTopic string   `json:"availability_topic"`
+	UnitOfMeasurement string   `json:"unit_of_measurement,omitempty"`
+	DeviceClass       string   `json:"device_class,omitempty"`
+	StateClass        string   `json:"state_class,omitempty"`
+	Device            haDevice `json:"device"`
+}
+
+// haDevice groups a device's sensors in Home Assistant
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+}
+
+// invalidNodeChars are characters Home Assistant doesn't allow in discovery node IDs
+var invalidNodeChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// announce publishes the discovery config for a point once per connection
+func (b *mqttBridge) announce(t PollTarget, reading isolarcloud.Reading) error {
+	nodeID := "sungrow_" + invalidNodeChars.ReplaceAllString(t.PsKey, "_")
+	objectID := fmt.Sprintf("p%d", reading.PointID)
+
+	b.mu.Lock()
+	done := b.announced[nodeID+"/"+objectID]
+	b.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	deviceClass, stateClass, unit := haSensorClass(reading)
+
+	deviceName := t.DeviceName
+	if deviceName == "" {
+		deviceName = t.PsKey
+	}
+
+	payload, err := json.Marshal(haDiscoveryConfig{
+		Name:              reading.Name,
+		UniqueID:          nodeID + "_" + objectID,
+		StateTopic:        b.stateTopic(t.PsKey, reading.PointID),
+		AvailabilityTopic: b.availabilityTopic(),
+		UnitOfMeasurement: unit,
+		DeviceClass:       deviceClass,
+		StateClass:        stateClass,
+		Device: haDevice{
+			Identifiers:  []string{nodeID},
+			Name:         deviceName,
+			Manufacturer: "Sungrow",
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = b.client.Publish(mqtt.Message{
+		Topic:   fmt.Sprintf("%s/sensor/%s/%s/config", b.settings.DiscoveryPrefix, nodeID, objectID),
+		Payload: payload,
+		QoS:     1,
+		Retain:  true,
+	})
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.announced[nodeID+"/"+objectID] = true
+	b.mu.Unlock()
+	return nil
+}
+
+// haSensorClass derives the Home Assistant device and state class from a reading's unit
+// and, for catalogue points, how its value behaves
+func haSensorClass(reading isolarcloud.Reading) (deviceClass, stateClass, normalizedUnit string) {
+	if reading.Key == batterySocKey || reading.Key == batteryLevelKey {
+		return "battery", "measurement", "%"
+	}
+
+	deviceClass, stateClass, normalizedUnit = "", "measurement", reading.Unit
+	switch strings.TrimSpace(reading.Unit) {
+	case "W", "kW":
+		deviceClass = "power"
+	case "Wh", "kWh", "MWh":
+		deviceClass, stateClass = "energy", "total_increasing"
+	case "V":
+		deviceClass = "voltage"
+	case "A":
+		deviceClass = "current"
+	case "Hz":
+		deviceClass = "frequency"
+	case "℃", "°C":
+		deviceClass, normalizedUnit = "temperature", "°C"
+	}
+
+	if def, ok := isolarcloud.LookupPoint(reading.PointID); ok && def.Kind == isolarcloud.KindCounter {
+		stateClass = "total_increasing"
+	}
+	return deviceClass, stateClass, normalizedUnit
+}
+
+// applyMQTTSettings starts, restarts or stops the MQTT bridge to match the settings. A
+// bridge already running with them is left alone, as reconnecting would flap its
+// availability in Home Assistant.
+func (a *App) applyMQTTSettings(ctx context.Context, settings MQTTSettings) {
+	a.mqttMu.Lock()
+	defer a.mqttMu.Unlock()
+
+	settings = settings.withDefaults()
+	if a.mqtt != nil && settings.Enabled && settings.Broker != "" && settings == a.mqtt.settings {
+		return
+	}
+
+	if a.mqtt != nil {
+		// Announce going offline ourselves, a clean DISCONNECT suppresses the last will
+		a.mqtt.client.Publish(mqtt.Message{Topic: a.mqtt.availabilityTopic(), Payload: []byte("offline"), QoS: 1, Retain: true})
+		a.mqtt.cancel()
+		a.mqtt = nil
+	}
+
+	if !settings.Enabled || settings.Broker == "" {
+		return
+	}
+
+	bridge := &mqttBridge{
+		settings:  settings,
+		announced: map[string]bool{},
+		log:       a.log,
+	}
+	bridge.client = mqtt.NewClient(mqtt.Options{
+		Broker:    settings.Broker,
+		ClientID:  settings.ClientID,
+		Username:  settings.Username,
+		Password:  settings.Password,
+		KeepAlive: 60 * time.Second,
+		Will: &mqtt.Message{
+			Topic:   bridge.availabilityTopic(),
+			Payload: []byte("offline"),
+			QoS:     1,
+			Retain:  true,
+		},
+		OnConnect: bridge.onConnect,
+	})
+
+	bridgeCtx, cancel := context.WithCancel(ctx)
+	bridge.cancel = cancel
+	a.mqtt = bridge
+	go bridge.client.Run(bridgeCtx)
+}
+
+// publishMQTT forwards a poll result to the MQTT bridge if it is running
+func (a *App) publishMQTT(t PollTarget, r PollResult) {
+	a.mqttMu.Lock()
+	bridge := a.mqtt
+	a.mqttMu.Unlock()
+
+	if bridge != nil {
+		bridge.publish(t, r)
+	}
+}
+
+// GetMQTTStatus reports whether the MQTT bridge is connected and the last connection error
+func (a *App) GetMQTTStatus() map[string]interface{} {
+	a.mqttMu.Lock()
+	bridge := a.mqtt
+	a.mqttMu.Unlock()
+
+	status := map[string]interface{}{
+		"enabled":   bridge != nil,
+		"connected": false,
+	}
+	if bridge != nil {
+		status["connected"] = bridge.client.Connected()
+		if err := bridge.client.LastError(); err != nil {
+			status["error"] = err.Error()
+		}
+	}
+	return status
+}
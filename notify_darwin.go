@@ -0,0 +1,18 @@
+//go:build darwin
+
+package main
+
+import (
+	"os/exec"
+)
+
+// notificationScript reads the title and body from its arguments so neither has to be
+// escaped into AppleScript source
+const notificationScript = `on run argv
+	display notification (item 3 of argv) with title (item 1 of argv) subtitle (item 2 of argv)
+end run`
+
+// sendNotification shows a Notification Center notification
+func sendNotification(title, body string) error {
+	return exec.Command("/usr/bin/osascript", "-e", notificationScript, notificationAppName, title, body).Run()
+}
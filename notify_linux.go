@@ -0,0 +1,28 @@
+//go:build linux
+
+package main
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// Desktop Notifications D-Bus names
+const (
+	notificationsName  = "org.freedesktop.Notifications"
+	notificationsPath  = "/org/freedesktop/Notifications"
+	notificationsIface = "org.freedesktop.Notifications"
+)
+
+// sendNotification shows a desktop notification through the notification daemon
+func sendNotification(title, body string) error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+
+	obj := conn.Object(notificationsName, notificationsPath)
+	// app_name, replaces_id, app_icon, summary, body, actions, hints, expire_timeout
+	return obj.Call(notificationsIface+".Notify", 0,
+		notificationAppName, uint32(0), "", title, body, []string{}, map[string]dbus.Variant{}, int32(-1),
+	).Err
+}
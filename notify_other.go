@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import "fmt"
+
+func sendNotification(title, body string) error {
+	return fmt.Errorf("desktop notifications not supported on this platform")
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+	"wails-sungrow-isolarcloud-app/trayicon"
+)
+
+// batterySocKey is the catalogue point that drives the tray icon
+const batterySocKey = "battery_soc"
+
+// Energy storage device points standing in for a battery device in the tray icon: its
+// battery level, and the charging and discharging power shown by the flow style
+const (
+	batteryLevelKey          = "battery_level"
+	batteryChargePowerKey    = "battery_charge_power"
+	batteryDischargePowerKey = "battery_discharge_power"
+)
+
+// PollerSettings controls how often watched devices are refreshed
+type PollerSettings struct {
+	IntervalSeconds   int          `json:"intervalSeconds"`
+	JitterSeconds     int          `json:"jitterSeconds"`
+	MaxBackoffSeconds int          `json:"maxBackoffSeconds"`
+	Targets           []PollTarget `json:"targets"`
+}
+
+// withDefaults fills in unset or invalid values
+func (s PollerSettings) withDefaults() PollerSettings {
+	def := defaultSettings().Poller
+	if s.IntervalSeconds < 30 {
+		s.IntervalSeconds = def.IntervalSeconds
+	}
+	if s.JitterSeconds < 0 {
+		s.JitterSeconds = 0
+	}
+	if s.MaxBackoffSeconds < s.IntervalSeconds {
+		s.MaxBackoffSeconds = max(def.MaxBackoffSeconds, s.IntervalSeconds)
+	}
+	return s
+}
+
+// PollTarget is a device whose points are refreshed on a schedule
+type PollTarget struct {
+	PsID            int    `json:"psId"`
+	PsKey           string `json:"psKey"`
+	AccountID       string `json:"accountId,omitempty"` // account the device belongs to, found from PsID if unset
+	DeviceType      int    `json:"deviceType"`
+	DeviceName      string `json:"deviceName"`
+	PointIDs        []int  `json:"pointIds"`
+	Tray            bool   `json:"tray"`                      // drives the tray icon and tooltip
+	IntervalSeconds int    `json:"intervalSeconds,omitempty"` // overrides the poller interval
+}
+
+// equal reports whether two targets poll the same points on the same schedule
+func (t PollTarget) equal(other PollTarget) bool {
+	return t.PsID == other.PsID &&
+		t.PsKey == other.PsKey &&
+		t.AccountID == other.AccountID &&
+		t.DeviceType == other.DeviceType &&
+		t.DeviceName == other.DeviceName &&
+		slices.Equal(t.PointIDs, other.PointIDs) &&
+		t.Tray == other.Tray &&
+		t.IntervalSeconds == other.IntervalSeconds
+}
+
+// PollResult is emitted to the frontend after every poll of a target
+type PollResult struct {
+	PsID       int                    `json:"psId"`
+	PsKey      string                 `json:"psKey"`
+	DeviceType int                    `json:"deviceType"`
+	Points     map[string]interface{} `json:"points,omitempty"`
+	Readings   []isolarcloud.Reading  `json:"readings,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Failures   int                    `json:"failures"`
+	Timestamp  int64                  `json:"timestamp"`
+	NextPoll   int64                  `json:"nextPoll"`
+}
+
+// Poller refreshes watched devices in the background, independent of the webview
+type Poller struct {
+	mu       sync.Mutex
+	ctx      context.Context
+	settings PollerSettings
+	targets  map[string]PollTarget
+	workers  map[string]context.CancelFunc
+	fetch    func(ctx context.Context, t PollTarget) (map[string]interface{}, error)
+	onResult func(t PollTarget, r PollResult)
+	log      *log.Logger
+}
+
+// newPoller creates a poller. fetch loads a target's points and onResult is called after each poll.
+// Failed polls are logged to logger.
+func newPoller(fetch func(ctx context.Context, t PollTarget) (map[string]interface{}, error), onResult func(t PollTarget, r PollResult), logger *log.Logger) *Poller {
+	return &Poller{
+		settings: defaultSettings().Poller,
+		targets:  map[string]PollTarget{},
+		workers:  map[string]context.CancelFunc{},
+		fetch:    fetch,
+		onResult: onResult,
+		log:      logger,
+	}
+}
+
+// Start begins polling the targets in settings until ctx is cancelled
+func (p *Poller) Start(ctx context.Context, settings PollerSettings) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.ctx = ctx
+	p.settings = settings.withDefaults()
+	for _, t := range settings.Targets {
+		p.targets[t.PsKey] = t
+	}
+	for key := range p.targets {
+		p.startLocked(key)
+	}
+}
+
+// Configure applies new intervals, restarting the workers only if the timings changed.
+// Targets are synced with Watch and Unwatch.
+func (p *Poller) Configure(settings PollerSettings) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	settings = settings.withDefaults()
+	unchanged := settings.IntervalSeconds == p.settings.IntervalSeconds &&
+		settings.JitterSeconds == p.settings.JitterSeconds &&
+		settings.MaxBackoffSeconds == p.settings.MaxBackoffSeconds
+	p.settings = settings
+	if unchanged {
+		return
+	}
+	for key := range p.targets {
+		p.startLocked(key)
+	}
+}
+
+// Watch adds or replaces a target and polls it immediately. Watching a target that is
+// already watched unchanged leaves its worker running.
+func (p *Poller) Watch(t PollTarget) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if current, ok := p.targets[t.PsKey]; ok && current.equal(t) {
+		return
+	}
+
+	// Only one target drives the tray
+	if t.Tray {
+		for key, other := range p.targets {
+			if other.Tray && key != t.PsKey {
+				other.Tray = false
+				p.targets[key] = other
+			}
+		}
+	}
+
+	p.targets[t.PsKey] = t
+	p.startLocked(t.PsKey)
+}
+
+// Unwatch stops polling a target
+func (p *Poller) Unwatch(psKey string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if cancel, ok := p.workers[psKey]; ok {
+		cancel()
+		delete(p.workers, psKey)
+	}
+	delete(p.targets, psKey)
+}
+
+// Targets returns the watched targets
+func (p *Poller) Targets() []PollTarget {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	targets := make([]PollTarget, 0, len(p.targets))
+	for _, t := range p.targets {
+		targets = append(targets, t)
+	}
+	slices.SortFunc(targets, func(x, y PollTarget) int {
+		if x.PsKey < y.PsKey {
+			return -1
+		}
+		if x.PsKey > y.PsKey {
+			return 1
+		}
+		return 0
+	})
+	return targets
+}
+
+// startLocked (re)starts the worker for a target. The caller must hold mu.
+func (p *Poller) startLocked(psKey string) {
+	if cancel, ok := p.workers[psKey]; ok {
+		cancel()
+		delete(p.workers, psKey)
+	}
+
+	// Not started yet, Start will launch the workers
+	if p.ctx == nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(p.ctx)
+	p.workers[psKey] = cancel
+	go p.run(ctx, psKey, p.settings)
+}
+
+// run polls a single target until its context is cancelled
+func (p *Poller) run(ctx context.Context, psKey string, settings PollerSettings) {
+	failures := 0
+	for {
+		p.mu.Lock()
+		t, ok := p.targets[psKey]
+		p.mu.Unlock()
+		if !ok {
+			return
+		}
+
+		points, err := p.fetch(ctx, t)
+		if ctx.Err() != nil {
+			return
+		}
+
+		interval := time.Duration(settings.IntervalSeconds) * time.Second
+		if t.IntervalSeconds >= 30 {
+			interval = time.Duration(t.IntervalSeconds) * time.Second
+		}
+
+		result := PollResult{
+			PsID:       t.PsID,
+			PsKey:      t.PsKey,
+			DeviceType: t.DeviceType,
+			Timestamp:  time.Now().UnixMilli(),
+		}
+
+		var delay time.Duration
+		if err != nil {
+			failures++
+			delay = backoffDelay(interval, failures, time.Duration(settings.MaxBackoffSeconds)*time.Second)
+			result.Error = err.Error()
+			p.log.Printf("Poller: %s failed (%d in a row), retrying in %s: %v\n", psKey, failures, delay, err)
+		} else {
+			failures = 0
+			delay = interval
+			result.Points = points
+		}
+		delay = addJitter(delay, time.Duration(settings.JitterSeconds)*time.Second)
+
+		result.Failures = failures
+		result.NextPoll = time.Now().Add(delay).UnixMilli()
+		p.onResult(t, result)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoffDelay doubles the wait after each consecutive failure, starting from a tenth
+// of the interval so a transient error doesn't leave the tray stale for a full cycle
+func backoffDelay(interval time.Duration, failures int, maxDelay time.Duration) time.Duration {
+	base := max(interval/10, 15*time.Second)
+	delay := time.Duration(float64(base) * math.Pow(2, float64(failures-1)))
+	if delay <= 0 || delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// addJitter spreads polls by up to ±jitter so devices don't all hit the gateway together
+func addJitter(d, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	d += time.Duration(rand.Int63n(int64(2*jitter))) - jitter
+	return max(d, time.Second)
+}
+
+// batterySoc extracts the state of charge percentage from polled readings, from a
+// battery device or, failing that, the battery level of an energy storage device
+func batterySoc(readings []isolarcloud.Reading) (float64, bool) {
+	for _, key := range []string{batterySocKey, batteryLevelKey} {
+		for _, r := range readings {
+			if r.Key == key {
+				return math.Round(r.Value*10) / 10, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// batteryFlow tells whether the battery is charging or discharging from the charging and
+// discharging power points, when they're polled. Below flowThreshold watts it is idle.
+func batteryFlow(readings []isolarcloud.Reading) trayicon.Flow {
+	const flowThreshold = 10
+
+	var charge, discharge float64
+	for _, r := range readings {
+		switch r.Key {
+		case batteryChargePowerKey:
+			charge = r.Value
+		case batteryDischargePowerKey:
+			discharge = r.Value
+		}
+	}
+	switch {
+	case charge >= flowThreshold && charge >= discharge:
+		return trayicon.FlowCharging
+	case discharge >= flowThreshold:
+		return trayicon.FlowDischarging
+	}
+	return trayicon.FlowIdle
+}
+
+// pollDevice fetches the points of a watched device
+func (a *App) pollDevice(ctx context.Context, t PollTarget) (map[string]interface{}, error) {
+	client, err := a.clientForTarget(t)
+	if err != nil {
+		return nil, err
+	}
+
+	devicePoints, err := a.deviceRealTime(ctx, client, t.DeviceType, []string{t.PsKey}, t.PointIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(devicePoints) == 0 {
+		return nil, fmt.Errorf("no data returned for %s", t.PsKey)
+	}
+
+	return devicePoints[0], nil
+}
+
+// clientForTarget returns the API client of the account a watched device belongs to
+func (a *App) clientForTarget(t PollTarget) (*isolarcloud.Client, error) {
+	if t.AccountID != "" {
+		if acct := a.findAccount(t.AccountID); acct != nil {
+			return acct.apiClient()
+		}
+	}
+	if t.PsID != 0 {
+		return a.clientForPlant(t.PsID)
+	}
+	return a.clientForPsKey(t.PsKey)
+}
+
+// handlePollResult pushes a poll result to the frontend and refreshes the tray
+func (a *App) handlePollResult(t PollTarget, r PollResult) {
+	if r.Error == "" {
+		r.Readings = isolarcloud.ParseReadings(r.Points, a.pointDef)
+	}
+
+	a.emit("poller:result", r)
+	a.recordHistory(r)
+	a.metrics.observePoll(r)
+	a.publishMQTT(t, r)
+	a.evaluateAlerts(t, r)
+
+	if a.headless {
+		a.logPollResult(t, r)
+		return
+	}
+	if !t.Tray || r.Error != "" {
+		return
+	}
+
+	if soc, ok := batterySoc(r.Readings); ok {
+		state := trayicon.State{Percentage: int(math.Round(soc)), Flow: batteryFlow(r.Readings)}
+		title := fmt.Sprintf("Battery: %d%%", state.Percentage)
+		if state.Flow != trayicon.FlowIdle {
+			title += ", " + string(state.Flow)
+		}
+		a.updateTray(state, title)
+	}
+}
+
+// WatchDevice starts polling a device in the background and remembers it across restarts
+func (a *App) WatchDevice(target PollTarget) error {
+	if target.PsKey == "" {
+		return fmt.Errorf("ps_key is required")
+	}
+	if len(target.PointIDs) == 0 {
+		return fmt.Errorf("at least one point ID is required")
+	}
+	if target.AccountID == "" {
+		a.accountsMu.Lock()
+		target.AccountID = a.plantAccounts[target.PsID]
+		a.accountsMu.Unlock()
+	}
+
+	a.poller.Watch(target)
+	return a.saveWatchedDevices()
+}
+
+// UnwatchDevice stops polling a device
+func (a *App) UnwatchDevice(psKey string) error {
+	a.poller.Unwatch(psKey)
+	return a.saveWatchedDevices()
+}
+
+// GetWatchedDevices returns the devices being polled
+func (a *App) GetWatchedDevices() []PollTarget {
+	return a.poller.Targets()
+}
+
+// saveWatchedDevices persists the poller's targets in the settings file
+func (a *App) saveWatchedDevices() error {
+	a.settingsMu.Lock()
+	a.settings.Poller.Targets = a.poller.Targets()
+	a.settingsMu.Unlock()
+
+	return a.saveSettings()
+}
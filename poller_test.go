@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPollerRestartsOnlyChangedWorkers(t *testing.T) {
+	fetched := make(chan string, 16)
+	p := newPoller(func(ctx context.Context, t PollTarget) (map[string]interface{}, error) {
+		fetched <- t.PsKey
+		return map[string]interface{}{}, nil
+	}, func(t PollTarget, r PollResult) {}, log.New(io.Discard, "", 0))
+
+	// polled returns the targets fetched since the last call, sorted
+	polled := func() []string {
+		var keys []string
+		for {
+			select {
+			case key := <-fetched:
+				keys = append(keys, key)
+			case <-time.After(100 * time.Millisecond):
+				slices.Sort(keys)
+				return keys
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	battery := PollTarget{PsKey: "1_43_2_1", PointIDs: []int{58604}, Tray: true}
+	inverter := PollTarget{PsKey: "1_14_1_1", PointIDs: []int{13011}}
+	settings := PollerSettings{IntervalSeconds: 300, Targets: []PollTarget{battery, inverter}}
+	p.Start(ctx, settings)
+	if got := polled(); !slices.Equal(got, []string{"1_14_1_1", "1_43_2_1"}) {
+		t.Fatalf("polled on start = %v", got)
+	}
+
+	// Saving settings, or reloading them in the daemon, changes nothing
+	p.Watch(battery)
+	p.Watch(inverter)
+	p.Configure(settings)
+	if got := polled(); got != nil {
+		t.Errorf("polled after re-applying the same settings = %v, want none", got)
+	}
+
+	inverter.PointIDs = []int{13011, 13141}
+	p.Watch(inverter)
+	if got := polled(); !slices.Equal(got, []string{"1_14_1_1"}) {
+		t.Errorf("polled after changing a target = %v, want only it", got)
+	}
+
+	settings.IntervalSeconds = 60
+	p.Configure(settings)
+	if got := polled(); !slices.Equal(got, []string{"1_14_1_1", "1_43_2_1"}) {
+		t.Errorf("polled after changing the interval = %v, want every target", got)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"wails-sungrow-isolarcloud-app/trayicon"
+)
+
+// Settings stores user preferences
+type Settings struct {
+	Poller  PollerSettings   `json:"poller"`
+	History HistorySettings  `json:"history"`
+	Metrics MetricsSettings  `json:"metrics"`
+	MQTT    MQTTSettings     `json:"mqtt"`
+	Auth    AuthSettings     `json:"auth"`
+	Alerts  AlertSettings    `json:"alerts"`
+	Webhook WebhookSettings  `json:"webhook"`
+	Tray    trayicon.Options `json:"tray"`
+	// StoredSecrets lists the secretSettings kept in the credential store, so a secret
+	// that can't be read back is reported rather than silently treated as unset
+	StoredSecrets []string `json:"storedSecrets,omitempty"`
+}
+
+// defaultSettings returns the settings used before the user changes anything
+func defaultSettings() Settings {
+	return Settings{
+		Poller: PollerSettings{
+			IntervalSeconds:   300,
+			JitterSeconds:     15,
+			MaxBackoffSeconds: 1800,
+		},
+		History: HistorySettings{
+			RawRetentionDays: 7,
+			RetentionDays:    365,
+		},
+		Metrics: MetricsSettings{
+			ListenAddr: "127.0.0.1:9469",
+		},
+		MQTT: MQTTSettings{
+			TopicPrefix:     "sungrow",
+			Discovery:       true,
+			DiscoveryPrefix: "homeassistant",
+		},
+		Auth: AuthSettings{
+			CallbackTimeoutSeconds: 300,
+		},
+		Alerts: AlertSettings{
+			Notifications: true,
+			Rules:         defaultAlertRules(),
+		},
+		Webhook: WebhookSettings{
+			Alerts:        true,
+			StatusChanges: true,
+		},
+		Tray: trayicon.DefaultOptions(),
+	}
+}
+
+// appConfigDir returns the directory holding the app's config files, creating it if needed
+func appConfigDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	appDir := filepath.Join(configDir, "SungrowMonitor")
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		return "", err
+	}
+
+	return appDir, nil
+}
+
+// secretSettings returns the settings fields kept in the credential store instead of
+// settings.json. An empty field means unchanged; ClearSecretSetting removes a secret.
+func secretSettings(s *Settings) map[string]*string {
+	return map[string]*string{
+		"mqttPassword":   &s.MQTT.Password,
+		webhookSecretKey: &s.Webhook.Secret,
+	}
+}
+
+// GetSettings returns the current settings
+func (a *App) GetSettings() Settings {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	return a.settings
+}
+
+// SaveSettings stores new settings and applies them to running subsystems
+func (a *App) SaveSettings(settings Settings) error {
+	// Watched devices are managed through WatchDevice/UnwatchDevice
+	settings.Poller.Targets = a.poller.Targets()
+	settings.Poller = settings.Poller.withDefaults()
+	settings.Auth = settings.Auth.withDefaults()
+	if settings.Metrics.ListenAddr == "" {
+		settings.Metrics.ListenAddr = defaultSettings().Metrics.ListenAddr
+	}
+	alertSettings, err := settings.Alerts.withDefaults()
+	if err != nil {
+		return err
+	}
+	settings.Alerts = alertSettings
+	if err := settings.Webhook.validate(); err != nil {
+		return err
+	}
+	settings.Tray = settings.Tray.WithDefaults()
+	if err := settings.Tray.Validate(); err != nil {
+		return err
+	}
+
+	a.settingsMu.Lock()
+	// Secrets left empty are unchanged
+	current := a.settings
+	settings.StoredSecrets = current.StoredSecrets
+	currentSecrets := secretSettings(&current)
+	for key, field := range secretSettings(&settings) {
+		if *field == "" {
+			*field = *currentSecrets[key]
+		}
+	}
+	a.settings = settings
+	a.settingsMu.Unlock()
+
+	if err := a.saveSettings(); err != nil {
+		return err
+	}
+
+	a.applySettings(settings)
+	return nil
+}
+
+// applySettings reconfigures the running background services
+func (a *App) applySettings(settings Settings) {
+	a.poller.Configure(settings.Poller)
+	if a.history != nil {
+		a.history.SetPolicy(settings.History.policy())
+	}
+	a.alerts.SetRules(settings.Alerts.Rules)
+	a.applyWebhookSettings(settings.Webhook)
+	a.applyTraySettings()
+	if a.ctx != nil {
+		a.applyMetricsSettings(a.ctx, settings.Metrics)
+		a.applyMQTTSettings(a.ctx, settings.MQTT)
+	}
+}
+
+// loadSettings loads settings from file, falling back to defaults
+func (a *App) loadSettings() {
+	settings := defaultSettings()
+
+	appDir, err := appConfigDir()
+	if err == nil {
+		data, err := os.ReadFile(filepath.Join(appDir, "settings.json"))
+		if err == nil {
+			if err := json.Unmarshal(data, &settings); err != nil {
+				settings = defaultSettings()
+			}
+		}
+	}
+	settings.Poller = settings.Poller.withDefaults()
+	settings.Auth = settings.Auth.withDefaults()
+	if settings.Metrics.ListenAddr == "" {
+		settings.Metrics.ListenAddr = defaultSettings().Metrics.ListenAddr
+	}
+	if alertSettings, err := settings.Alerts.withDefaults(); err == nil {
+		settings.Alerts = alertSettings
+	} else {
+		a.log.Printf("loadSettings: %v, using the default alert rules\n", err)
+		settings.Alerts = defaultSettings().Alerts
+	}
+	a.alerts.SetRules(settings.Alerts.Rules)
+	a.loadSecretSettings(&settings)
+	settings.Tray = settings.Tray.WithDefaults()
+	if err := settings.Tray.Validate(); err != nil {
+		a.log.Printf("loadSettings: %v, using the default tray icon\n", err)
+		settings.Tray = defaultSettings().Tray
+	}
+
+	a.settingsMu.Lock()
+	a.settings = settings
+	a.settingsMu.Unlock()
+}
+
+// saveSettings saves settings to file
+func (a *App) saveSettings() error {
+	appDir, err := appConfigDir()
+	if err != nil {
+		return err
+	}
+
+	a.settingsMu.Lock()
+	settings := a.settings
+	a.settingsMu.Unlock()
+
+	store, err := a.credentialStore()
+	if err != nil {
+		return err
+	}
+	settings.StoredSecrets = slices.Clone(settings.StoredSecrets)
+	for key, field := range secretSettings(&settings) {
+		if *field == "" {
+			continue
+		}
+		if err := store.Save(key, []byte(*field)); err != nil {
+			return fmt.Errorf("cannot store %s: %w", key, err)
+		}
+		if !slices.Contains(settings.StoredSecrets, key) {
+			settings.StoredSecrets = append(settings.StoredSecrets, key)
+		}
+		*field = ""
+	}
+	slices.Sort(settings.StoredSecrets)
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(appDir, "settings.json"), data, 0600); err != nil {
+		return err
+	}
+
+	a.settingsMu.Lock()
+	a.settings.StoredSecrets = settings.StoredSecrets
+	a.settingsMu.Unlock()
+	return nil
+}
+
+// loadSecretSettings reads the secretSettings kept in the credential store back into
+// settings loaded from settings.json. Values still in settings.json from older versions
+// are kept, and moved into the store on the next save.
+func (a *App) loadSecretSettings(settings *Settings) {
+	store, err := a.credentialStore()
+	if err != nil {
+		a.log.Printf("loadSecretSettings: %v\n", err)
+		return
+	}
+
+	for key, field := range secretSettings(settings) {
+		if *field != "" {
+			continue
+		}
+		data, err := store.Load(key)
+		if err == nil {
+			*field = string(data)
+			continue
+		}
+		if slices.Contains(settings.StoredSecrets, key) {
+			a.log.Printf("loadSecretSettings: %s is in the %s but can't be read: %v\n", key, store.Name(), err)
+		}
+	}
+}
+
+// ClearSecretSetting removes a secret setting, such as the MQTT password, from the
+// credential store. Saving settings with the field empty leaves it unchanged.
+func (a *App) ClearSecretSetting(key string) error {
+	if _, ok := secretSettings(&Settings{})[key]; !ok {
+		return fmt.Errorf("unknown secret setting %q", key)
+	}
+
+	store, err := a.credentialStore()
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(key); err != nil {
+		return fmt.Errorf("cannot remove %s: %w", key, err)
+	}
+
+	a.settingsMu.Lock()
+	*secretSettings(&a.settings)[key] = ""
+	a.settings.StoredSecrets = slices.DeleteFunc(slices.Clone(a.settings.StoredSecrets), func(k string) bool {
+		return k == key
+	})
+	settings := a.settings
+	a.settingsMu.Unlock()
+
+	if err := a.saveSettings(); err != nil {
+		return err
+	}
+	a.applySettings(settings)
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestApplySettingsRestartsOnlyChangedServices(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a := NewApp()
+	a.log.SetOutput(io.Discard)
+	a.ctx = ctx
+	defer a.shutdown(ctx)
+
+	settings := defaultSettings()
+	settings.Metrics = MetricsSettings{Enabled: true, ListenAddr: "127.0.0.1:0"}
+	settings.MQTT = MQTTSettings{Enabled: true, Broker: "tcp://127.0.0.1:1", TopicPrefix: "sungrow"}
+	a.applySettings(settings)
+	metricsServer, bridge := a.metricsServer, a.mqtt
+	if metricsServer == nil || bridge == nil {
+		t.Fatalf("metrics server %v and MQTT bridge %v not started", metricsServer, bridge)
+	}
+
+	// Saving unrelated settings leaves both running
+	settings.Poller.IntervalSeconds = 60
+	a.applySettings(settings)
+	if a.metricsServer != metricsServer || a.mqtt != bridge {
+		t.Error("saving unrelated settings restarted the metrics server or MQTT bridge")
+	}
+
+	settings.MQTT.TopicPrefix = "solar"
+	a.applySettings(settings)
+	if a.mqtt == bridge || a.mqtt == nil || a.metricsServer != metricsServer {
+		t.Error("changing the MQTT settings didn't restart only the bridge")
+	}
+
+	settings.Metrics.Enabled = false
+	a.applySettings(settings)
+	if a.metricsServer != nil {
+		t.Error("disabling metrics left the server running")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+	"wails-sungrow-isolarcloud-app/status"
+)
+
+// statusJournalFile is the status event journal in the app config directory
+const statusJournalFile = "status-events.jsonl"
+
+// openStatusJournal opens the status event journal and prunes it daily, keeping events
+// as long as history is kept, until ctx is cancelled
+func (a *App) openStatusJournal(ctx context.Context) {
+	appDir, err := appConfigDir()
+	if err != nil {
+		a.log.Printf("openStatusJournal: %v\n", err)
+		return
+	}
+
+	journal, err := status.OpenJournal(filepath.Join(appDir, statusJournalFile))
+	if err != nil {
+		a.log.Printf("openStatusJournal: %v\n", err)
+		return
+	}
+	a.statusJournal = journal
+
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for {
+			retention := time.Duration(a.GetSettings().History.RetentionDays) * 24 * time.Hour
+			if err := journal.Prune(time.Now().Add(-retention)); err != nil {
+				a.log.Printf("openStatusJournal: pruning failed: %v\n", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// watchStatus lists plants, and the devices of plants with watched devices, every
+// poller interval so their status changes are noticed without the window open
+func (a *App) watchStatus(ctx context.Context) {
+	go func() {
+		for {
+			a.refreshStatus(ctx)
+
+			interval := time.Duration(a.GetSettings().Poller.IntervalSeconds) * time.Second
+			timer := time.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+}
+
+// refreshStatus takes a snapshot of plant and device status
+func (a *App) refreshStatus(ctx context.Context) {
+	if len(a.signedInAccounts()) == 0 {
+		return
+	}
+
+	// Listing plants observes them
+	if _, err := a.listPlantsPerAccount(ctx, func(ctx context.Context, client *isolarcloud.Client) ([]Plant, error) {
+		return client.PlantList(ctx)
+	}); err != nil {
+		a.log.Printf("refreshStatus: %v\n", err)
+	}
+
+	var psIDs []int
+	for _, t := range a.poller.Targets() {
+		if t.PsID != 0 && !slices.Contains(psIDs, t.PsID) {
+			psIDs = append(psIDs, t.PsID)
+		}
+	}
+	for _, psID := range psIDs {
+		client, err := a.clientForPlant(psID)
+		if err != nil {
+			a.log.Printf("refreshStatus: plant %d: %v\n", psID, err)
+			continue
+		}
+		devices, err := client.DeviceList(ctx, psID)
+		if err != nil {
+			a.log.Printf("refreshStatus: plant %d: %v\n", psID, err)
+			continue
+		}
+		a.observeDevices(devices)
+	}
+}
+
+// observePlants records status changes of listed plants
+func (a *App) observePlants(plants []Plant) {
+	a.recordStatusEvents(a.statusWatcher.ObservePlants(plants, time.Now()))
+}
+
+// observeDevices records status changes of listed devices
+func (a *App) observeDevices(devices []PlantDevice) {
+	a.recordStatusEvents(a.statusWatcher.ObserveDevices(devices, time.Now()))
+}
+
+// recordStatusEvents journals status changes and tells the frontend about them
+func (a *App) recordStatusEvents(events []status.Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	for _, e := range events {
+		a.log.Printf("recordStatusEvents: %s\n", e.Message)
+		a.emit("status:event", e)
+		a.publishWebhook(webhookEventStatus, e)
+	}
+	if a.statusJournal == nil {
+		return
+	}
+	if err := a.statusJournal.Append(events...); err != nil {
+		a.log.Printf("recordStatusEvents: %v\n", err)
+	}
+}
+
+// GetStatusEvents returns journaled plant and device status changes, newest first
+func (a *App) GetStatusEvents(query status.Query) ([]status.Event, error) {
+	if a.statusJournal == nil {
+		return nil, fmt.Errorf("status journal is not available")
+	}
+
+	return a.statusJournal.Query(query)
+}
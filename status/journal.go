@@ -0,0 +1,191 @@
+package status
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Journal is an append-only log of status events, one JSON object per line
+type Journal struct {
+	mu   sync.Mutex
+	path string
+}
+
+// OpenJournal opens the journal at path, creating its directory if needed
+func OpenJournal(path string) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return &Journal{path: path}, nil
+}
+
+// Append adds events to the end of the journal
+func (j *Journal) Append(events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	// Finish a line cut short by a crash, so it doesn't swallow the first new event
+	if err := terminateLastLine(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// terminateLastLine appends a newline unless the file is empty or already ends in one
+func terminateLastLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = f.Write([]byte{'\n'})
+	return err
+}
+
+// Query selects events from the journal. Zero fields match everything.
+type Query struct {
+	PsID  int         `json:"psId,omitempty"`
+	PsKey string      `json:"psKey,omitempty"`
+	Types []EventType `json:"types,omitempty"`
+	From  int64       `json:"from,omitempty"` // Unix milliseconds, inclusive
+	To    int64       `json:"to,omitempty"`   // Unix milliseconds, exclusive
+	Limit int         `json:"limit,omitempty"`
+}
+
+// matches reports whether an event is selected by the query
+func (q Query) matches(e Event) bool {
+	switch {
+	case q.PsID != 0 && e.PsID != q.PsID:
+		return false
+	case q.PsKey != "" && e.PsKey != q.PsKey:
+		return false
+	case len(q.Types) > 0 && !slices.Contains(q.Types, e.Type):
+		return false
+	case q.From != 0 && e.Timestamp < q.From:
+		return false
+	case q.To != 0 && e.Timestamp >= q.To:
+		return false
+	}
+	return true
+}
+
+// Query returns the matching events, newest first
+func (j *Journal) Query(q Query) ([]Event, error) {
+	events := []Event{}
+	err := j.scan(func(e Event) {
+		if q.matches(e) {
+			events = append(events, e)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(events)
+	if q.Limit > 0 && len(events) > q.Limit {
+		events = events[:q.Limit]
+	}
+	return events, nil
+}
+
+// Prune drops events older than before
+func (j *Journal) Prune(before time.Time) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var kept []Event
+	dropped := 0
+	err := j.scanLocked(func(e Event) {
+		if e.Timestamp >= before.UnixMilli() {
+			kept = append(kept, e)
+		} else {
+			dropped++
+		}
+	})
+	if err != nil || dropped == 0 {
+		return err
+	}
+
+	tmp := j.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, e := range kept {
+		if err := enc.Encode(e); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// scan calls fn with every event in the journal, oldest first
+func (j *Journal) scan(fn func(Event)) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.scanLocked(fn)
+}
+
+// scanLocked reads the journal. Lines that can't be parsed, such as one cut short by
+// a crash, are skipped. The caller must hold mu.
+func (j *Journal) scanLocked(fn func(Event)) error {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		fn(e)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading status journal: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package status
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testEvents are a day of events for two plants, oldest first
+func testEvents() []Event {
+	return []Event{
+		{Type: EventOffline, Subject: SubjectPlant, PsID: 1, Name: "Home", Message: "Home went offline", Timestamp: 1000},
+		{Type: EventFaultRaised, Subject: SubjectDevice, PsID: 1, PsKey: "1_43_2_1", Name: "Battery", Severity: "alarm", Message: "Battery raised an alarm", Timestamp: 2000},
+		{Type: EventOnline, Subject: SubjectPlant, PsID: 1, Name: "Home", Message: "Home is back online", Timestamp: 3000},
+		{Type: EventOffline, Subject: SubjectPlant, PsID: 2, Name: "Shed", Message: "Shed went offline", Timestamp: 4000},
+	}
+}
+
+func TestJournal(t *testing.T) {
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "status", "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := j.Query(Query{}); err != nil || len(got) != 0 {
+		t.Errorf("Query of a new journal = %v, %v", got, err)
+	}
+
+	events := testEvents()
+	if err := j.Append(events[:1]...); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Append(events[1:]...); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []Event
+	}{
+		{name: "everything, newest first", query: Query{}, want: []Event{events[3], events[2], events[1], events[0]}},
+		{name: "one plant", query: Query{PsID: 1}, want: []Event{events[2], events[1], events[0]}},
+		{name: "one device", query: Query{PsKey: "1_43_2_1"}, want: []Event{events[1]}},
+		{name: "types", query: Query{Types: []EventType{EventOffline}}, want: []Event{events[3], events[0]}},
+		{name: "from inclusive, to exclusive", query: Query{From: 2000, To: 4000}, want: []Event{events[2], events[1]}},
+		{name: "limit keeps the newest", query: Query{Limit: 2}, want: []Event{events[3], events[2]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := j.Query(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Query = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJournalTruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := testEvents()
+	if err := j.Append(events[:2]...); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cut the last event short, as a crash mid-write would
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data[:len(data)-20], 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := j.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, events[:1]) {
+		t.Errorf("Query with a truncated last line = %+v, want the complete event", got)
+	}
+
+	// Events appended afterwards aren't lost to the partial line
+	if err := j.Append(events[2:]...); err != nil {
+		t.Fatal(err)
+	}
+	got, err = j.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Event{events[3], events[2], events[0]}; !slices.Equal(got, want) {
+		t.Errorf("Query after appending = %+v, want %+v", got, want)
+	}
+}
+
+func TestJournalPrune(t *testing.T) {
+	j, err := OpenJournal(filepath.Join(t.TempDir(), "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := testEvents()
+	if err := j.Append(events...); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := j.Prune(time.UnixMilli(3000)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := j.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Event{events[3], events[2]}; !slices.Equal(got, want) {
+		t.Errorf("Query after pruning = %+v, want %+v", got, want)
+	}
+}
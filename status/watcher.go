@@ -0,0 +1,214 @@
+// Package status watches the status fields of plants and devices for transitions, such
+// as a plant going offline or a device raising a fault, and keeps a journal of them.
+//
+// The watcher diffs successive snapshots from the plant and device lists. The first
+// snapshot of a plant or device only sets its baseline, so restarting doesn't report
+// every existing fault again.
+package status
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+)
+
+// EventType is the kind of transition an event records
+type EventType string
+
+const (
+	EventOffline          EventType = "offline"
+	EventOnline           EventType = "online"
+	EventFaultRaised      EventType = "fault_raised"
+	EventFaultCleared     EventType = "fault_cleared"
+	EventGridDisconnected EventType = "grid_disconnected"
+	EventGridConnected    EventType = "grid_connected"
+)
+
+// Subject is what an event is about
+type Subject string
+
+const (
+	SubjectPlant  Subject = "plant"
+	SubjectDevice Subject = "device"
+)
+
+// Event is a status transition of a plant or device
+type Event struct {
+	Type    EventType `json:"type"`
+	Subject Subject   `json:"subject"`
+	PsID    int       `json:"psId"`
+	PsKey   string    `json:"psKey,omitempty"` // devices only
+	Name    string    `json:"name"`
+	// Severity is "fault" or "alarm" for fault_raised events
+	Severity  string `json:"severity,omitempty"`
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"` // Unix milliseconds
+}
+
+// Plant status codes returned by the API
+const (
+	plantOnline        = 1
+	plantAlarm         = 2
+	plantNormal        = 3
+	plantGridConnected = 1
+)
+
+// Device status codes returned by the API
+const (
+	deviceAlarm   = 2
+	deviceNormal  = 4
+	deviceOnline  = "1"
+	deviceOffline = "0"
+)
+
+// Watcher remembers the last status of each plant and device it has seen
+type Watcher struct {
+	mu      sync.Mutex
+	plants  map[int]isolarcloud.Plant
+	devices map[string]isolarcloud.PlantDevice // by ps_key
+}
+
+// NewWatcher creates a watcher with no baseline
+func NewWatcher() *Watcher {
+	return &Watcher{
+		plants:  map[int]isolarcloud.Plant{},
+		devices: map[string]isolarcloud.PlantDevice{},
+	}
+}
+
+// ObservePlants diffs plants against their last snapshot. Plants missing from the list
+// are left alone, so a single page of plants can be observed.
+func (w *Watcher) ObservePlants(plants []isolarcloud.Plant, at time.Time) []Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var events []Event
+	for _, p := range plants {
+		previous, seen := w.plants[p.PsID]
+		w.plants[p.PsID] = p
+		if seen {
+			events = append(events, diffPlant(previous, p, at)...)
+		}
+	}
+	return events
+}
+
+// ObserveDevices diffs devices against their last snapshot
+func (w *Watcher) ObserveDevices(devices []isolarcloud.PlantDevice, at time.Time) []Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var events []Event
+	for _, d := range devices {
+		if d.PsKey == "" {
+			continue
+		}
+		previous, seen := w.devices[d.PsKey]
+		w.devices[d.PsKey] = d
+		if seen {
+			events = append(events, diffDevice(previous, d, at)...)
+		}
+	}
+	return events
+}
+
+// diffPlant returns the transitions between two snapshots of a plant
+func diffPlant(previous, current isolarcloud.Plant, at time.Time) []Event {
+	event := func(t EventType, severity, message string) Event {
+		return Event{
+			Type:      t,
+			Subject:   SubjectPlant,
+			PsID:      current.PsID,
+			Name:      current.PsName,
+			Severity:  severity,
+			Message:   message,
+			Timestamp: at.UnixMilli(),
+		}
+	}
+
+	var events []Event
+	if wasOnline, online := previous.OnlineStatus == plantOnline, current.OnlineStatus == plantOnline; wasOnline != online {
+		if online {
+			events = append(events, event(EventOnline, "", fmt.Sprintf("%s is back online", current.PsName)))
+		} else {
+			events = append(events, event(EventOffline, "", fmt.Sprintf("%s went offline", current.PsName)))
+		}
+	}
+
+	// A fault status of 0 means the field was missing, not a change
+	if previous.PsFaultStatus != current.PsFaultStatus && previous.PsFaultStatus != 0 && current.PsFaultStatus != 0 {
+		if current.PsFaultStatus == plantNormal {
+			events = append(events, event(EventFaultCleared, "", fmt.Sprintf("%s fault cleared", current.PsName)))
+		} else {
+			severity := faultSeverity(current.PsFaultStatus, plantAlarm)
+			events = append(events, event(EventFaultRaised, severity, fmt.Sprintf("%s raised %s", current.PsName, withArticle(severity))))
+		}
+	}
+
+	if wasConnected, connected := previous.GridConnectionStatus == plantGridConnected, current.GridConnectionStatus == plantGridConnected; wasConnected != connected {
+		if connected {
+			events = append(events, event(EventGridConnected, "", fmt.Sprintf("%s reconnected to the grid", current.PsName)))
+		} else {
+			events = append(events, event(EventGridDisconnected, "", fmt.Sprintf("%s disconnected from the grid", current.PsName)))
+		}
+	}
+
+	return events
+}
+
+// diffDevice returns the transitions between two snapshots of a device
+func diffDevice(previous, current isolarcloud.PlantDevice, at time.Time) []Event {
+	event := func(t EventType, severity, message string) Event {
+		return Event{
+			Type:      t,
+			Subject:   SubjectDevice,
+			PsID:      current.PsID,
+			PsKey:     current.PsKey,
+			Name:      current.DeviceName,
+			Severity:  severity,
+			Message:   message,
+			Timestamp: at.UnixMilli(),
+		}
+	}
+
+	var events []Event
+	// dev_status is sometimes omitted, only a change between known values is a transition
+	if previous.DevStatus != current.DevStatus {
+		switch {
+		case previous.DevStatus == deviceOnline && current.DevStatus == deviceOffline:
+			events = append(events, event(EventOffline, "", fmt.Sprintf("%s went offline", current.DeviceName)))
+		case previous.DevStatus == deviceOffline && current.DevStatus == deviceOnline:
+			events = append(events, event(EventOnline, "", fmt.Sprintf("%s is back online", current.DeviceName)))
+		}
+	}
+
+	if previous.DevFaultStatus != current.DevFaultStatus && previous.DevFaultStatus != 0 && current.DevFaultStatus != 0 {
+		if current.DevFaultStatus == deviceNormal {
+			events = append(events, event(EventFaultCleared, "", fmt.Sprintf("%s fault cleared", current.DeviceName)))
+		} else {
+			severity := faultSeverity(current.DevFaultStatus, deviceAlarm)
+			events = append(events, event(EventFaultRaised, severity, fmt.Sprintf("%s raised %s", current.DeviceName, withArticle(severity))))
+		}
+	}
+
+	return events
+}
+
+// faultSeverity names an abnormal fault status code. Codes other than alarm are
+// treated as faults.
+func faultSeverity(code, alarm int) string {
+	if code == alarm {
+		return "alarm"
+	}
+	return "fault"
+}
+
+// withArticle prefixes a severity with "a" or "an"
+func withArticle(severity string) string {
+	if severity == "alarm" {
+		return "an alarm"
+	}
+	return "a " + severity
+}
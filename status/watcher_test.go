@@ -0,0 +1,188 @@
+package status
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+)
+
+func TestObservePlants(t *testing.T) {
+	at := time.UnixMilli(1_700_000_000_000)
+	normal := isolarcloud.Plant{PsID: 1, PsName: "Home", OnlineStatus: plantOnline, PsFaultStatus: plantNormal, GridConnectionStatus: plantGridConnected}
+
+	// event is a transition of the plant at the observation time
+	event := func(t EventType, severity, message string) Event {
+		return Event{Type: t, Subject: SubjectPlant, PsID: 1, Name: "Home", Severity: severity, Message: message, Timestamp: at.UnixMilli()}
+	}
+	tests := []struct {
+		name   string
+		change func(*isolarcloud.Plant)
+		want   []Event
+	}{
+		{
+			name:   "unchanged",
+			change: func(*isolarcloud.Plant) {},
+		},
+		{
+			name:   "goes offline",
+			change: func(p *isolarcloud.Plant) { p.OnlineStatus = 0 },
+			want:   []Event{event(EventOffline, "", "Home went offline")},
+		},
+		{
+			name:   "raises a fault",
+			change: func(p *isolarcloud.Plant) { p.PsFaultStatus = 1 },
+			want:   []Event{event(EventFaultRaised, "fault", "Home raised a fault")},
+		},
+		{
+			name:   "raises an alarm",
+			change: func(p *isolarcloud.Plant) { p.PsFaultStatus = plantAlarm },
+			want:   []Event{event(EventFaultRaised, "alarm", "Home raised an alarm")},
+		},
+		{
+			name:   "fault status missing",
+			change: func(p *isolarcloud.Plant) { p.PsFaultStatus = 0 },
+		},
+		{
+			name:   "disconnects from the grid while going offline",
+			change: func(p *isolarcloud.Plant) { p.OnlineStatus, p.GridConnectionStatus = 0, 2 },
+			want: []Event{
+				event(EventOffline, "", "Home went offline"),
+				event(EventGridDisconnected, "", "Home disconnected from the grid"),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWatcher()
+			if events := w.ObservePlants([]isolarcloud.Plant{normal}, at.Add(-time.Minute)); events != nil {
+				t.Fatalf("first observation = %+v, want only a baseline", events)
+			}
+
+			changed := normal
+			tt.change(&changed)
+			if got := w.ObservePlants([]isolarcloud.Plant{changed}, at); !slices.Equal(got, tt.want) {
+				t.Errorf("ObservePlants = %+v, want %+v", got, tt.want)
+			}
+
+			// Changing back reports the opposite transitions, bar a missing fault status
+			got := w.ObservePlants([]isolarcloud.Plant{normal}, at)
+			if len(got) != len(tt.want) {
+				t.Errorf("ObservePlants changing back = %+v, want %d events", got, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestObservePlantsRecovery(t *testing.T) {
+	at := time.UnixMilli(1_700_000_000_000)
+	w := NewWatcher()
+	w.ObservePlants([]isolarcloud.Plant{{PsID: 1, PsName: "Home", PsFaultStatus: 1, GridConnectionStatus: 2}}, at)
+
+	got := w.ObservePlants([]isolarcloud.Plant{{PsID: 1, PsName: "Home", OnlineStatus: plantOnline, PsFaultStatus: plantNormal, GridConnectionStatus: plantGridConnected}}, at)
+	var types []EventType
+	for _, e := range got {
+		types = append(types, e.Type)
+	}
+	if want := []EventType{EventOnline, EventFaultCleared, EventGridConnected}; !slices.Equal(types, want) {
+		t.Errorf("ObservePlants on recovery = %v, want %v", types, want)
+	}
+
+	// Another page of plants leaves this one's baseline alone
+	if got := w.ObservePlants([]isolarcloud.Plant{{PsID: 2, PsName: "Shed"}}, at); got != nil {
+		t.Errorf("first observation of another plant = %+v", got)
+	}
+	if got := w.ObservePlants(nil, at); got != nil {
+		t.Errorf("empty observation = %+v", got)
+	}
+}
+
+func TestObserveDevices(t *testing.T) {
+	at := time.UnixMilli(1_700_000_000_000)
+	battery := isolarcloud.PlantDevice{PsID: 1, PsKey: "1_43_2_1", DeviceName: "Battery", DevStatus: deviceOnline, DevFaultStatus: deviceNormal}
+	inverter := isolarcloud.PlantDevice{PsID: 1, PsKey: "1_14_1_1", DeviceName: "Inverter", DevStatus: deviceOnline, DevFaultStatus: deviceNormal}
+
+	// event is a transition of the battery at the observation time
+	event := func(t EventType, severity, message string) Event {
+		return Event{Type: t, Subject: SubjectDevice, PsID: 1, PsKey: "1_43_2_1", Name: "Battery", Severity: severity, Message: message, Timestamp: at.UnixMilli()}
+	}
+	// state is the battery's dev_status and dev_fault_status
+	type state struct {
+		status string
+		fault  int
+	}
+	tests := []struct {
+		name  string
+		steps []state
+		want  [][]Event // events from each step after the first
+	}{
+		{
+			name:  "offline and back",
+			steps: []state{{deviceOnline, deviceNormal}, {deviceOffline, deviceNormal}, {deviceOnline, deviceNormal}},
+			want:  [][]Event{{event(EventOffline, "", "Battery went offline")}, {event(EventOnline, "", "Battery is back online")}},
+		},
+		{
+			name:  "status missing in between",
+			steps: []state{{deviceOnline, deviceNormal}, {"", deviceNormal}, {deviceOffline, deviceNormal}},
+			want:  [][]Event{nil, nil},
+		},
+		{
+			name:  "fault raised and cleared",
+			steps: []state{{deviceOnline, deviceNormal}, {deviceOnline, 1}, {deviceOnline, deviceNormal}},
+			want:  [][]Event{{event(EventFaultRaised, "fault", "Battery raised a fault")}, {event(EventFaultCleared, "", "Battery fault cleared")}},
+		},
+		{
+			name:  "fault becomes an alarm",
+			steps: []state{{deviceOnline, 1}, {deviceOnline, deviceAlarm}},
+			want:  [][]Event{{event(EventFaultRaised, "alarm", "Battery raised an alarm")}},
+		},
+		{
+			name:  "fault status missing",
+			steps: []state{{deviceOnline, 1}, {deviceOnline, 0}, {deviceOnline, 1}},
+			want:  [][]Event{nil, nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWatcher()
+			for i, step := range tt.steps {
+				d := battery
+				d.DevStatus, d.DevFaultStatus = step.status, step.fault
+				got := w.ObserveDevices([]isolarcloud.PlantDevice{d, inverter}, at)
+				if i == 0 {
+					if got != nil {
+						t.Fatalf("first observation = %+v, want only a baseline", got)
+					}
+					continue
+				}
+				if want := tt.want[i-1]; !slices.Equal(got, want) {
+					t.Errorf("step %d: ObserveDevices = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestObserveDevicesDisappearing(t *testing.T) {
+	at := time.UnixMilli(1_700_000_000_000)
+	battery := isolarcloud.PlantDevice{PsID: 1, PsKey: "1_43_2_1", DeviceName: "Battery", DevStatus: deviceOnline, DevFaultStatus: deviceNormal}
+	w := NewWatcher()
+	w.ObserveDevices([]isolarcloud.PlantDevice{battery}, at)
+
+	// A device missing from the list isn't reported, and keeps its baseline
+	if got := w.ObserveDevices(nil, at); got != nil {
+		t.Errorf("ObserveDevices without the battery = %+v, want no events", got)
+	}
+	offline := battery
+	offline.DevStatus = deviceOffline
+	got := w.ObserveDevices([]isolarcloud.PlantDevice{offline}, at)
+	if len(got) != 1 || got[0].Type != EventOffline {
+		t.Errorf("ObserveDevices when the battery returns offline = %+v, want it going offline", got)
+	}
+
+	// Devices without a ps_key can't be told apart and are ignored
+	if got := w.ObserveDevices([]isolarcloud.PlantDevice{{DeviceName: "Meter", DevStatus: deviceOnline}, {DeviceName: "Meter", DevStatus: deviceOffline}}, at); got != nil {
+		t.Errorf("ObserveDevices of devices without a ps_key = %+v", got)
+	}
+}
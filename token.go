@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"wails-sungrow-isolarcloud-app/isolarcloud"
+)
+
+// tokenRefreshLeeway is how long before TokenExpiry the access token is proactively refreshed
+const tokenRefreshLeeway = 5 * time.Minute
+
+// tokenExpiresWithin reports whether a millisecond expiry timestamp falls within d from now
+func tokenExpiresWithin(expiryMillis int64, d time.Duration) bool {
+	if expiryMillis == 0 {
+		return false
+	}
+	return time.Now().Add(d).UnixMilli() >= expiryMillis
+}
+
+// AccessToken returns a usable access token, refreshing it first if it is about to expire.
+// Together with RefreshAccessToken it makes the account the isolarcloud.AuthProvider of its
+// client, which is why these live on account rather than the frontend-bound App.
+func (acct *account) AccessToken(ctx context.Context) (string, error) {
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+
+	if acct.credentials == nil || acct.credentials.AccessToken == "" {
+		return "", isolarcloud.ErrNotAuthenticated
+	}
+
+	if acct.credentials.RefreshToken != "" && tokenExpiresWithin(acct.credentials.TokenExpiry, tokenRefreshLeeway) {
+		if err := acct.refreshTokenLocked(ctx); err != nil {
+			// The old token may still have a few minutes left, so keep using it
+			if !tokenExpiresWithin(acct.credentials.TokenExpiry, 0) {
+				acct.app.log.Printf("AccessToken: %s: proactive refresh failed, using current token: %v\n", acct.displayName(), err)
+				return acct.credentials.AccessToken, nil
+			}
+			return "", err
+		}
+	}
+
+	return acct.credentials.AccessToken, nil
+}
+
+// RefreshAccessToken refreshes the access token after the gateway rejected staleToken.
+// Concurrent callers holding the same stale token share a single refresh.
+func (acct *account) RefreshAccessToken(ctx context.Context, staleToken string) (string, error) {
+	acct.mu.Lock()
+	defer acct.mu.Unlock()
+
+	if acct.credentials == nil {
+		return "", isolarcloud.ErrNotAuthenticated
+	}
+
+	// Another request already refreshed while we were waiting
+	if acct.credentials.AccessToken != staleToken {
+		return acct.credentials.AccessToken, nil
+	}
+
+	acct.app.log.Printf("RefreshAccessToken: %s: gateway rejected access token, refreshing\n", acct.displayName())
+	if err := acct.refreshTokenLocked(ctx); err != nil {
+		return "", err
+	}
+
+	return acct.credentials.AccessToken, nil
+}
+
+// refreshTokenLocked exchanges the stored refresh token for a new access token.
+// The caller must hold mu.
+func (acct *account) refreshTokenLocked(ctx context.Context) error {
+	if acct.credentials.RefreshToken == "" {
+		return fmt.Errorf("no refresh token available, please log in again: %w", isolarcloud.ErrTokenExpired)
+	}
+
+	loginData, err := acct.clientLocked().RefreshToken(ctx, acct.credentials.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("token refresh failed: %w", err)
+	}
+
+	acct.credentials.AccessToken = loginData.AccessToken
+	if loginData.RefreshToken != "" {
+		acct.credentials.RefreshToken = loginData.RefreshToken
+	}
+	acct.credentials.TokenExpiry = loginData.ExpiryMillis()
+
+	acct.app.log.Printf("refreshTokenLocked: %s: access token refreshed, expires %s\n", acct.displayName(), time.UnixMilli(acct.credentials.TokenExpiry).Format(time.RFC3339))
+
+	return acct.saveLocked()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"image"
+	stdruntime "runtime"
+
+	"wails-sungrow-isolarcloud-app/trayicon"
+)
+
+// updateTray shows a battery state in the tray icon and title
+func (a *App) updateTray(state trayicon.State, title string) {
+	a.trayMu.Lock()
+	a.trayTitle = title
+	a.trayState = &state
+	a.trayMu.Unlock()
+
+	a.UpdateTrayTitle(title)
+	a.sendTrayIcon(state)
+}
+
+// applyTraySettings redraws the tray icon in the current style
+func (a *App) applyTraySettings() {
+	a.trayMu.Lock()
+	state := a.trayState
+	a.trayMu.Unlock()
+
+	if state != nil {
+		a.sendTrayIcon(*state)
+	}
+}
+
+// sendTrayIcon draws a battery state and hands it to the tray
+func (a *App) sendTrayIcon(state trayicon.State) {
+	iconBytes, err := a.trayIcon(state)
+	if err != nil {
+		a.log.Printf("sendTrayIcon: failed to generate icon: %v\n", err)
+		return
+	}
+
+	select {
+	case a.TrayIconChan <- iconBytes:
+	default:
+		a.log.Println("sendTrayIcon: TrayIconChan blocked/full")
+	}
+}
+
+// trayIcon draws a battery state in the tray settings' style. Windows gets an ICO
+// holding every size, so it can pick the one matching the display scale, unless a size
+// is set; other platforms get a PNG, 64px on macOS for Retina menu bars.
+func (a *App) trayIcon(state trayicon.State) ([]byte, error) {
+	options := a.GetSettings().Tray
+
+	// Windows requires usage of ICO format for system tray
+	if stdruntime.GOOS == "windows" {
+		sizes := trayicon.Sizes
+		if options.Size != 0 {
+			sizes = []int{options.Size}
+		}
+		var icons []image.Image
+		for _, size := range sizes {
+			options.Size = size
+			icon, err := trayicon.Render(state, options)
+			if err != nil {
+				return nil, err
+			}
+			icons = append(icons, icon)
+		}
+		return trayicon.ICO(icons...)
+	}
+
+	if options.Size == 0 && stdruntime.GOOS == "darwin" {
+		options.Size = 64
+	}
+	icon, err := trayicon.Render(state, options)
+	if err != nil {
+		return nil, err
+	}
+	return trayicon.PNG(icon)
+}
+
+// PreviewTrayIcon draws a battery state as a PNG with possibly unsaved tray settings
+func (a *App) PreviewTrayIcon(options trayicon.Options, state trayicon.State) ([]byte, error) {
+	icon, err := trayicon.Render(state, options.WithDefaults())
+	if err != nil {
+		return nil, err
+	}
+	return trayicon.PNG(icon)
+}
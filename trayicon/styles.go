@@ -0,0 +1,193 @@
+package trayicon
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+)
+
+// transparent is the colour outside every shape
+var transparent = color.NRGBA{}
+
+// shader returns the shader drawing a state in the options' style
+func (o Options) shader(s State, c colors) shader {
+	fill := o.level(c, s.Percentage)
+	switch o.Style {
+	case StyleBar:
+		return barShader(s.Percentage, fill, c)
+	case StyleText:
+		return textShader(s.Percentage, fill, c)
+	case StyleFlow:
+		return flowShader(s, fill, c)
+	default:
+		return pieShader(s.Percentage, fill, c)
+	}
+}
+
+// pieShader draws a circle filled clockwise from the top in proportion to the charge
+func pieShader(percentage int, fill color.NRGBA, c colors) shader {
+	limit := float64(percentage) / 100
+	return func(x, y float64) color.NRGBA {
+		if !inCircle(x, y, 0.5, 0.5, 15.0/32) {
+			return transparent
+		}
+		if clockwiseFraction(x, y) <= limit {
+			return fill
+		}
+		return c.empty
+	}
+}
+
+// barShader draws an upright battery filled from the bottom in proportion to the charge
+func barShader(percentage int, fill color.NRGBA, c colors) shader {
+	const (
+		left, right = 0.22, 0.78 // body
+		top, bottom = 0.16, 0.96
+		stroke      = 0.08
+		nubL, nubR  = 0.38, 0.62 // terminal
+		nubTop      = 0.04
+		innerTop    = top + stroke + 0.04
+		innerBottom = bottom - stroke - 0.04
+		innerL      = left + stroke + 0.04
+		innerR      = right - stroke - 0.04
+		innerHeight = innerBottom - innerTop
+	)
+	level := innerBottom - innerHeight*float64(percentage)/100
+
+	return func(x, y float64) color.NRGBA {
+		switch {
+		case inRect(x, y, nubL, nubTop, nubR, top):
+			return c.empty
+		case !inRect(x, y, left, top, right, bottom):
+			return transparent
+		case !inRect(x, y, left+stroke, top+stroke, right-stroke, bottom-stroke):
+			return c.empty
+		case inRect(x, y, innerL, level, innerR, innerBottom):
+			return fill
+		}
+		return transparent
+	}
+}
+
+// textShader draws the percentage in block digits on a rounded badge of the charge colour
+func textShader(percentage int, fill color.NRGBA, c colors) shader {
+	digits := strconv.Itoa(percentage)
+
+	// Digits are glyphWidth cells wide with a one cell gap, scaled to fit the badge
+	cols := len(digits)*(glyphWidth+1) - 1
+	cell := min(0.86/float64(cols), 0.6/glyphHeight)
+	left := (1 - cell*float64(cols)) / 2
+	top := (1 - cell*glyphHeight) / 2
+
+	return func(x, y float64) color.NRGBA {
+		if !inRoundedRect(x, y, 0.02, 0.02, 0.98, 0.98, 0.2) {
+			return transparent
+		}
+		col := int(math.Floor((x - left) / cell))
+		row := int(math.Floor((y - top) / cell))
+		if col >= 0 && row >= 0 && row < glyphHeight && col < cols && col%(glyphWidth+1) < glyphWidth {
+			glyph := glyphs[digits[col/(glyphWidth+1)]-'0']
+			if glyph[row][col%(glyphWidth+1)] == '#' {
+				return c.text
+			}
+		}
+		return fill
+	}
+}
+
+// flowShader draws the charge as a ring around an arrow pointing up while charging and
+// down while discharging, or a dot when idle
+func flowShader(s State, fill color.NRGBA, c colors) shader {
+	limit := float64(s.Percentage) / 100
+
+	return func(x, y float64) color.NRGBA {
+		if inCircle(x, y, 0.5, 0.5, 15.0/32) && !inCircle(x, y, 0.5, 0.5, 11.0/32) {
+			if clockwiseFraction(x, y) <= limit {
+				return fill
+			}
+			return c.empty
+		}
+
+		switch s.Flow {
+		case FlowCharging:
+			if inUpArrow(x, y) {
+				return c.charging
+			}
+		case FlowDischarging:
+			if inUpArrow(x, 1-y) {
+				return c.discharging
+			}
+		default:
+			if inCircle(x, y, 0.5, 0.5, 0.09) {
+				return c.empty
+			}
+		}
+		return transparent
+	}
+}
+
+// inUpArrow reports whether a point is inside the arrow of the flow style, pointing up
+func inUpArrow(x, y float64) bool {
+	const (
+		tip, base      = 0.24, 0.5 // head
+		headHalfWidth  = 0.18
+		shaftHalfWidth = 0.065
+		shaftBottom    = 0.76
+	)
+	if y >= tip && y <= base {
+		halfWidth := headHalfWidth * (y - tip) / (base - tip)
+		return math.Abs(x-0.5) <= halfWidth
+	}
+	return y > base && y <= shaftBottom && math.Abs(x-0.5) <= shaftHalfWidth
+}
+
+// clockwiseFraction returns how far round a point is from the top, clockwise, from 0 to 1
+func clockwiseFraction(x, y float64) float64 {
+	// Atan2 is 0 to the right and -Pi/2 at the top; shift so the top is 0
+	angle := math.Atan2(y-0.5, x-0.5) + math.Pi/2
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	return angle / (2 * math.Pi)
+}
+
+// inCircle reports whether a point is inside a circle
+func inCircle(x, y, cx, cy, r float64) bool {
+	dx, dy := x-cx, y-cy
+	return dx*dx+dy*dy <= r*r
+}
+
+// inRect reports whether a point is inside a rectangle
+func inRect(x, y, x0, y0, x1, y1 float64) bool {
+	return x >= x0 && x < x1 && y >= y0 && y < y1
+}
+
+// inRoundedRect reports whether a point is inside a rectangle with rounded corners
+func inRoundedRect(x, y, x0, y0, x1, y1, r float64) bool {
+	if !inRect(x, y, x0, y0, x1, y1) {
+		return false
+	}
+	// Distance past the straight edges into a corner's square
+	dx := max(x0+r-x, x-(x1-r), 0)
+	dy := max(y0+r-y, y-(y1-r), 0)
+	return dx*dx+dy*dy <= r*r
+}
+
+// Block digit glyphs, glyphWidth by glyphHeight cells
+const (
+	glyphWidth  = 3
+	glyphHeight = 5
+)
+
+var glyphs = [10][glyphHeight]string{
+	{"###", "#.#", "#.#", "#.#", "###"},
+	{".#.", "##.", ".#.", ".#.", "###"},
+	{"###", "..#", "###", "#..", "###"},
+	{"###", "..#", "###", "..#", "###"},
+	{"#.#", "#.#", "###", "..#", "..#"},
+	{"###", "#..", "###", "..#", "###"},
+	{"###", "#..", "###", "#.#", "###"},
+	{"###", "..#", "..#", "..#", "..#"},
+	{"###", "#.#", "###", "#.#", "###"},
+	{"###", "#.#", "###", "..#", "###"},
+}
@@ -0,0 +1,310 @@
+// Package trayicon draws the battery status icons shown in the system tray.
+//
+// Icons are drawn from resolution independent shapes, so every style renders cleanly at
+// each of the supported sizes, and edges are antialiased by supersampling each pixel.
+// Rendering is deterministic: the same state and options always give the same pixels.
+package trayicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Style is the way the battery status is drawn
+type Style string
+
+// Icon styles
+const (
+	StylePie  Style = "pie"  // pie chart filling clockwise from the top
+	StyleBar  Style = "bar"  // vertical battery filling from the bottom
+	StyleText Style = "text" // the percentage as digits on a coloured badge
+	StyleFlow Style = "flow" // ring of charge around an arrow showing charge/discharge
+)
+
+// Styles lists every style, in the order they're offered to users
+var Styles = []Style{StylePie, StyleBar, StyleText, StyleFlow}
+
+// Sizes are the supported icon sizes in pixels, covering standard and HiDPI trays
+var Sizes = []int{16, 32, 64}
+
+// DefaultSize is used when Options.Size is 0
+const DefaultSize = 32
+
+// supersamples is the number of samples per pixel along each axis when antialiasing
+const supersamples = 4
+
+// Flow is the direction energy is moving through the battery
+type Flow string
+
+// Battery flows
+const (
+	FlowIdle        Flow = "idle"
+	FlowCharging    Flow = "charging"
+	FlowDischarging Flow = "discharging"
+)
+
+// State is what an icon shows
+type State struct {
+	Percentage int  `json:"percentage"` // state of charge, clamped to 0-100
+	Flow       Flow `json:"flow"`       // only drawn by the flow style; empty means idle
+}
+
+// Palette holds the icon colours as "#rrggbb" or "#rrggbbaa"
+type Palette struct {
+	Low         string `json:"low"`         // charge at or below the low threshold
+	Medium      string `json:"medium"`      // charge at or below the medium threshold
+	High        string `json:"high"`        // charge above the medium threshold
+	Empty       string `json:"empty"`       // the uncharged part and outlines
+	Text        string `json:"text"`        // digits of the text style
+	Charging    string `json:"charging"`    // arrow of the flow style while charging
+	Discharging string `json:"discharging"` // arrow of the flow style while discharging
+}
+
+// DefaultPalette returns the colours of the original tray icon
+func DefaultPalette() Palette {
+	return Palette{
+		Low:         "#dc2626",
+		Medium:      "#eab308",
+		High:        "#16a34a",
+		Empty:       "#505050",
+		Text:        "#ffffff",
+		Charging:    "#38bdf8",
+		Discharging: "#f97316",
+	}
+}
+
+// Options controls how icons are drawn
+type Options struct {
+	Style Style `json:"style"`
+	// Size is the width and height in pixels, one of Sizes, or 0 for DefaultSize
+	Size      int  `json:"size"`
+	Antialias bool `json:"antialias"`
+	// Charge at or below Low percent uses the low colour, then at or below Medium the
+	// medium colour, and above that the high colour
+	Low     int     `json:"low"`
+	Medium  int     `json:"medium"`
+	Palette Palette `json:"palette"`
+}
+
+// DefaultOptions returns options matching the original 32x32 pie icon, antialiased
+func DefaultOptions() Options {
+	return Options{
+		Style:     StylePie,
+		Antialias: true,
+		Low:       20,
+		Medium:    50,
+		Palette:   DefaultPalette(),
+	}
+}
+
+// WithDefaults fills in an unset style and colours from DefaultOptions
+func (o Options) WithDefaults() Options {
+	def := DefaultOptions()
+	if o.Style == "" {
+		o.Style = def.Style
+	}
+	for _, f := range []struct {
+		dst *string
+		def string
+	}{
+		{&o.Palette.Low, def.Palette.Low},
+		{&o.Palette.Medium, def.Palette.Medium},
+		{&o.Palette.High, def.Palette.High},
+		{&o.Palette.Empty, def.Palette.Empty},
+		{&o.Palette.Text, def.Palette.Text},
+		{&o.Palette.Charging, def.Palette.Charging},
+		{&o.Palette.Discharging, def.Palette.Discharging},
+	} {
+		if *f.dst == "" {
+			*f.dst = f.def
+		}
+	}
+	return o
+}
+
+// Validate checks the options can be rendered
+func (o Options) Validate() error {
+	if !slices.Contains(Styles, o.Style) {
+		return fmt.Errorf("unknown tray icon style %q", o.Style)
+	}
+	if o.Size != 0 && !slices.Contains(Sizes, o.Size) {
+		return fmt.Errorf("tray icon size must be one of %v", Sizes)
+	}
+	if o.Low < 0 || o.Medium < o.Low || o.Medium > 100 {
+		return fmt.Errorf("tray icon thresholds must satisfy 0 <= low <= medium <= 100")
+	}
+	_, err := o.Palette.parse()
+	return err
+}
+
+// colors is a parsed palette
+type colors struct {
+	low, medium, high, empty, text, charging, discharging color.NRGBA
+}
+
+// parse converts the palette's hex colours
+func (p Palette) parse() (colors, error) {
+	var c colors
+	for _, f := range []struct {
+		name string
+		hex  string
+		dst  *color.NRGBA
+	}{
+		{"low", p.Low, &c.low},
+		{"medium", p.Medium, &c.medium},
+		{"high", p.High, &c.high},
+		{"empty", p.Empty, &c.empty},
+		{"text", p.Text, &c.text},
+		{"charging", p.Charging, &c.charging},
+		{"discharging", p.Discharging, &c.discharging},
+	} {
+		parsed, err := ParseColor(f.hex)
+		if err != nil {
+			return c, fmt.Errorf("tray icon %s colour: %w", f.name, err)
+		}
+		*f.dst = parsed
+	}
+	return c, nil
+}
+
+// ParseColor parses a "#rrggbb" or "#rrggbbaa" colour
+func ParseColor(hex string) (color.NRGBA, error) {
+	s := strings.TrimPrefix(hex, "#")
+	if len(s) == 6 {
+		s += "ff"
+	}
+	if len(s) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid colour %q, expected #rrggbb", hex)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid colour %q, expected #rrggbb", hex)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// level returns the colour for a charge percentage
+func (o Options) level(c colors, percentage int) color.NRGBA {
+	switch {
+	case percentage <= o.Low:
+		return c.low
+	case percentage <= o.Medium:
+		return c.medium
+	default:
+		return c.high
+	}
+}
+
+// Render draws an icon
+func Render(s State, o Options) (*image.RGBA, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	c, _ := o.Palette.parse()
+	s.Percentage = min(max(s.Percentage, 0), 100)
+
+	size := o.Size
+	if size == 0 {
+		size = DefaultSize
+	}
+	n := 1
+	if o.Antialias {
+		n = supersamples
+	}
+	return rasterize(o.shader(s, c), size, n), nil
+}
+
+// shader returns the colour at a point of an icon in unit coordinates, where (0, 0) is
+// the top left corner and (1, 1) the bottom right. Transparent outside the shape.
+type shader func(x, y float64) color.NRGBA
+
+// rasterize samples a shader n*n times per pixel, averaging the samples so shape edges
+// blend smoothly. With n = 1 each pixel takes the colour at its centre.
+func rasterize(shade shader, size, n int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	step := 1 / float64(size*n)
+	samples := float64(n * n)
+
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			// Accumulate premultiplied so transparent samples don't darken edges
+			var r, g, b, a float64
+			for sy := 0; sy < n; sy++ {
+				for sx := 0; sx < n; sx++ {
+					x := (float64(px*n+sx) + 0.5) * step
+					y := (float64(py*n+sy) + 0.5) * step
+					c := shade(x, y)
+					alpha := float64(c.A) / 255
+					r += float64(c.R) * alpha
+					g += float64(c.G) * alpha
+					b += float64(c.B) * alpha
+					a += alpha
+				}
+			}
+			img.SetRGBA(px, py, color.RGBA{
+				R: uint8(math.Round(r / samples)),
+				G: uint8(math.Round(g / samples)),
+				B: uint8(math.Round(b / samples)),
+				A: uint8(math.Round(a / samples * 255)),
+			})
+		}
+	}
+	return img
+}
+
+// PNG encodes an icon as PNG
+func PNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ICO encodes icons as a Windows .ico holding each of them as PNG, letting Windows pick
+// the size matching the display scale
+func ICO(imgs ...image.Image) ([]byte, error) {
+	entries := make([][]byte, len(imgs))
+	for i, img := range imgs {
+		data, err := PNG(img)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = data
+	}
+
+	buf := new(bytes.Buffer)
+
+	// ICONDIR header
+	binary.Write(buf, binary.LittleEndian, uint16(0))         // Reserved
+	binary.Write(buf, binary.LittleEndian, uint16(1))         // Type 1 = Icon
+	binary.Write(buf, binary.LittleEndian, uint16(len(imgs))) // Count
+
+	// ICONDIRENTRY for each image, followed by the image data
+	offset := 6 + 16*len(imgs)
+	for i, img := range imgs {
+		bounds := img.Bounds()
+		buf.WriteByte(uint8(bounds.Dx()))                               // Width (0 means 256)
+		buf.WriteByte(uint8(bounds.Dy()))                               // Height (0 means 256)
+		buf.WriteByte(0)                                                // ColorCount (0 for >= 8bpp)
+		buf.WriteByte(0)                                                // Reserved
+		binary.Write(buf, binary.LittleEndian, uint16(1))               // Planes
+		binary.Write(buf, binary.LittleEndian, uint16(32))              // BitCount
+		binary.Write(buf, binary.LittleEndian, uint32(len(entries[i]))) // SizeInBytes
+		binary.Write(buf, binary.LittleEndian, uint32(offset))          // Offset
+		offset += len(entries[i])
+	}
+	for _, data := range entries {
+		buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,106 @@
+package trayicon
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images in testdata")
+
+// goldenStates are the states drawn for each style's golden images
+var goldenStates = map[Style]State{
+	StylePie:  {Percentage: 42},
+	StyleBar:  {Percentage: 42},
+	StyleText: {Percentage: 42},
+	StyleFlow: {Percentage: 42, Flow: FlowCharging},
+}
+
+func TestRenderGolden(t *testing.T) {
+	for _, style := range Styles {
+		for _, size := range Sizes {
+			for _, antialias := range []bool{true, false} {
+				name := fmt.Sprintf("%s_%d", style, size)
+				if antialias {
+					name += "_aa"
+				}
+				t.Run(name, func(t *testing.T) {
+					o := DefaultOptions()
+					o.Style, o.Size, o.Antialias = style, size, antialias
+					img, err := Render(goldenStates[style], o)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got, err := PNG(img)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					path := filepath.Join("testdata", name+".png")
+					if *update {
+						if err := os.WriteFile(path, got, 0644); err != nil {
+							t.Fatal(err)
+						}
+						return
+					}
+					want, err := os.ReadFile(path)
+					if err != nil {
+						t.Fatalf("%v (run go test -update to create it)", err)
+					}
+					if !bytes.Equal(got, want) {
+						t.Errorf("%s differs from the golden image; if the change is intended, run go test -update and review the images", path)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*Options)
+		wantErr bool
+	}{
+		{"defaults", func(*Options) {}, false},
+		{"automatic size", func(o *Options) { o.Size = 0 }, false},
+		{"unknown style", func(o *Options) { o.Style = "donut" }, true},
+		{"unsupported size", func(o *Options) { o.Size = 24 }, true},
+		{"low above medium", func(o *Options) { o.Low = 60 }, true},
+		{"medium above 100", func(o *Options) { o.Medium = 101 }, true},
+		{"named colour", func(o *Options) { o.Palette.Text = "red" }, true},
+		{"colour with alpha", func(o *Options) { o.Palette.Empty = "#50505080" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := DefaultOptions()
+			tt.change(&o)
+			if err := o.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestICO(t *testing.T) {
+	o := DefaultOptions()
+	o.Size = 16
+	small, _ := Render(State{Percentage: 50}, o)
+	o.Size = 64
+	large, _ := Render(State{Percentage: 50}, o)
+
+	ico, err := ICO(small, large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ico[:6], []byte{0, 0, 1, 0, 2, 0}) {
+		t.Fatalf("ICONDIR header = %v", ico[:6])
+	}
+	// Each ICONDIRENTRY starts with the width and height
+	if ico[6] != 16 || ico[7] != 16 || ico[22] != 64 || ico[23] != 64 {
+		t.Errorf("entry sizes = %dx%d, %dx%d", ico[6], ico[7], ico[22], ico[23])
+	}
+}
@@ -0,0 +1,241 @@
+package webhook
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Retry timing of deliveries the endpoint didn't accept
+const (
+	retryBaseDelay = 30 * time.Second
+	retryMaxDelay  = time.Hour
+)
+
+// DefaultMaxAge is how long a delivery is retried before it is dropped
+const DefaultMaxAge = 7 * 24 * time.Hour
+
+// Delivery is a rendered event waiting in the outbox
+type Delivery struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Body        json.RawMessage `json:"body"`
+	Created     int64           `json:"created"`     // Unix milliseconds
+	Attempts    int             `json:"attempts"`    // failed attempts so far
+	NextAttempt int64           `json:"nextAttempt"` // Unix milliseconds
+	LastError   string          `json:"lastError,omitempty"`
+}
+
+// Outbox is a directory of pending deliveries, one file each, sent oldest first
+type Outbox struct {
+	dir    string
+	maxAge time.Duration
+	mu     sync.Mutex
+	wake   chan struct{}
+}
+
+// OpenOutbox opens the outbox in dir, creating it if needed. Deliveries older than
+// maxAge are dropped.
+func OpenOutbox(dir string, maxAge time.Duration) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Outbox{dir: dir, maxAge: maxAge, wake: make(chan struct{}, 1)}, nil
+}
+
+// Enqueue renders an event with tmpl and stores it for delivery
+func (o *Outbox) Enqueue(tmpl string, e Event) error {
+	body, err := Render(tmpl, e)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	err = o.write(Delivery{ID: e.ID, Type: e.Type, Body: body, Created: e.Timestamp, NextAttempt: e.Timestamp})
+	o.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending returns the deliveries waiting to be sent, oldest first
+func (o *Outbox) Pending() ([]Delivery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.read()
+}
+
+// RetryNow makes every pending delivery due, e.g. after the endpoint was fixed
+func (o *Outbox) RetryNow() error {
+	o.mu.Lock()
+	deliveries, err := o.read()
+	for _, d := range deliveries {
+		if err != nil {
+			break
+		}
+		if d.NextAttempt > d.Created {
+			d.NextAttempt = d.Created
+			err = o.write(d)
+		}
+	}
+	o.mu.Unlock()
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// Run sends due deliveries until ctx is cancelled. Deliveries go out in order: after a
+// failure the rest wait for the failed one's next attempt, since the endpoint is likely
+// down and retrying out of order would reorder events. endpoint is called before each
+// round so setting changes apply to queued events; an endpoint without a URL pauses
+// delivery, keeping events queued until RetryNow or Enqueue wakes the loop. onError,
+// which may be nil, is told about failed attempts and dropped deliveries.
+func (o *Outbox) Run(ctx context.Context, endpoint func() Endpoint, onError func(error)) {
+	if onError == nil {
+		onError = func(error) {}
+	}
+	for {
+		wait := o.flush(ctx, endpoint(), onError)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-o.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// flush sends the due deliveries, returning how long until the next is due
+func (o *Outbox) flush(ctx context.Context, endpoint Endpoint, onError func(error)) time.Duration {
+	const idle = time.Hour
+
+	// Paused, e.g. the webhook is disabled; nothing is sent and nothing counts as failed
+	if endpoint.URL == "" {
+		return idle
+	}
+
+	deliveries, err := o.Pending()
+	if err != nil {
+		onError(fmt.Errorf("reading outbox: %w", err))
+		return retryBaseDelay
+	}
+
+	for _, d := range deliveries {
+		now := time.Now()
+		if o.maxAge > 0 && now.Sub(time.UnixMilli(d.Created)) > o.maxAge {
+			onError(fmt.Errorf("dropping %s event %s after %d attempts: %s", d.Type, d.ID, d.Attempts, d.LastError))
+			if err := o.remove(d.ID); err != nil {
+				onError(err)
+			}
+			continue
+		}
+		if wait := time.UnixMilli(d.NextAttempt).Sub(now); wait > 0 {
+			return wait
+		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := endpoint.Post(sendCtx, d.Type, d.ID, d.Body)
+		cancel()
+		if ctx.Err() != nil {
+			return idle
+		}
+		if err == nil {
+			if err := o.remove(d.ID); err != nil {
+				onError(err)
+			}
+			continue
+		}
+
+		d.Attempts++
+		d.LastError = err.Error()
+		delay := min(retryBaseDelay<<min(d.Attempts-1, 16), retryMaxDelay)
+		d.NextAttempt = now.Add(delay).UnixMilli()
+		onError(fmt.Errorf("delivering %s event %s failed (%d in a row), retrying in %s: %w", d.Type, d.ID, d.Attempts, delay, err))
+
+		o.mu.Lock()
+		if err := o.write(d); err != nil {
+			onError(fmt.Errorf("updating outbox: %w", err))
+		}
+		o.mu.Unlock()
+		return delay
+	}
+	return idle
+}
+
+// path is the file of a delivery
+func (o *Outbox) path(id string) string {
+	return filepath.Join(o.dir, id+".json")
+}
+
+// write stores a delivery, replacing the file atomically. The caller must hold mu.
+func (o *Outbox) write(d Delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	tmp := o.path(d.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path(d.ID))
+}
+
+// remove deletes a delivered or expired delivery
+func (o *Outbox) remove(id string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := os.Remove(o.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// read loads every delivery, oldest first, skipping files that can't be parsed, such as
+// one cut short by a crash. The caller must hold mu.
+func (o *Outbox) read() ([]Delivery, error) {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []Delivery
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(o.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var d Delivery
+		if err := json.Unmarshal(data, &d); err != nil {
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	slices.SortFunc(deliveries, func(x, y Delivery) int {
+		return cmp.Or(cmp.Compare(x.Created, y.Created), strings.Compare(x.ID, y.ID))
+	})
+	return deliveries, nil
+}
@@ -0,0 +1,326 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testEndpoint is an httptest webhook receiver recording the deliveries it accepted and
+// rejecting those listed in failing
+type testEndpoint struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	delivered []string
+	failing   map[string]bool
+}
+
+func newTestEndpoint(t *testing.T) *testEndpoint {
+	e := &testEndpoint{failing: map[string]bool{}}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(DeliveryHeader)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.failing[id] {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		e.delivered = append(e.delivered, id)
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+// endpoint returns where the outbox should post
+func (e *testEndpoint) endpoint() Endpoint {
+	return Endpoint{URL: e.URL, Client: e.Client()}
+}
+
+// fail makes deliveries of id fail, or succeed again
+func (e *testEndpoint) fail(id string, fail bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failing[id] = fail
+}
+
+// takeDelivered returns the IDs delivered since the last call
+func (e *testEndpoint) takeDelivered() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ids := e.delivered
+	e.delivered = nil
+	return ids
+}
+
+// enqueue adds events created age ago, a millisecond apart in the order given
+func enqueue(t *testing.T, o *Outbox, age time.Duration, ids ...string) {
+	t.Helper()
+	created := time.Now().Add(-age).UnixMilli()
+	for i, id := range ids {
+		e := Event{ID: id, Type: "alert", Timestamp: created + int64(i), Data: map[string]interface{}{"n": i}}
+		if err := o.Enqueue("", e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// pendingIDs lists the deliveries left in the outbox
+func pendingIDs(t *testing.T, o *Outbox) []string {
+	t.Helper()
+	deliveries, err := o.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, d := range deliveries {
+		ids = append(ids, d.ID)
+	}
+	return ids
+}
+
+// collectErrors is an onError recording what it's told
+type collectErrors []error
+
+func (c *collectErrors) add(err error) { *c = append(*c, err) }
+
+func TestOutboxDeliversOldestFirst(t *testing.T) {
+	o, err := OpenOutbox(t.TempDir(), DefaultMaxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := newTestEndpoint(t)
+
+	// Queued out of order, the oldest goes first
+	enqueue(t, o, time.Minute, "b", "c")
+	enqueue(t, o, time.Hour, "a")
+
+	var errs collectErrors
+	if wait := o.flush(context.Background(), endpoint.endpoint(), errs.add); wait != time.Hour {
+		t.Errorf("wait after delivering everything = %s, want idle", wait)
+	}
+	if got := endpoint.takeDelivered(); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("delivered %v, want a, b, c", got)
+	}
+	if ids := pendingIDs(t, o); ids != nil || errs != nil {
+		t.Errorf("pending %v with errors %v after delivering, want none", ids, errs)
+	}
+}
+
+func TestOutboxFailureHoldsBackLaterDeliveries(t *testing.T) {
+	o, err := OpenOutbox(t.TempDir(), DefaultMaxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := newTestEndpoint(t)
+	endpoint.fail("b", true)
+	enqueue(t, o, time.Minute, "a", "b", "c")
+
+	// Each failure doubles the wait before the next attempt, and c waits behind b
+	var errs collectErrors
+	for attempt, want := range []time.Duration{retryBaseDelay, 2 * retryBaseDelay, 4 * retryBaseDelay} {
+		start := time.Now()
+		wait := o.flush(context.Background(), endpoint.endpoint(), errs.add)
+		if wait != want {
+			t.Errorf("attempt %d: wait = %s, want %s", attempt+1, wait, want)
+		}
+
+		deliveries, _ := o.Pending()
+		if len(deliveries) != 2 || deliveries[0].ID != "b" {
+			t.Fatalf("attempt %d: pending %+v, want b then c", attempt+1, deliveries)
+		}
+		b := deliveries[0]
+		if b.Attempts != attempt+1 || b.LastError == "" {
+			t.Errorf("attempt %d: b has %d attempts, last error %q", attempt+1, b.Attempts, b.LastError)
+		}
+		if next := time.UnixMilli(b.NextAttempt).Sub(start); next < want-time.Second || next > want+time.Second {
+			t.Errorf("attempt %d: next attempt in %s, want %s", attempt+1, next, want)
+		}
+
+		// Not due yet, so nothing is sent
+		if wait := o.flush(context.Background(), endpoint.endpoint(), errs.add); wait <= 0 || wait > want {
+			t.Errorf("attempt %d: wait before b is due = %s", attempt+1, wait)
+		}
+		if err := o.RetryNow(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := endpoint.takeDelivered(); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("delivered %v while b was failing, want only a", got)
+	}
+	if len(errs) != 3 {
+		t.Errorf("onError told of %d errors, want one per failed attempt: %v", len(errs), errs)
+	}
+
+	// Once b goes through, c follows straight away
+	endpoint.fail("b", false)
+	o.flush(context.Background(), endpoint.endpoint(), errs.add)
+	if got := endpoint.takeDelivered(); !slices.Equal(got, []string{"b", "c"}) {
+		t.Errorf("delivered %v after b recovered, want b, c", got)
+	}
+}
+
+func TestOutboxRetryNow(t *testing.T) {
+	o, err := OpenOutbox(t.TempDir(), DefaultMaxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := newTestEndpoint(t)
+	endpoint.fail("a", true)
+	enqueue(t, o, time.Minute, "a")
+
+	var errs collectErrors
+	o.flush(context.Background(), endpoint.endpoint(), errs.add)
+	<-o.wake // drained from Enqueue, as Run would
+
+	endpoint.fail("a", false)
+	if err := o.RetryNow(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-o.wake:
+	default:
+		t.Error("RetryNow didn't wake the delivery loop")
+	}
+
+	// Due now, keeping its attempts so far
+	deliveries, _ := o.Pending()
+	if len(deliveries) != 1 || deliveries[0].NextAttempt != deliveries[0].Created || deliveries[0].Attempts != 1 {
+		t.Fatalf("pending after RetryNow = %+v", deliveries)
+	}
+	o.flush(context.Background(), endpoint.endpoint(), errs.add)
+	if got := endpoint.takeDelivered(); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("delivered %v after RetryNow, want a", got)
+	}
+}
+
+func TestOutboxDropsExpiredDeliveries(t *testing.T) {
+	o, err := OpenOutbox(t.TempDir(), DefaultMaxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := newTestEndpoint(t)
+	enqueue(t, o, DefaultMaxAge+time.Minute, "expired")
+	enqueue(t, o, DefaultMaxAge-time.Minute, "fresh")
+
+	var errs collectErrors
+	o.flush(context.Background(), endpoint.endpoint(), errs.add)
+	if got := endpoint.takeDelivered(); !slices.Equal(got, []string{"fresh"}) {
+		t.Errorf("delivered %v, want only fresh", got)
+	}
+	if ids := pendingIDs(t, o); ids != nil {
+		t.Errorf("pending %v, want the expired delivery dropped", ids)
+	}
+	if len(errs) != 1 {
+		t.Errorf("onError told of %v, want the dropped delivery", errs)
+	}
+}
+
+func TestOutboxSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	o, err := OpenOutbox(dir, DefaultMaxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, o, time.Minute, "a", "b")
+
+	// A file cut short by a crash is skipped rather than blocking the rest
+	if err := os.WriteFile(filepath.Join(dir, "partial.json"), []byte(`{"id":"par`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenOutbox(dir, DefaultMaxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := pendingIDs(t, reopened); !slices.Equal(ids, []string{"a", "b"}) {
+		t.Fatalf("pending after reopening = %v, want a, b", ids)
+	}
+
+	endpoint := newTestEndpoint(t)
+	var errs collectErrors
+	reopened.flush(context.Background(), endpoint.endpoint(), errs.add)
+	if got := endpoint.takeDelivered(); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("delivered %v after reopening, want a, b", got)
+	}
+}
+
+func TestOutboxPausedWithoutURL(t *testing.T) {
+	o, err := OpenOutbox(t.TempDir(), DefaultMaxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, o, time.Minute, "a")
+
+	var errs collectErrors
+	if wait := o.flush(context.Background(), Endpoint{}, errs.add); wait != time.Hour {
+		t.Errorf("wait while paused = %s, want idle", wait)
+	}
+	deliveries, _ := o.Pending()
+	if len(deliveries) != 1 || deliveries[0].Attempts != 0 || errs != nil {
+		t.Errorf("pending %+v with errors %v while paused, want a untouched", deliveries, errs)
+	}
+}
+
+func TestOutboxRun(t *testing.T) {
+	o, err := OpenOutbox(t.TempDir(), DefaultMaxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := newTestEndpoint(t)
+
+	var mu sync.Mutex
+	enabled := false
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		o.Run(ctx, func() Endpoint {
+			mu.Lock()
+			defer mu.Unlock()
+			if !enabled {
+				return Endpoint{}
+			}
+			return endpoint.endpoint()
+		}, nil)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	// waitDelivered waits for the endpoint to have received ids
+	waitDelivered := func(ids ...string) {
+		t.Helper()
+		var got []string
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			got = append(got, endpoint.takeDelivered()...)
+			if slices.Equal(got, ids) {
+				return
+			}
+		}
+		t.Fatalf("delivered %v, want %v", got, ids)
+	}
+
+	// Queued while paused, then sent once re-enabled
+	enqueue(t, o, time.Minute, "a")
+	time.Sleep(50 * time.Millisecond)
+	if got := endpoint.takeDelivered(); got != nil {
+		t.Fatalf("delivered %v while paused", got)
+	}
+	mu.Lock()
+	enabled = true
+	mu.Unlock()
+	o.RetryNow()
+	waitDelivered("a")
+
+	// Enqueueing wakes the loop
+	enqueue(t, o, 0, "b")
+	waitDelivered("b")
+}
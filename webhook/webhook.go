@@ -0,0 +1,168 @@
+// Package webhook posts events to an HTTP endpoint as JSON signed with HMAC-SHA256.
+//
+// Events are rendered when they happen and kept in an on-disk outbox until the endpoint
+// accepts them, retrying with exponential backoff, so nothing is lost while the machine
+// or the endpoint is offline.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Request headers describing a delivery
+const (
+	// SignatureHeader holds "sha256=" and the hex HMAC-SHA256 of the body, keyed with
+	// the secret. It is only sent when a secret is set.
+	SignatureHeader = "X-Sungrow-Signature"
+	EventHeader     = "X-Sungrow-Event"
+	DeliveryHeader  = "X-Sungrow-Delivery"
+)
+
+// Event is something to report, such as an alert firing
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	Timestamp int64       `json:"timestamp"` // Unix milliseconds
+	Data      interface{} `json:"data"`
+}
+
+// NewEvent creates an event with a unique ID, ordered by creation time
+func NewEvent(eventType string, data interface{}) Event {
+	now := time.Now()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return Event{
+		ID:        fmt.Sprintf("%d-%s", now.UnixNano(), hex.EncodeToString(b)),
+		Type:      eventType,
+		Timestamp: now.UnixMilli(),
+		Data:      data,
+	}
+}
+
+// Render builds the request body of an event. An empty template sends the event itself.
+// Templates see the event as its JSON, e.g. {{.type}} and {{.data.message}}, and can
+// quote values with the json function: {"text": {{json .data.message}}}. The result
+// must be valid JSON.
+func Render(tmpl string, e Event) ([]byte, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return json.Marshal(e)
+	}
+
+	t, err := parseTemplate(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round trip through JSON so templates use the same field names as the default body
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	if err := t.Execute(&body, data); err != nil {
+		return nil, fmt.Errorf("rendering webhook template: %w", err)
+	}
+	if !json.Valid(body.Bytes()) {
+		return nil, fmt.Errorf("webhook template did not produce valid JSON: %s", truncate(body.String(), 200))
+	}
+	return body.Bytes(), nil
+}
+
+// CheckTemplate reports whether a template can be parsed. Whether it renders valid
+// JSON depends on the event, so that is only checked when rendering.
+func CheckTemplate(tmpl string) error {
+	if strings.TrimSpace(tmpl) == "" {
+		return nil
+	}
+	_, err := parseTemplate(tmpl)
+	return err
+}
+
+// parseTemplate compiles a body template
+func parseTemplate(tmpl string) (*template.Template, error) {
+	t, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %w", err)
+	}
+	return t, nil
+}
+
+// Sign returns the signature header value of a body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Endpoint is where deliveries are posted
+type Endpoint struct {
+	URL    string
+	Secret string
+	Client *http.Client // http.DefaultClient if nil
+}
+
+// Post sends a rendered event once. Any response other than 2xx is an error.
+func (e Endpoint) Post(ctx context.Context, eventType, deliveryID string, body []byte) error {
+	if e.URL == "" {
+		return fmt.Errorf("webhook URL is not set")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SungrowMonitor-Webhook")
+	req.Header.Set(EventHeader, eventType)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	if e.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(e.Secret, body))
+	}
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned HTTP %d: %s", resp.StatusCode, truncate(strings.TrimSpace(string(detail)), 200))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// truncate shortens text for error messages
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,134 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testEvent is an alert event with a message needing JSON escaping
+func testEvent() Event {
+	return Event{
+		ID:        "1700000000000000000-0a0b0c0d",
+		Type:      "alert",
+		Timestamp: 1_700_000_000_000,
+		Data:      map[string]interface{}{"message": `Battery "low": 9%`, "value": 9},
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "default body is the event",
+			tmpl: " \n",
+			want: `{"id":"1700000000000000000-0a0b0c0d","type":"alert","timestamp":1700000000000,"data":{"message":"Battery \"low\": 9%","value":9}}`,
+		},
+		{
+			name: "json quotes values",
+			tmpl: `{"text": {{json .data.message}}, "kind": "{{.type}}"}`,
+			want: `{"text": "Battery \"low\": 9%", "kind": "alert"}`,
+		},
+		{
+			name: "missing keys render as zero values",
+			tmpl: `{"missing": {{json .data.nothing}}}`,
+			want: `{"missing": null}`,
+		},
+		{
+			name:    "unquoted values aren't valid JSON",
+			tmpl:    `{"text": {{.data.message}}}`,
+			wantErr: "did not produce valid JSON",
+		},
+		{
+			name:    "template syntax errors",
+			tmpl:    `{"text": {{json .data.message}`,
+			wantErr: "invalid webhook template",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := Render(tt.tmpl, testEvent())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Render error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("Render = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+	if err := CheckTemplate(""); err != nil {
+		t.Errorf("CheckTemplate(\"\") = %v", err)
+	}
+	// Only rendering can tell whether the output is JSON
+	if err := CheckTemplate(`{"text": {{.data.message}}}`); err != nil {
+		t.Errorf("CheckTemplate of a parseable template = %v", err)
+	}
+	if err := CheckTemplate(`{{if}}`); err == nil {
+		t.Error("CheckTemplate accepted a broken template")
+	}
+}
+
+func TestSign(t *testing.T) {
+	// RFC 4231 test case 2
+	got := Sign("Jefe", []byte("what do ya want for nothing?"))
+	want := "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+	if got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+}
+
+func TestPost(t *testing.T) {
+	body, err := Render("", testEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		if string(got) != string(body) || !json.Valid(got) {
+			t.Errorf("body = %s", got)
+		}
+		for header, want := range map[string]string{
+			"Content-Type":  "application/json",
+			EventHeader:     "alert",
+			DeliveryHeader:  "delivery-1",
+			SignatureHeader: Sign("s3cret", body),
+		} {
+			if r.Header.Get(header) != want {
+				t.Errorf("%s = %q, want %q", header, r.Header.Get(header), want)
+			}
+		}
+		if r.URL.Path == "/broken" {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	endpoint := Endpoint{URL: srv.URL + "/hook", Secret: "s3cret", Client: srv.Client()}
+	if err := endpoint.Post(context.Background(), "alert", "delivery-1", body); err != nil {
+		t.Errorf("Post = %v", err)
+	}
+
+	endpoint.URL = srv.URL + "/broken"
+	err = endpoint.Post(context.Background(), "alert", "delivery-1", body)
+	if err == nil || !strings.Contains(err.Error(), "HTTP 503: try later") {
+		t.Errorf("Post to a failing endpoint = %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+
+	"wails-sungrow-isolarcloud-app/webhook"
+)
+
+// Webhook event types
+const (
+	webhookEventAlert  = "alert"
+	webhookEventStatus = "status"
+	webhookEventTest   = "test"
+)
+
+// webhookSecretKey is the secretSettings key of the webhook signing secret
+const webhookSecretKey = "webhookSecret"
+
+// webhookOutboxDir holds undelivered webhook events in the app config directory
+const webhookOutboxDir = "webhook-outbox"
+
+// WebhookSettings controls posting alerts and status changes to an HTTP endpoint
+type WebhookSettings struct {
+	Enabled bool   `json:"enabled"`
+	URL     string `json:"url"`
+	Secret  string `json:"secret,omitempty"` // signs each body with HMAC-SHA256
+	// Template is a text/template producing the JSON body, empty to send the event as is
+	Template      string `json:"template"`
+	Alerts        bool   `json:"alerts"`
+	StatusChanges bool   `json:"statusChanges"`
+}
+
+// endpoint is where the settings post to
+func (s WebhookSettings) endpoint(a *App) webhook.Endpoint {
+	return webhook.Endpoint{URL: s.URL, Secret: s.Secret, Client: a.httpClient}
+}
+
+// validate checks the template can be parsed
+func (s WebhookSettings) validate() error {
+	return webhook.CheckTemplate(s.Template)
+}
+
+// signingSecretMissing reports whether a signing secret was saved but couldn't be read
+// back from the credential store, so deliveries would go out unsigned
+func signingSecretMissing(s Settings) bool {
+	return s.Webhook.Secret == "" && slices.Contains(s.StoredSecrets, webhookSecretKey)
+}
+
+// testWebhookEvent is the event sent by SendTestWebhook
+func testWebhookEvent() webhook.Event {
+	return webhook.NewEvent(webhookEventTest, map[string]interface{}{
+		"message": "Test event from " + notificationAppName,
+	})
+}
+
+// sends reports whether events of a type are posted
+func (s WebhookSettings) sends(eventType string) bool {
+	if !s.Enabled || s.URL == "" {
+		return false
+	}
+	switch eventType {
+	case webhookEventAlert:
+		return s.Alerts
+	case webhookEventStatus:
+		return s.StatusChanges
+	}
+	return false
+}
+
+// openWebhookOutbox opens the webhook outbox and delivers from it until ctx is
+// cancelled. Events queued while the endpoint was unreachable are sent once it's back,
+// including those left from a previous run.
+func (a *App) openWebhookOutbox(ctx context.Context) {
+	appDir, err := appConfigDir()
+	if err != nil {
+		a.log.Printf("openWebhookOutbox: %v\n", err)
+		return
+	}
+
+	outbox, err := webhook.OpenOutbox(filepath.Join(appDir, webhookOutboxDir), webhook.DefaultMaxAge)
+	if err != nil {
+		a.log.Printf("openWebhookOutbox: %v\n", err)
+		return
+	}
+	a.webhooks = outbox
+	if settings := a.GetSettings(); settings.Webhook.Enabled && signingSecretMissing(settings) {
+		a.log.Printf("openWebhookOutbox: error: the webhook signing secret is unavailable, events will be sent unsigned\n")
+	}
+
+	// A disabled webhook pauses the outbox rather than posting to the last URL
+	go outbox.Run(ctx, func() webhook.Endpoint {
+		settings := a.GetSettings().Webhook
+		if !settings.Enabled {
+			return webhook.Endpoint{}
+		}
+		return settings.endpoint(a)
+	}, func(err error) {
+		a.log.Printf("webhook: %v\n", err)
+	})
+}
+
+// applyWebhookSettings retries queued events straight away, since the endpoint may
+// have just been fixed or re-enabled
+func (a *App) applyWebhookSettings(settings WebhookSettings) {
+	if a.webhooks == nil || !settings.Enabled {
+		return
+	}
+	if err := a.webhooks.RetryNow(); err != nil {
+		a.log.Printf("applyWebhookSettings: %v\n", err)
+	}
+}
+
+// publishWebhook queues an event for the webhook if it's enabled for that type
+func (a *App) publishWebhook(eventType string, data interface{}) {
+	all := a.GetSettings()
+	settings := all.Webhook
+	if a.webhooks == nil || !settings.sends(eventType) {
+		return
+	}
+	if signingSecretMissing(all) {
+		a.log.Printf("publishWebhook: error: the webhook signing secret is unavailable, sending %s event unsigned\n", eventType)
+	}
+
+	if err := a.webhooks.Enqueue(settings.Template, webhook.NewEvent(eventType, data)); err != nil {
+		a.log.Printf("publishWebhook: %v\n", err)
+	}
+}
+
+// SendTestWebhook posts a test event with the given, possibly unsaved, settings and
+// reports whether the endpoint accepted it. Nothing is queued on failure.
+func (a *App) SendTestWebhook(settings WebhookSettings) error {
+	event := testWebhookEvent()
+	body, err := webhook.Render(settings.Template, event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.httpClient.Timeout)
+	defer cancel()
+	return settings.endpoint(a).Post(ctx, event.Type, event.ID, body)
+}